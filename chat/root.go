@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxMessageLength is the longest chat message that the game shows in full (in characters)
+	MaxMessageLength = 128
+
+	// Shortest part that a long message is split into, so that a long prefix still leaves room for the message itself
+	minMessagePart = 32
+)
+
+// richTextRegex matches Unity/TextMeshPro rich text tags (eg. "<color=red>", "</size>", "<#ff0000>" or "<sprite name=x>"),
+// while leaving things like "<3" and "a < b" alone
+var richTextRegex = regexp.MustCompile(`<\/?[A-Za-z#][^<>]*>`)
+
+// Sanitize makes text from Discord safe to show in the game, removing rich text tags and any characters that would break the say command
+// (like line breaks and double quotes, which the console treats as argument delimiters)
+func Sanitize(text string) string {
+	// Removing a tag may form a new one (eg. "<<b>color=red>"), so keep going until there's nothing left to remove
+	for {
+		stripped := richTextRegex.ReplaceAllString(text, "")
+		if stripped == text {
+			break
+		}
+		text = stripped
+	}
+
+	text = strings.Map(func(r rune) rune {
+		if r == '"' {
+			return '\''
+		}
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return ' '
+		}
+		if r == utf8.RuneError || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// Split splits a message into lines that fit in the chat (prefix included), breaking at spaces where possible
+func Split(prefix string, message string, limit int) []string {
+	length := limit - utf8.RuneCountInString(prefix)
+	if length < minMessagePart {
+		length = minMessagePart
+	}
+
+	lines := make([]string, 0)
+	runes := []rune(message)
+	for len(runes) > length {
+		split := length
+		for i := length; i > length/2; i-- {
+			if runes[i] == ' ' {
+				split = i
+				break
+			}
+		}
+		lines = append(lines, prefix+strings.TrimSpace(string(runes[:split])))
+		runes = []rune(strings.TrimSpace(string(runes[split:])))
+	}
+	if len(runes) > 0 {
+		lines = append(lines, prefix+string(runes))
+	}
+	return lines
+}
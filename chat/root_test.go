@@ -0,0 +1,58 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := map[string]string{
+		"Hello there": "Hello there",
+		"<color=red>Hello</color> <size=100>there": "Hello there",
+		"<#ff0000>Red</color> <b><i>text</i></b>":  "Red text",
+		"<<b>color=red>sneaky":                     "sneaky",
+		`<sprite name="x"> hi`:                     "hi",
+		"I <3 Rust, 1 < 2 > 0":                     "I <3 Rust, 1 < 2 > 0",
+		"Line\nbreak\r\n\ttab":                     "Line break tab",
+		`say "quoted" text`:                        "say 'quoted' text",
+		"zero\u200bwidth\u0000":                    "zerowidth",
+		"  <b> </b>  ":                             "",
+	}
+	for input, expected := range tests {
+		if output := Sanitize(input); output != expected {
+			t.Errorf("expected %q to be sanitized to %q but got %q", input, expected, output)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	prefix := "[DISCORD] Dids: "
+	if lines := Split(prefix, "Hello", MaxMessageLength); len(lines) != 1 || lines[0] != prefix+"Hello" {
+		t.Fatal("expected a single line but got", lines)
+	}
+
+	message := strings.Repeat("lorem ipsum ", 30)
+	lines := Split(prefix, strings.TrimSpace(message), MaxMessageLength)
+	if len(lines) != 4 {
+		t.Error("expected 4 lines but got", len(lines), lines)
+	}
+	parts := make([]string, 0)
+	for _, line := range lines {
+		if length := len([]rune(line)); length > MaxMessageLength {
+			t.Error("expected lines to fit in", MaxMessageLength, "characters but got", length, line)
+		}
+		if !strings.HasPrefix(line, prefix) || strings.HasSuffix(line, " ") {
+			t.Errorf("unexpected line %q", line)
+		}
+		parts = append(parts, strings.TrimPrefix(line, prefix))
+	}
+	if strings.Join(parts, " ") != strings.TrimSpace(message) {
+		t.Error("expected the lines to contain the whole message but got", parts)
+	}
+
+	// Words that are too long to fit are split anywhere, counting characters instead of bytes
+	lines = Split(prefix, strings.Repeat("ä", 200), MaxMessageLength)
+	if len(lines) != 2 || len([]rune(lines[0])) != MaxMessageLength || lines[1] != prefix+strings.Repeat("ä", 200-(MaxMessageLength-len([]rune(prefix)))) {
+		t.Error("unexpected lines:", lines)
+	}
+}
@@ -2,7 +2,9 @@ package database
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
+	"strconv"
 
 	"github.com/Dids/rustbot/logger"
 	"github.com/HouzuoGuo/tiedot/db"
@@ -127,6 +129,18 @@ func (database *Database) Set(collection string, objectID int, value map[string]
 	return objectID, nil
 }
 
+// Delete removes an object from the database
+func (database *Database) Delete(collection string, objectID int) error {
+	// Switch to the collection
+	objects, collectionErr := database.GetCollection(collection)
+	if collectionErr != nil {
+		return collectionErr
+	}
+
+	// Delete the object from the collection
+	return objects.Delete(objectID)
+}
+
 // Query the database directly
 func (database *Database) Query(collection string, query string) (map[int]map[string]interface{}, error) {
 	//log.Println("Executing query:", query)
@@ -165,6 +179,58 @@ func (database *Database) Query(collection string, query string) (map[int]map[st
 	return results, nil
 }
 
+// GetState returns a single persisted value from a state collection, where every object is a key and its value
+// (or nil if it hasn't been set yet)
+func (database *Database) GetState(collection string, key string) (interface{}, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	matches, err := database.queryState(collection, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range matches {
+		return state["Value"], nil
+	}
+
+	return nil, nil
+}
+
+// SetState persists a single value in a state collection
+func (database *Database) SetState(collection string, key string, value interface{}) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	// Reuse the existing object if the key has been set before
+	matches, err := database.queryState(collection, key)
+	if err != nil {
+		return err
+	}
+	objectID := 0
+	for id := range matches {
+		objectID = id
+		break
+	}
+
+	_, err = database.Set(collection, objectID, map[string]interface{}{"Key": key, "Value": value})
+	return err
+}
+
+// queryState returns the objects of a state collection with the given key, making sure that the key is indexed first
+func (database *Database) queryState(collection string, key string) (map[int]map[string]interface{}, error) {
+	objects, err := database.GetCollection(collection)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.createIndexes(objects, []string{"Key"}); err != nil {
+		return nil, err
+	}
+
+	return database.Query(collection, `[{"eq": `+strconv.Quote(key)+`, "in": ["Key"]}]`)
+}
+
 // GetCollection returns a reference to the collection object
 func (database *Database) GetCollection(collection string) (*db.Col, error) {
 	// Make sure the collection exists first
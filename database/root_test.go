@@ -53,8 +53,50 @@ func TestDatabase(t *testing.T) {
 		t.Fatal(results, "Query returned empty results")
 	}
 
+	// Delete the object and verify that it's gone
+	if err := database.Delete("test_data", id); err != nil {
+		t.Fatal(id, err)
+	}
+	if object, err := database.Get("test_data", id); err == nil {
+		t.Fatal(object, "Deleted object still exists")
+	}
+
 	// Close the database
 	if err := database.Close(); err != nil {
 		t.Fatal(err)
 	}
 }
+
+func TestState(t *testing.T) {
+	database, err := NewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(database.Path)
+	defer os.RemoveAll(database.Path)
+	if err := database.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	// Values that haven't been set yet are nil
+	if value, err := database.GetState("test_state", "SomeKey"); err != nil || value != nil {
+		t.Fatal("expected no value but got", value, err)
+	}
+
+	// Setting a value again replaces it, and every collection has its own values
+	for _, value := range []string{"first", "second"} {
+		if err := database.SetState("test_state", "SomeKey", value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.SetState("other_state", "SomeKey", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := database.GetState("test_state", "SomeKey"); err != nil || value != "second" {
+		t.Fatal("expected the latest value but got", value, err)
+	}
+	if matches, err := database.Query("test_state", `[{"eq": "SomeKey", "in": ["Key"]}]`); err != nil || len(matches) != 1 {
+		t.Fatal("expected a single object for the key but got", matches, err)
+	}
+}
@@ -0,0 +1,159 @@
+package discord
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultAlarmCooldown is how long to wait before notifying about the same alarm again
+const defaultAlarmCooldown = 5 * time.Minute
+
+// addAlarmCommands adds the slash commands for subscribing to smart alarms
+func (discord *Discord) addAlarmCommands() {
+	nameOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Name of the smart alarm", Required: true}
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "alarm",
+			Description: "Get a direct message when a smart alarm is triggered",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "subscribe", Description: "Subscribe to a smart alarm", Options: []*discordgo.ApplicationCommandOption{nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "unsubscribe", Description: "Unsubscribe from a smart alarm", Options: []*discordgo.ApplicationCommandOption{nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "List all smart alarms and your subscriptions"},
+			},
+		},
+		handler:   discord.handleAlarmCommand,
+		ephemeral: true,
+	})
+}
+
+func (discord *Discord) handleAlarmCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	user := interactionUser(interaction)
+	if user == nil {
+		return nil, errors.New("unable to determine the user")
+	}
+
+	switch subcommand {
+	case "subscribe", "unsubscribe":
+		entity, err := discord.RustPlus.GetEntity(options["name"].StringValue())
+		if err != nil {
+			return nil, err
+		}
+		if entity.Type != rustplus.AppEntityType_Alarm {
+			return nil, errors.New(entity.Name + " is not a smart alarm")
+		}
+		if subcommand == "subscribe" {
+			if err := addAlarmSubscription(discord.Database, entity.Name, user.ID); err != nil {
+				return nil, err
+			}
+			return &discordgo.WebhookEdit{Content: "You will now receive a direct message when **" + escapeMarkdown(entity.Name) + "** is triggered"}, nil
+		}
+		if err := removeAlarmSubscription(discord.Database, entity.Name, user.ID); err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: "You will no longer receive direct messages for **" + escapeMarkdown(entity.Name) + "**"}, nil
+	case "list":
+		entities, err := discord.RustPlus.GetEntities()
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]string, 0)
+		for _, entity := range entities {
+			if entity.Type != rustplus.AppEntityType_Alarm {
+				continue
+			}
+			line := formatEntity(entity)
+			if subscribers, err := loadAlarmSubscribers(discord.Database, entity.Name); err == nil {
+				for _, subscriber := range subscribers {
+					if subscriber == user.ID {
+						line += " (subscribed)"
+						break
+					}
+				}
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) <= 0 {
+			return &discordgo.WebhookEdit{Content: "No smart alarms have been paired yet"}, nil
+		}
+		return &discordgo.WebhookEdit{Content: strings.Join(lines, "\n")}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
+
+// handleAlarm notifies the alarm channel and every subscriber about a triggered alarm, unless it's still on cooldown
+func (discord *Discord) handleAlarm(message eventhandler.Message) {
+	if !discord.alarmReady(message.User, alarmCooldown()) {
+		discord.logger.Trace("Skipping alarm notification due to cooldown:", message.User)
+		return
+	}
+
+	content := "🚨 **" + escapeMarkdown(message.User) + "** was triggered!"
+
+	// Send the high priority notification to the alarm channel
+	if channelID := os.Getenv("DISCORD_ALARM_CHANNEL_ID"); len(channelID) > 0 {
+		channelContent := content
+		if mention := os.Getenv("DISCORD_ALARM_MENTION"); len(mention) > 0 {
+			channelContent = mention + " " + content
+		}
+		if _, err := discord.Client.ChannelMessageSend(channelID, channelContent); err != nil {
+			discord.logger.Error("Failed to send alarm notification:", message, "with error:", err)
+		}
+	}
+
+	// Send a direct message to every subscriber
+	subscribers, err := loadAlarmSubscribers(discord.Database, message.User)
+	if err != nil {
+		discord.logger.Error("Failed to load alarm subscribers:", err)
+		return
+	}
+	for _, userID := range subscribers {
+		channel, err := discord.Client.UserChannelCreate(userID)
+		if err != nil {
+			discord.logger.Error("Failed to open direct message channel for user", userID, "with error:", err)
+			continue
+		}
+		if _, err := discord.Client.ChannelMessageSend(channel.ID, content); err != nil {
+			discord.logger.Error("Failed to send alarm notification to user", userID, "with error:", err)
+		}
+	}
+}
+
+// alarmReady returns true (and starts a new cooldown) if the alarm hasn't sent notifications within the cooldown
+func (discord *Discord) alarmReady(name string, cooldown time.Duration) bool {
+	discord.alarmMutex.Lock()
+	defer discord.alarmMutex.Unlock()
+
+	if discord.alarmCooldowns == nil {
+		discord.alarmCooldowns = make(map[string]time.Time)
+	}
+	key := strings.ToLower(name)
+	if last, ok := discord.alarmCooldowns[key]; ok && time.Since(last) < cooldown {
+		return false
+	}
+	discord.alarmCooldowns[key] = time.Now()
+	return true
+}
+
+// alarmCooldown returns the configured alarm cooldown in seconds, or the default cooldown
+func alarmCooldown() time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv("DISCORD_ALARM_COOLDOWN")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultAlarmCooldown
+}
+
+// interactionUser returns the user who invoked an interaction, both in guilds and in direct messages
+func interactionUser(interaction *discordgo.InteractionCreate) *discordgo.User {
+	if interaction.Member != nil && interaction.Member.User != nil {
+		return interaction.Member.User
+	}
+	return interaction.User
+}
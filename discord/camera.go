@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// defaultCameraWatch is how long a camera is watched, unless specified otherwise
+	defaultCameraWatch = 5
+
+	// maxCameraWatch is the longest a camera can be watched, in seconds
+	maxCameraWatch = 30
+
+	// maxCameraFiles is the maximum number of attachments in a single Discord message
+	maxCameraFiles = 10
+)
+
+// addCameraCommands adds the slash command for viewing CCTV cameras
+func (discord *Discord) addCameraCommands() {
+	identifierOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "identifier", Description: "Identifier of the CCTV camera", Required: true}
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "camera",
+			Description: "View CCTV cameras",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "view", Description: "Show the latest frame of a camera", Options: []*discordgo.ApplicationCommandOption{identifierOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "watch", Description: "Capture a short sequence of frames from a camera", Options: []*discordgo.ApplicationCommandOption{
+					identifierOption,
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "seconds", Description: "How long to watch for, up to 30 seconds (defaults to 5)"},
+					{Type: discordgo.ApplicationCommandOptionString, Name: "format", Description: "How to post the frames (defaults to an animated GIF)", Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Animated GIF", Value: "gif"},
+						{Name: "Frames", Value: "frames"},
+					}},
+				}},
+			},
+		},
+		handler: discord.handleCameraCommand,
+	})
+}
+
+func (discord *Discord) handleCameraCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	identifier := options["identifier"].StringValue()
+	switch subcommand {
+	case "view":
+		data, err := discord.RustPlus.GetCameraImage(context.Background(), identifier)
+		if err != nil {
+			return nil, err
+		}
+		content := "📷 **" + escapeMarkdown(identifier) + "**"
+		return &discordgo.WebhookEdit{Content: content, Files: []*discordgo.File{cameraFile(0, "jpg", data)}}, nil
+	case "watch":
+		seconds := defaultCameraWatch
+		if option, ok := options["seconds"]; ok {
+			seconds = int(option.IntValue())
+		}
+		if seconds <= 0 || seconds > maxCameraWatch {
+			return nil, errors.New("seconds must be between 1 and " + strconv.Itoa(maxCameraWatch))
+		}
+		format := "gif"
+		if option, ok := options["format"]; ok {
+			format = option.StringValue()
+		}
+
+		// Spread the frames evenly, keeping the number of frames within the attachment limit
+		count := seconds + 1
+		if count > maxCameraFiles {
+			count = maxCameraFiles
+		}
+		interval := time.Duration(seconds) * time.Second / time.Duration(count-1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(seconds)*time.Second+time.Minute)
+		defer cancel()
+		frames, err := discord.RustPlus.RecordCamera(ctx, identifier, count, interval)
+		if err != nil {
+			return nil, err
+		}
+
+		content := "📷 **" + escapeMarkdown(identifier) + "** over " + strconv.Itoa(seconds) + "s"
+		if format == "frames" {
+			files := make([]*discordgo.File, 0, len(frames))
+			for i, frame := range frames {
+				files = append(files, cameraFile(i+1, "jpg", frame))
+			}
+			return &discordgo.WebhookEdit{Content: content, Files: files}, nil
+		}
+		data, err := rustplus.EncodeCameraGIF(frames, interval)
+		if err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: content, Files: []*discordgo.File{cameraFile(0, "gif", data)}}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
+
+// cameraFile returns a camera image as a Discord attachment (numbered when it's part of a sequence)
+func cameraFile(number int, extension string, data []byte) *discordgo.File {
+	name := "camera"
+	if number > 0 {
+		name += "-" + strconv.Itoa(number)
+	}
+	contentType := "image/jpeg"
+	if extension == "gif" {
+		contentType = "image/gif"
+	}
+	return &discordgo.File{Name: name + "." + extension, ContentType: contentType, Reader: bytes.NewReader(data)}
+}
@@ -0,0 +1,90 @@
+package discord
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/webrcon"
+)
+
+// Destinations for the in-game chat channels (besides the ID of a Discord channel)
+const (
+	chatRouteChat = "chat" // The chat channel of the server
+	chatRouteTag  = "tag"  // The chat channel of the server, tagging messages with their in-game channel (eg. "[Team] ")
+	chatRouteDrop = "drop" // Nowhere
+)
+
+// Discord channel IDs are snowflakes
+var channelIDRegex = regexp.MustCompile(`^[0-9]+$`)
+
+// defaultChatRoutes only relays the global chat, so that team chat and server messages stay out of the public channel
+// (chat channels without a route are dropped)
+var defaultChatRoutes = map[string]string{"global": chatRouteChat}
+
+// parseChatRoutes parses the destinations of the in-game chat channels (eg. "team=123456789012345678,server=tag"),
+// where each destination is either chat, tag, drop or the ID of a Discord channel (like an admin only log)
+func parseChatRoutes(value string) (map[string]string, error) {
+	routes := make(map[string]string)
+	for chatChannel, route := range defaultChatRoutes {
+		routes[chatChannel] = route
+	}
+	if len(strings.TrimSpace(value)) <= 0 {
+		return routes, nil
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("expected channel=destination: " + field)
+		}
+		chatChannel := strings.ToLower(strings.TrimSpace(parts[0]))
+		if !isChatChannel(chatChannel) {
+			return nil, errors.New("unknown chat channel: " + chatChannel + " (expected one of " + strings.Join(webrcon.ChatChannelNames(), ", ") + ")")
+		}
+		route := strings.ToLower(strings.TrimSpace(parts[1]))
+		if route != chatRouteChat && route != chatRouteTag && route != chatRouteDrop && !channelIDRegex.MatchString(route) {
+			return nil, errors.New("destination must be chat, tag, drop or a channel ID: " + field)
+		}
+		routes[chatChannel] = route
+	}
+	return routes, nil
+}
+
+// isChatChannel reports whether a name is the name of an in-game chat channel
+func isChatChannel(name string) bool {
+	for _, chatChannel := range webrcon.ChatChannelNames() {
+		if chatChannel == name {
+			return true
+		}
+	}
+	return false
+}
+
+// chatRoute returns the Discord channel for a chat message along with the prefix for it, or an empty channel if the message should be dropped
+func (discord *Discord) chatRoute(message eventhandler.Message) (string, string) {
+	chatChannel := message.ChatChannel
+	if len(chatChannel) <= 0 {
+		chatChannel = "global"
+	}
+
+	// Routes are configured per server, and chat channels without one are dropped
+	route, ok := discord.chatRoutes[message.Server][chatChannel]
+	if !ok {
+		route = chatRouteDrop
+	}
+
+	switch route {
+	case chatRouteDrop:
+		return "", ""
+	case chatRouteChat:
+		return serverChannel(message.Server, "DISCORD_CHAT_CHANNEL_ID"), discord.serverPrefix(message.Server, "DISCORD_CHAT_CHANNEL_ID")
+	case chatRouteTag:
+		tag := "[" + strings.ToUpper(chatChannel[:1]) + chatChannel[1:] + "] "
+		return serverChannel(message.Server, "DISCORD_CHAT_CHANNEL_ID"), discord.serverPrefix(message.Server, "DISCORD_CHAT_CHANNEL_ID") + tag
+	}
+
+	// Other Discord channels may be shared by several servers (just like the channels of console rules)
+	return route, discord.serverPrefix(message.Server, "")
+}
@@ -0,0 +1,87 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// addClockCommands adds the slash commands for checking the in-game time and managing time triggers
+func (discord *Discord) addClockCommands() {
+	nameOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Name of the smart switch or group", Required: true}
+	eventOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "event", Description: "When to switch", Required: true, Choices: []*discordgo.ApplicationCommandOptionChoice{
+		{Name: "Sunset", Value: rustplus.SunsetTrigger},
+		{Name: "Sunrise", Value: rustplus.SunriseTrigger},
+	}}
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "time",
+			Description: "Check the in-game time and switch things at sunset or sunrise",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "now", Description: "Show the in-game time and when the day/night changes"},
+				{Type: discordgo.ApplicationCommandOptionSubCommandGroup, Name: "trigger", Description: "Switch smart switches at sunset or sunrise", Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "add", Description: "Switch a smart switch or group at sunset or sunrise", Options: []*discordgo.ApplicationCommandOption{
+						nameOption,
+						eventOption,
+						{Type: discordgo.ApplicationCommandOptionBoolean, Name: "on", Description: "Turn on (true) or off (false)", Required: true},
+					}},
+					{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "remove", Description: "Remove a time trigger", Options: []*discordgo.ApplicationCommandOption{nameOption, eventOption}},
+					{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "List all time triggers"},
+				}},
+			},
+		},
+		handler: discord.handleClockCommand,
+	})
+}
+
+func (discord *Discord) handleClockCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	switch subcommand {
+	case "now":
+		clock, err := discord.RustPlus.GetClock(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if clock.IsDay() {
+			return &discordgo.WebhookEdit{Content: "☀️ It's **" + clock.String() + "**, night falls in " + rustplus.FormatDuration(clock.UntilSunset())}, nil
+		}
+		return &discordgo.WebhookEdit{Content: "🌙 It's **" + clock.String() + "**, the sun rises in " + rustplus.FormatDuration(clock.UntilSunrise())}, nil
+	case "trigger add":
+		trigger, err := discord.RustPlus.AddTimeTrigger(options["name"].StringValue(), options["event"].StringValue(), options["on"].BoolValue())
+		if err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: formatTimeTrigger(trigger)}, nil
+	case "trigger remove":
+		if err := discord.RustPlus.RemoveTimeTrigger(options["name"].StringValue(), options["event"].StringValue()); err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: "Removed the " + options["event"].StringValue() + " trigger of **" + escapeMarkdown(options["name"].StringValue()) + "**"}, nil
+	case "trigger list":
+		triggers, err := discord.RustPlus.GetTimeTriggers()
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]string, 0, len(triggers))
+		for _, trigger := range triggers {
+			lines = append(lines, formatTimeTrigger(trigger))
+		}
+		if len(lines) <= 0 {
+			return &discordgo.WebhookEdit{Content: "No time triggers have been added yet"}, nil
+		}
+		return &discordgo.WebhookEdit{Content: truncateMessage(strings.Join(lines, "\n"))}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
+
+// formatTimeTrigger formats a time trigger as "**Name** turns on at sunset"
+func formatTimeTrigger(trigger *rustplus.TimeTrigger) string {
+	state := "off"
+	if trigger.Value {
+		state = "on"
+	}
+	return "**" + escapeMarkdown(trigger.Name) + "** turns " + state + " at " + trigger.Event
+}
@@ -0,0 +1,90 @@
+package discord
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// commandHandler handles a single invocation of a slash command, with the (space separated) subcommand path and its options
+type commandHandler func(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error)
+
+// command is a single slash command and the function that handles it
+type command struct {
+	definition *discordgo.ApplicationCommand
+	handler    commandHandler
+
+	// Ephemeral responses are only visible to the user who invoked the command
+	ephemeral bool
+}
+
+// addCommand adds a slash command, which will be registered with Discord once the client is ready
+func (discord *Discord) addCommand(cmd *command) {
+	if discord.commands == nil {
+		discord.commands = make(map[string]*command)
+	}
+	discord.commands[cmd.definition.Name] = cmd
+}
+
+// registerCommands registers (or replaces) all of our global slash commands with Discord
+func (discord *Discord) registerCommands(applicationID string) error {
+	definitions := make([]*discordgo.ApplicationCommand, 0, len(discord.commands))
+	for _, cmd := range discord.commands {
+		definitions = append(definitions, cmd.definition)
+	}
+	_, err := discord.Client.ApplicationCommandBulkOverwrite(applicationID, "", definitions)
+	return err
+}
+
+func (discord *Discord) handleInteractionCreate(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+	if interaction.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	data := interaction.ApplicationCommandData()
+	cmd, ok := discord.commands[data.Name]
+	if !ok {
+		discord.logger.Warning("Received unknown command:", data.Name)
+		return
+	}
+	discord.logger.Trace("Discord event: command", data.Name)
+
+	// Acknowledge the command right away, as Rust+ requests may take longer than Discord allows for a response
+	flags := uint64(0)
+	if cmd.ephemeral {
+		flags = uint64(discordgo.MessageFlagsEphemeral)
+	}
+	if err := session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: flags},
+	}); err != nil {
+		discord.logger.Error("Failed to acknowledge command:", data.Name, "with error:", err)
+		return
+	}
+
+	// Run the command and replace the acknowledgement with the result
+	subcommand, options := commandOptions(data.Options)
+	response, err := cmd.handler(interaction, subcommand, options)
+	if err != nil {
+		response = &discordgo.WebhookEdit{Content: "❗ " + err.Error()}
+	}
+	if _, err := session.InteractionResponseEdit(interaction.Interaction, response); err != nil {
+		discord.logger.Error("Failed to respond to command:", data.Name, "with error:", err)
+	}
+}
+
+// commandOptions returns the (space separated) subcommand path and the options of the innermost subcommand
+func commandOptions(options []*discordgo.ApplicationCommandInteractionDataOption) (string, map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	path := make([]string, 0)
+	for len(options) == 1 && (options[0].Type == discordgo.ApplicationCommandOptionSubCommand || options[0].Type == discordgo.ApplicationCommandOptionSubCommandGroup) {
+		path = append(path, options[0].Name)
+		options = options[0].Options
+	}
+
+	result := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, option := range options {
+		result[option.Name] = option
+	}
+
+	return strings.Join(path, " "), result
+}
@@ -37,4 +37,9 @@ func (discord *Discord) handleRateLimit(session *discordgo.Session, event *disco
 func (discord *Discord) handleReady(session *discordgo.Session, event *discordgo.Ready) {
 	discord.logger.Trace("Discord event: ready")
 	discord.IsReady = true
+
+	// Register our slash commands
+	if err := discord.registerCommands(event.User.ID); err != nil {
+		discord.logger.Error("Failed to register commands:", err)
+	}
 }
@@ -0,0 +1,88 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// addCredentialCommands adds the (direct message only) slash commands for registering Rust+ credentials
+func (discord *Discord) addCredentialCommands() {
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "rustplus",
+			Description: "Manage your Rust+ credentials (direct messages only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "register", Description: "Register your Rust+ player ID and token", Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionString, Name: "player_id", Description: "Your Steam ID", Required: true},
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "player_token", Description: "Your Rust+ player token", Required: true},
+				}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "unregister", Description: "Remove your Rust+ credentials"},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "status", Description: "Check the status of your Rust+ credentials"},
+			},
+		},
+		handler:   discord.handleCredentialCommand,
+		ephemeral: true,
+	})
+}
+
+func (discord *Discord) handleCredentialCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	// Player tokens are secrets, so never accept them in a guild channel
+	if len(interaction.GuildID) > 0 {
+		return nil, errors.New("for your own safety, this command only works in direct messages")
+	}
+	user := interactionUser(interaction)
+	if user == nil {
+		return nil, errors.New("unable to determine the user")
+	}
+
+	switch subcommand {
+	case "register":
+		playerID, err := strconv.ParseUint(strings.TrimSpace(options["player_id"].StringValue()), 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid player ID, it should be your 17 digit Steam ID")
+		}
+		playerToken := options["player_token"].IntValue()
+		if int64(int32(playerToken)) != playerToken {
+			return nil, errors.New("invalid player token")
+		}
+		if _, err := discord.RustPlus.RegisterCredential(context.Background(), user.ID, playerID, int32(playerToken)); err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: "Your Rust+ credentials were verified and registered"}, nil
+	case "unregister":
+		if err := discord.RustPlus.UnregisterCredential(user.ID); err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: "Your Rust+ credentials were removed"}, nil
+	case "status":
+		credential, err := discord.RustPlus.GetCredential(user.ID)
+		if err == rustplus.ErrCredentialNotFound {
+			return &discordgo.WebhookEdit{Content: "You haven't registered any Rust+ credentials yet"}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !credential.Valid {
+			return &discordgo.WebhookEdit{Content: "Your Rust+ credentials for player `" + strconv.FormatUint(credential.PlayerID, 10) + "` are invalid (" + escapeMarkdown(credential.Error) + "), please register new ones"}, nil
+		}
+		return &discordgo.WebhookEdit{Content: "Your Rust+ credentials for player `" + strconv.FormatUint(credential.PlayerID, 10) + "` are valid"}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
+
+// handleCredentialInvalid lets a user know that their Rust+ credentials were rejected by the server
+func (discord *Discord) handleCredentialInvalid(message eventhandler.Message) {
+	channel, err := discord.Client.UserChannelCreate(message.User)
+	if err != nil {
+		discord.logger.Error("Failed to open direct message channel for user", message.User, "with error:", err)
+		return
+	}
+	if _, err := discord.Client.ChannelMessageSend(channel.ID, message.Message); err != nil {
+		discord.logger.Error("Failed to send credential notification to user", message.User, "with error:", err)
+	}
+}
@@ -0,0 +1,122 @@
+package discord
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Dids/rustbot/database"
+)
+
+// stateCollection is where single persisted values are stored (see database.GetState)
+const stateCollection = "discord_state"
+
+// addAlarmSubscription subscribes a Discord user to an alarm (by name)
+func addAlarmSubscription(database *database.Database, name string, userID string) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	subscribers, err := loadAlarmSubscribers(database, name)
+	if err != nil {
+		return err
+	}
+	for _, subscriber := range subscribers {
+		if subscriber == userID {
+			return nil
+		}
+	}
+
+	_, err = database.Set("alarm_subscriptions", 0, map[string]interface{}{"Key": strings.ToLower(name), "UserID": userID})
+	return err
+}
+
+// removeAlarmSubscription unsubscribes a Discord user from an alarm (by name)
+func removeAlarmSubscription(database *database.Database, name string, userID string) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	matches, err := database.Query("alarm_subscriptions", `[{"eq": `+strconv.Quote(strings.ToLower(name))+`, "in": ["Key"]}]`)
+	if err != nil {
+		return err
+	}
+	for objectID, subscription := range matches {
+		if subscription["UserID"] == userID {
+			if err := database.Delete("alarm_subscriptions", objectID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadAlarmSubscribers returns the Discord user IDs subscribed to an alarm (by name)
+func loadAlarmSubscribers(database *database.Database, name string) ([]string, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	matches, err := database.Query("alarm_subscriptions", `[{"eq": `+strconv.Quote(strings.ToLower(name))+`, "in": ["Key"]}]`)
+	if err != nil {
+		return nil, err
+	}
+	subscribers := make([]string, 0)
+	for _, subscription := range matches {
+		if userID, ok := subscription["UserID"].(string); ok {
+			subscribers = append(subscribers, userID)
+		}
+	}
+
+	return subscribers, nil
+}
+
+func saveShopAlert(database *database.Database, alert *shopAlert) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	objectID, err := database.Set("shop_alerts", alert.ObjectID, map[string]interface{}{
+		"UserID":     alert.UserID,
+		"ItemID":     alert.ItemID,
+		"Blueprint":  alert.Blueprint,
+		"CurrencyID": alert.CurrencyID,
+		"MaxPrice":   alert.MaxPrice,
+	})
+	if err != nil {
+		return err
+	}
+	alert.ObjectID = objectID
+
+	return nil
+}
+
+// loadShopAlerts returns every user's price alerts
+func loadShopAlerts(database *database.Database) ([]*shopAlert, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	objects, err := database.Query("shop_alerts", `"all"`)
+	if err != nil {
+		return nil, err
+	}
+	alerts := make([]*shopAlert, 0, len(objects))
+	for objectID, object := range objects {
+		alert := &shopAlert{ObjectID: objectID}
+		alert.UserID, _ = object["UserID"].(string)
+		itemID, _ := object["ItemID"].(float64)
+		alert.ItemID = int32(itemID)
+		alert.Blueprint, _ = object["Blueprint"].(bool)
+		currencyID, _ := object["CurrencyID"].(float64)
+		alert.CurrencyID = int32(currencyID)
+		alert.MaxPrice, _ = object["MaxPrice"].(float64)
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].ObjectID < alerts[j].ObjectID
+	})
+	return alerts, nil
+}
@@ -0,0 +1,112 @@
+package discord
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Custom Discord emoji (eg. "<:rust:123456789012345678>" or "<a:dance:123456789012345678>")
+var customEmojiRegex = regexp.MustCompile(`<a?:(\w+):([0-9]+)>`)
+
+// Shortcodes typed in the game (eg. ":fire:"), allowing for underscores that were already escaped for Markdown
+var emojiShortcodeRegex = regexp.MustCompile(`:((?:\\_|[A-Za-z0-9_+-])+):`)
+
+// Valid names for emoji aliases
+var emojiAliasRegex = regexp.MustCompile(`^[A-Za-z0-9_+-]+$`)
+
+// Variation selectors and skin tone modifiers only change how an emoji looks, so they're ignored when translating emoji to the game
+var emojiModifiers = strings.NewReplacer("\uFE0F", "", "\U0001F3FB", "", "\U0001F3FC", "", "\U0001F3FD", "", "\U0001F3FE", "", "\U0001F3FF", "")
+
+// emojiMapper translates emoji between Discord and the game, which can only show them as ":shortcode:" text
+type emojiMapper struct {
+	emoji         map[string]string // Discord emoji (unicode or custom) by shortcode
+	shortcodes    map[string]string // Shortcodes by unicode emoji (without modifiers)
+	customAliases map[string]string // Shortcodes by custom emoji ID
+	replacer      *strings.Replacer // Replaces unicode emoji with their shortcodes
+}
+
+// newEmojiMapper creates an emoji mapper using the bundled shortcode table and the aliases of the guild
+// (eg. "rust=<:rustlogo:123456789012345678>,gg=🎉"), which take precedence over the bundled shortcodes in both directions
+func newEmojiMapper(aliases string) (*emojiMapper, error) {
+	mapper := &emojiMapper{emoji: make(map[string]string), shortcodes: make(map[string]string), customAliases: make(map[string]string)}
+	for shortcode, emoji := range bundledEmoji {
+		mapper.emoji[shortcode] = emoji
+		mapper.shortcodes[emojiModifiers.Replace(emoji)] = shortcode
+	}
+	for alias, shortcode := range bundledEmojiAliases {
+		mapper.emoji[alias] = bundledEmoji[shortcode]
+	}
+
+	if len(strings.TrimSpace(aliases)) > 0 {
+		for _, field := range strings.Split(aliases, ",") {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				return nil, errors.New("expected alias=emoji: " + field)
+			}
+			alias := strings.ToLower(strings.Trim(strings.TrimSpace(parts[0]), ":"))
+			emoji := strings.TrimSpace(parts[1])
+			if !emojiAliasRegex.MatchString(alias) {
+				return nil, errors.New("alias may only contain letters, numbers, underscores, plus and minus signs: " + field)
+			}
+			if matches := customEmojiRegex.FindStringSubmatch(emoji); len(matches) > 0 && matches[0] == emoji {
+				mapper.customAliases[matches[2]] = alias
+			} else if isUnicodeEmoji(emoji) {
+				mapper.shortcodes[emojiModifiers.Replace(emoji)] = alias
+			} else {
+				return nil, errors.New("emoji must be a custom emoji (<:name:id>) or a unicode emoji: " + field)
+			}
+			mapper.emoji[alias] = emoji
+		}
+	}
+
+	// Longer emoji go first, so that sequences (like flags) aren't replaced one part at a time
+	emoji := make([]string, 0, len(mapper.shortcodes))
+	for key := range mapper.shortcodes {
+		emoji = append(emoji, key)
+	}
+	sort.Slice(emoji, func(i, j int) bool {
+		if len(emoji[i]) != len(emoji[j]) {
+			return len(emoji[i]) > len(emoji[j])
+		}
+		return emoji[i] < emoji[j]
+	})
+	pairs := make([]string, 0, len(emoji)*2)
+	for _, key := range emoji {
+		pairs = append(pairs, key, ":"+mapper.shortcodes[key]+":")
+	}
+	mapper.replacer = strings.NewReplacer(pairs...)
+
+	return mapper, nil
+}
+
+// isUnicodeEmoji reports whether an alias points to something that looks like a unicode emoji rather than plain text
+// (which would otherwise replace parts of ordinary messages)
+func isUnicodeEmoji(emoji string) bool {
+	return !strings.ContainsAny(emoji, "<>: ") && strings.IndexFunc(emoji, func(r rune) bool { return r > unicode.MaxASCII }) >= 0
+}
+
+// toGame replaces unicode and custom emoji with shortcodes, custom emoji without an alias using their own name
+func (mapper *emojiMapper) toGame(content string) string {
+	content = customEmojiRegex.ReplaceAllStringFunc(content, func(match string) string {
+		matches := customEmojiRegex.FindStringSubmatch(match)
+		if shortcode, ok := mapper.customAliases[matches[2]]; ok {
+			return ":" + shortcode + ":"
+		}
+		return ":" + matches[1] + ":"
+	})
+	return mapper.replacer.Replace(emojiModifiers.Replace(content))
+}
+
+// toDiscord replaces known shortcodes with Discord emoji, leaving anything else as it is
+func (mapper *emojiMapper) toDiscord(content string) string {
+	return emojiShortcodeRegex.ReplaceAllStringFunc(content, func(match string) string {
+		shortcode := strings.ToLower(strings.ReplaceAll(match[1:len(match)-1], `\_`, "_"))
+		if emoji, ok := mapper.emoji[shortcode]; ok {
+			return emoji
+		}
+		return match
+	})
+}
@@ -0,0 +1,270 @@
+package discord
+
+// bundledEmoji is the shortcode table used for translating emoji (mostly the same names as Discord uses),
+// covering the emoji that are commonly used in chat along with the ones that make sense in Rust
+var bundledEmoji = map[string]string{
+	"grinning":                     "😀",
+	"smiley":                       "😃",
+	"smile":                        "😄",
+	"grin":                         "😁",
+	"laughing":                     "😆",
+	"sweat_smile":                  "😅",
+	"joy":                          "😂",
+	"rofl":                         "🤣",
+	"slight_smile":                 "🙂",
+	"upside_down":                  "🙃",
+	"wink":                         "😉",
+	"blush":                        "😊",
+	"innocent":                     "😇",
+	"heart_eyes":                   "😍",
+	"kissing_heart":                "😘",
+	"yum":                          "😋",
+	"stuck_out_tongue":             "😛",
+	"stuck_out_tongue_winking_eye": "😜",
+	"zany_face":                    "🤪",
+	"money_mouth":                  "🤑",
+	"hugging":                      "🤗",
+	"thinking":                     "🤔",
+	"zipper_mouth":                 "🤐",
+	"neutral_face":                 "😐",
+	"expressionless":               "😑",
+	"no_mouth":                     "😶",
+	"smirk":                        "😏",
+	"unamused":                     "😒",
+	"rolling_eyes":                 "🙄",
+	"grimacing":                    "😬",
+	"lying_face":                   "🤥",
+	"relieved":                     "😌",
+	"pensive":                      "😔",
+	"sleepy":                       "😪",
+	"drooling_face":                "🤤",
+	"sleeping":                     "😴",
+	"mask":                         "😷",
+	"nauseated_face":               "🤢",
+	"face_vomiting":                "🤮",
+	"sneezing_face":                "🤧",
+	"hot_face":                     "🥵",
+	"cold_face":                    "🥶",
+	"dizzy_face":                   "😵",
+	"exploding_head":               "🤯",
+	"cowboy":                       "🤠",
+	"partying_face":                "🥳",
+	"sunglasses":                   "😎",
+	"nerd":                         "🤓",
+	"confused":                     "😕",
+	"worried":                      "😟",
+	"slight_frown":                 "🙁",
+	"open_mouth":                   "😮",
+	"hushed":                       "😯",
+	"astonished":                   "😲",
+	"flushed":                      "😳",
+	"pleading_face":                "🥺",
+	"fearful":                      "😨",
+	"cold_sweat":                   "😰",
+	"cry":                          "😢",
+	"sob":                          "😭",
+	"scream":                       "😱",
+	"confounded":                   "😖",
+	"persevere":                    "😣",
+	"disappointed":                 "😞",
+	"sweat":                        "😓",
+	"weary":                        "😩",
+	"tired_face":                   "😫",
+	"yawning_face":                 "🥱",
+	"triumph":                      "😤",
+	"rage":                         "😡",
+	"angry":                        "😠",
+	"face_with_symbols_over_mouth": "🤬",
+	"smiling_imp":                  "😈",
+	"imp":                          "👿",
+	"skull":                        "💀",
+	"poop":                         "💩",
+	"clown":                        "🤡",
+	"ghost":                        "👻",
+	"alien":                        "👽",
+	"robot":                        "🤖",
+	"see_no_evil":                  "🙈",
+	"hear_no_evil":                 "🙉",
+	"speak_no_evil":                "🙊",
+	"wave":                         "👋",
+	"raised_hand":                  "✋",
+	"ok_hand":                      "👌",
+	"pinched_fingers":              "🤌",
+	"v":                            "✌️",
+	"crossed_fingers":              "🤞",
+	"metal":                        "🤘",
+	"call_me":                      "🤙",
+	"point_left":                   "👈",
+	"point_right":                  "👉",
+	"point_up":                     "☝️",
+	"point_down":                   "👇",
+	"middle_finger":                "🖕",
+	"thumbsup":                     "👍",
+	"thumbsdown":                   "👎",
+	"fist":                         "✊",
+	"punch":                        "👊",
+	"clap":                         "👏",
+	"raised_hands":                 "🙌",
+	"open_hands":                   "👐",
+	"handshake":                    "🤝",
+	"pray":                         "🙏",
+	"muscle":                       "💪",
+	"eyes":                         "👀",
+	"brain":                        "🧠",
+	"shrug":                        "🤷",
+	"facepalm":                     "🤦",
+	"heart":                        "❤️",
+	"orange_heart":                 "🧡",
+	"yellow_heart":                 "💛",
+	"green_heart":                  "💚",
+	"blue_heart":                   "💙",
+	"purple_heart":                 "💜",
+	"black_heart":                  "🖤",
+	"broken_heart":                 "💔",
+	"sparkling_heart":              "💖",
+	"100":                          "💯",
+	"anger":                        "💢",
+	"boom":                         "💥",
+	"dizzy":                        "💫",
+	"sweat_drops":                  "💦",
+	"zzz":                          "💤",
+	"speech_balloon":               "💬",
+	"fire":                         "🔥",
+	"sparkles":                     "✨",
+	"star":                         "⭐️",
+	"zap":                          "⚡",
+	"snowflake":                    "❄️",
+	"sunny":                        "☀️",
+	"cloud":                        "☁️",
+	"umbrella":                     "☔",
+	"rainbow":                      "🌈",
+	"crescent_moon":                "🌙",
+	"droplet":                      "💧",
+	"ocean":                        "🌊",
+	"evergreen_tree":               "🌲",
+	"deciduous_tree":               "🌳",
+	"cactus":                       "🌵",
+	"mushroom":                     "🍄",
+	"rock":                         "🪨",
+	"wood":                         "🪵",
+	"bear":                         "🐻",
+	"wolf":                         "🐺",
+	"boar":                         "🐗",
+	"horse":                        "🐴",
+	"chicken":                      "🐔",
+	"shark":                        "🦈",
+	"fish":                         "🐟",
+	"snake":                        "🐍",
+	"spider":                       "🕷",
+	"crab":                         "🦀",
+	"rat":                          "🐀",
+	"pig":                          "🐷",
+	"cow":                          "🐮",
+	"dog":                          "🐶",
+	"cat":                          "🐱",
+	"apple":                        "🍎",
+	"corn":                         "🌽",
+	"potato":                       "🥔",
+	"pumpkin":                      "🎃",
+	"meat_on_bone":                 "🍖",
+	"cut_of_meat":                  "🥩",
+	"pizza":                        "🍕",
+	"hamburger":                    "🍔",
+	"beer":                         "🍺",
+	"beers":                        "🍻",
+	"coffee":                       "☕",
+	"water":                        "🚰",
+	"gun":                          "🔫",
+	"bow_and_arrow":                "🏹",
+	"crossed_swords":               "⚔️",
+	"dagger":                       "🗡️",
+	"axe":                          "🪓",
+	"pick":                         "⛏️",
+	"hammer":                       "🔨",
+	"wrench":                       "🔧",
+	"tools":                        "🛠",
+	"shield":                       "🛡️",
+	"bomb":                         "💣",
+	"firecracker":                  "🧨",
+	"lock":                         "🔒",
+	"unlock":                       "🔓",
+	"key":                          "🔑",
+	"door":                         "🚪",
+	"house":                        "🏠",
+	"house_abandoned":              "🏚",
+	"tent":                         "⛺️",
+	"moneybag":                     "💰",
+	"gem":                          "💎",
+	"package":                      "📦",
+	"gift":                         "🎁",
+	"battery":                      "🔋",
+	"bulb":                         "💡",
+	"radio":                        "📻",
+	"satellite":                    "📡",
+	"camera":                       "📷",
+	"map":                          "🗺️",
+	"compass":                      "🧭",
+	"hourglass":                    "⌛",
+	"alarm_clock":                  "⏰",
+	"bell":                         "🔔",
+	"loudspeaker":                  "📢",
+	"rotating_light":               "🚨",
+	"warning":                      "⚠️",
+	"no_entry":                     "⛔",
+	"radioactive":                  "☢️",
+	"biohazard":                    "☣️",
+	"helicopter":                   "🚁",
+	"airplane":                     "✈️",
+	"rocket":                       "🚀",
+	"ship":                         "🚢",
+	"boat":                         "⛵",
+	"red_car":                      "🚗",
+	"train":                        "🚂",
+	"tractor":                      "🚜",
+	"parachute":                    "🪂",
+	"tada":                         "🎉",
+	"trophy":                       "🏆",
+	"medal":                        "🏅",
+	"crown":                        "👑",
+	"dart":                         "🎯",
+	"video_game":                   "🎮",
+	"game_die":                     "🎲",
+	"spades":                       "♠️",
+	"black_joker":                  "🃏",
+	"musical_note":                 "🎵",
+	"white_check_mark":             "✅",
+	"x":                            "❌",
+	"question":                     "❓",
+	"exclamation":                  "❗",
+	"heavy_plus_sign":              "➕",
+	"heavy_minus_sign":             "➖",
+	"arrow_up":                     "⬆️",
+	"arrow_down":                   "⬇️",
+	"arrow_left":                   "⬅️",
+	"arrow_right":                  "➡️",
+	"recycle":                      "♻️",
+	"skull_crossbones":             "☠️",
+	"red_circle":                   "🔴",
+	"green_circle":                 "🟢",
+}
+
+// bundledEmojiAliases are alternative shortcodes, which are only used for emoji typed in the game
+var bundledEmojiAliases = map[string]string{
+	"+1":                            "thumbsup",
+	"-1":                            "thumbsdown",
+	"thumbs_up":                     "thumbsup",
+	"thumbs_down":                   "thumbsdown",
+	"red_heart":                     "heart",
+	"hankey":                        "poop",
+	"slightly_smiling_face":         "slight_smile",
+	"slightly_frowning_face":        "slight_frown",
+	"satisfied":                     "laughing",
+	"thinking_face":                 "thinking",
+	"rolling_on_the_floor_laughing": "rofl",
+	"fingers_crossed":               "crossed_fingers",
+	"sign_of_the_horns":             "metal",
+	"face_palm":                     "facepalm",
+	"raised_back_of_hand":           "raised_hand",
+	"hand":                          "raised_hand",
+	"car":                           "red_car",
+}
@@ -0,0 +1,30 @@
+package discord
+
+import (
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Raw Discord mentions that would otherwise show up as IDs in the game
+var userMentionRegex = regexp.MustCompile(`<@!?[0-9]+>`)
+var roleMentionRegex = regexp.MustCompile(`<@&[0-9]+>`)
+var channelMentionRegex = regexp.MustCompile(`<#[0-9]+>`)
+
+// formatGameMessage returns the content of a Discord message as readable text for the game,
+// turning mentions into "@name" and emoji into ":shortcode:"
+func (discord *Discord) formatGameMessage(session *discordgo.Session, message *discordgo.Message) string {
+	content, err := message.ContentWithMoreMentionsReplaced(session)
+	if err != nil {
+		content = message.ContentWithMentionsReplaced()
+	}
+	return discord.emoji.toGame(replaceRawMentions(content))
+}
+
+// replaceRawMentions replaces any mentions that couldn't be resolved to a name (eg. roles that aren't mentionable)
+func replaceRawMentions(content string) string {
+	content = userMentionRegex.ReplaceAllString(content, "@user")
+	content = roleMentionRegex.ReplaceAllString(content, "@role")
+	content = channelMentionRegex.ReplaceAllString(content, "#channel")
+	return content
+}
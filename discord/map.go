@@ -0,0 +1,125 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// defaultMapInterval is how often the pinned map message is updated, unless configured otherwise
+	defaultMapInterval = 5 * time.Minute
+
+	// mapMessageKey is the state key for the ID of the pinned map message
+	mapMessageKey = "MapMessageID"
+)
+
+// addMapCommands adds the slash command for rendering the server map
+func (discord *Discord) addMapCommands() {
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "map",
+			Description: "Show the server map with live markers",
+		},
+		handler: discord.handleMapCommand,
+	})
+}
+
+func (discord *Discord) handleMapCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	data, err := discord.RustPlus.RenderMap(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &discordgo.WebhookEdit{Files: []*discordgo.File{mapFile(data)}}, nil
+}
+
+// startMapUpdates keeps a pinned map message up to date in the map channel (if one is configured)
+func (discord *Discord) startMapUpdates() {
+	channelID := os.Getenv("DISCORD_MAP_CHANNEL_ID")
+	if len(channelID) <= 0 {
+		return
+	}
+	interval := defaultMapInterval
+	if seconds, err := strconv.Atoi(os.Getenv("DISCORD_MAP_INTERVAL")); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	discord.logger.Trace("Creating Discord map ticker..")
+	ticker := time.NewTicker(interval)
+	defer func() {
+		discord.logger.Trace("Stopping Discord map ticker..")
+		ticker.Stop()
+	}()
+
+	for {
+		select {
+		case <-discord.stop:
+			return
+		case <-ticker.C:
+			if err := discord.updateMapMessage(channelID); err != nil {
+				discord.logger.Warning("Failed to update map message:", err)
+			}
+		}
+	}
+}
+
+// updateMapMessage renders the map and replaces the image of the pinned map message, creating and pinning it if necessary
+func (discord *Discord) updateMapMessage(channelID string) error {
+	data, err := discord.RustPlus.RenderMap(context.Background())
+	if err != nil {
+		return err
+	}
+	content := "🗺️ Updated <t:" + strconv.FormatInt(time.Now().Unix(), 10) + ":R>"
+
+	// Edit the existing message, unless it has been deleted
+	messageID, err := discord.Database.GetState(stateCollection, mapMessageKey)
+	if err != nil {
+		return err
+	}
+	if id, ok := messageID.(string); ok && len(id) > 0 {
+		err := discord.editMessageFiles(channelID, id, content, []*discordgo.File{mapFile(data)})
+		var restErr *discordgo.RESTError
+		if err == nil || !errors.As(err, &restErr) || restErr.Response == nil || restErr.Response.StatusCode != http.StatusNotFound {
+			return err
+		}
+		discord.logger.Info("Map message was deleted, sending a new one")
+	}
+
+	message, err := discord.Client.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content, Files: []*discordgo.File{mapFile(data)}})
+	if err != nil {
+		return err
+	}
+	if err := discord.Client.ChannelMessagePin(channelID, message.ID); err != nil {
+		discord.logger.Warning("Failed to pin map message:", err)
+	}
+	return discord.Database.SetState(stateCollection, mapMessageKey, message.ID)
+}
+
+// editMessageFiles replaces the content and attachments of a message (discordgo doesn't support editing attachments)
+func (discord *Discord) editMessageFiles(channelID string, messageID string, content string, files []*discordgo.File) error {
+	payload := map[string]interface{}{
+		"content": content,
+
+		// Only the attachments listed here are kept, so the new files replace any existing ones
+		"attachments": []interface{}{},
+	}
+	contentType, body, err := discordgo.MultipartBodyWithJSON(payload, files)
+	if err != nil {
+		return err
+	}
+
+	endpoint := discordgo.EndpointChannelMessage(channelID, messageID)
+	bucket := discord.Client.Ratelimiter.LockBucket(discordgo.EndpointChannelMessage(channelID, ""))
+	_, err = discord.Client.RequestWithLockedBucket("PATCH", endpoint, contentType, body, bucket, 0)
+	return err
+}
+
+func mapFile(data []byte) *discordgo.File {
+	return &discordgo.File{Name: "map.png", ContentType: "image/png", Reader: bytes.NewReader(data)}
+}
@@ -12,6 +12,17 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
+// consoleEventIcons are the icons for each type of console event, which also determines which messages are console events
+// (server saves happen every few minutes, so they're left out)
+var consoleEventIcons = map[eventhandler.MessageType]string{
+	eventhandler.KickType:    "👢",
+	eventhandler.EACKickType: "🛡",
+	eventhandler.BanType:     "🔨",
+	eventhandler.ReportType:  "🚩",
+	eventhandler.StartupType: "✅",
+	eventhandler.AirdropType: "🪂",
+}
+
 func (discord *Discord) handleMessageCreate(session *discordgo.Session, message *discordgo.MessageCreate) {
 	// Ignore all messages created by the bot itself
 	if message.Author.ID == session.State.User.ID {
@@ -25,14 +36,26 @@ func (discord *Discord) handleMessageCreate(session *discordgo.Session, message
 		return
 	}
 
-	// Only process messages from specific channels
-	if channel.ID != os.Getenv("DISCORD_CHAT_CHANNEL_ID") {
+	// Both the team chat and the server chat show mentions and custom emoji as raw IDs, so make them readable first
+	content := discord.formatGameMessage(session, message.Message)
+
+	// Relay messages from the team chat channel to the Rust+ team chat
+	if len(os.Getenv("DISCORD_TEAMCHAT_CHANNEL_ID")) > 0 && channel.ID == os.Getenv("DISCORD_TEAMCHAT_CHANNEL_ID") {
+		discord.EventHandler.Emit(eventhandler.Message{Event: "receive_discord_team_message", User: message.Author.Username, Message: content})
+		return
+	}
+
+	// Only process messages from the chat channels of our servers
+	servers := discord.serversForChannel(channel.ID)
+	if len(servers) <= 0 {
 		discord.logger.Trace("Ignoring message from channel:", "#"+channel.Name)
 		return
 	}
 
-	// Relay the message to our message handler, which will eventually send it to the Webrcon client
-	discord.EventHandler.Emit(eventhandler.Message{Event: "receive_discord_message", User: message.Author.Username, Message: message.Content})
+	// Relay the message to our message handler, which will eventually send it to the Webrcon client of each server using this channel
+	for _, serverID := range servers {
+		discord.EventHandler.Emit(eventhandler.Message{Event: "receive_discord_message", User: message.Author.Username, Message: content, Server: serverID})
+	}
 }
 
 func (discord *Discord) handleIncomingLoggerMessage(message eventhandler.Message) {
@@ -96,40 +119,7 @@ func (discord *Discord) handleIncomingWebrconMessage(message eventhandler.Messag
 	discord.logger.Trace("handleIncomingWebrconMessage:", message)
 
 	// Format any potential mentions
-	mentionRegexMatches := mentionRegex.FindAllStringSubmatch(message.Message, -1)
-	if len(mentionRegexMatches) > 0 {
-		// Get the bot channel
-		if botChannel, botChannelErr := discord.Client.Channel(os.Getenv("DISCORD_CHAT_CHANNEL_ID")); botChannelErr != nil {
-			discord.logger.Warning("Failed to find bot channel:", botChannelErr)
-		} else {
-			// Get the bot guild from the channel
-			if botGuild, botGuildErr := discord.Client.Guild(botChannel.GuildID); botGuildErr != nil {
-				discord.logger.Warning("Failed to find bot guild:", botGuildErr)
-			} else {
-				for _, match := range mentionRegexMatches {
-
-					mentionUsername := strings.ToUpper(match[1])
-
-					// Loop through each user in the guild
-					for _, member := range botGuild.Members {
-						username := strings.ToUpper(member.Nick)
-						if username == "" {
-							username = strings.ToUpper(member.User.Username)
-						}
-
-						if username == mentionUsername {
-							replacer := newCaseInsensitiveReplacer(`@`+username, `<@!`+member.User.ID+`>`)
-							formattedMentionMessage := replacer.Replace(message.Message)
-							// discord.logger.Trace("Formatted mention message:", formattedMentionMessage)
-							message.Message = formattedMentionMessage
-
-							break
-						}
-					}
-				}
-			}
-		}
-	}
+	message.Message = discord.formatMentions(message.Server, message.Message)
 
 	// TODO: Also replace the word "ylläpitäjä", "admin" and "admini" with "@Dids", so I get pinged? This should be configurable though..
 
@@ -142,10 +132,12 @@ func (discord *Discord) handleIncomingWebrconMessage(message eventhandler.Messag
 	if message.Type == eventhandler.StatusType {
 		// Update presence
 		discord.logger.Trace("Received status message, updating presence:", message.Message)
-		if err := discord.updateNickname(truncateString(message.User, 32)); err != nil {
-			discord.logger.Error("Failed to update nickname:", err)
+		if discord.isPrimaryServer(message.Server) {
+			if err := discord.updateNickname(truncateString(message.User, 32)); err != nil {
+				discord.logger.Error("Failed to update nickname:", err)
+			}
 		}
-		if err := discord.updatePresence(message.Message); err != nil {
+		if err := discord.updateStatusPresence(discord.serverStatus(message.Server, message.Message), ""); err != nil {
 			discord.logger.Error("Failed to update presence:", err)
 		}
 		return
@@ -157,54 +149,66 @@ func (discord *Discord) handleIncomingWebrconMessage(message eventhandler.Messag
 		if err := json.Unmarshal([]byte(message.Message), &parsedPlayers); err != nil {
 			discord.logger.Error("Failed to parse players:", err)
 		}
-		if err := discord.updatePlayers(parsedPlayers); err != nil {
+		if err := discord.updatePlayers(message.Server, parsedPlayers); err != nil {
 			discord.logger.Error("Failed to update players:", err)
 		}
 		return
 	} else if message.Type == eventhandler.ServerConnectedType || message.Type == eventhandler.ServerDisconnectedType {
-		if _, err := discord.Client.ChannelMessageSend(os.Getenv("DISCORD_NOTIFICATIONS_CHANNEL_ID"), "`"+message.Message+"`"); err != nil {
+		channelID := serverChannel(message.Server, "DISCORD_NOTIFICATIONS_CHANNEL_ID")
+		prefix := discord.serverPrefix(message.Server, "DISCORD_NOTIFICATIONS_CHANNEL_ID")
+		if _, err := discord.Client.ChannelMessageSend(channelID, prefix+"`"+message.Message+"`"); err != nil {
 			discord.logger.Error("Failed to send message:", message, "with error:", err)
 		}
 		return
 	} else if message.Type == eventhandler.PvPKillType || message.Type == eventhandler.OtherKillType {
 		// Ignore PvP deaths if disabled
-		if os.Getenv("DISCORD_KILLFEED_PVP_ENABLED") != "true" && message.Type == eventhandler.PvPKillType {
-			discord.logger.Trace("Ignoring PvP kill, feed is disabled", os.Getenv("DISCORD_KILLFEED_PVP_ENABLED"))
+		if serverChannel(message.Server, "DISCORD_KILLFEED_PVP_ENABLED") != "true" && message.Type == eventhandler.PvPKillType {
+			discord.logger.Trace("Ignoring PvP kill, feed is disabled", serverChannel(message.Server, "DISCORD_KILLFEED_PVP_ENABLED"))
 			return
 		}
 
 		// Ignore Other deaths if disabled
-		if os.Getenv("DISCORD_KILLFEED_OTHER_ENABLED") != "true" && message.Type == eventhandler.OtherKillType {
-			discord.logger.Trace("Ignoring other kill, feed is disabled", os.Getenv("DISCORD_KILLFEED_OTHER_ENABLED"))
+		if serverChannel(message.Server, "DISCORD_KILLFEED_OTHER_ENABLED") != "true" && message.Type == eventhandler.OtherKillType {
+			discord.logger.Trace("Ignoring other kill, feed is disabled", serverChannel(message.Server, "DISCORD_KILLFEED_OTHER_ENABLED"))
 			return
 		}
 
-		// Send deaths to the main channel by default
-		channelID := os.Getenv("DISCORD_CHAT_CHANNEL_ID")
-
-		// If the "kill feed" channel is set, override the channel
-		if len(os.Getenv("DISCORD_KILLFEED_CHANNEL_ID")) > 0 {
-			channelID = os.Getenv("DISCORD_KILLFEED_CHANNEL_ID")
-		}
-
-		if _, err := discord.Client.ChannelMessageSend(channelID, "_"+message.Message+"_"); err != nil {
+		// Send deaths to the "kill feed" channel, or to the main channel by default
+		channelID, name := serverChannelOrChat(message.Server, "DISCORD_KILLFEED_CHANNEL_ID")
+		if _, err := discord.Client.ChannelMessageSend(channelID, discord.serverPrefix(message.Server, name)+delayedPrefix(message)+"_"+message.Message+"_"); err != nil {
 			discord.logger.Error("Failed to send message:", message, "with error:", err)
 		}
 
 		return
 	} else if message.Type == eventhandler.JoinType || message.Type == eventhandler.DisconnectType {
-		// Send join/leave messages to the main channel by default
-		channelID := os.Getenv("DISCORD_CHAT_CHANNEL_ID")
-
-		// If the "notifications" channel is set, override the channel
-		if len(os.Getenv("DISCORD_NOTIFICATIONS_CHANNEL_ID")) > 0 {
-			channelID = os.Getenv("DISCORD_NOTIFICATIONS_CHANNEL_ID")
+		// Send join/leave messages to the "notifications" channel, or to the main channel by default
+		channelID, name := serverChannelOrChat(message.Server, "DISCORD_NOTIFICATIONS_CHANNEL_ID")
+		if _, err := discord.Client.ChannelMessageSend(channelID, discord.serverPrefix(message.Server, name)+delayedPrefix(message)+"_"+message.User+" "+string(message.Message)+"_"); err != nil {
+			discord.logger.Error("Failed to send message:", message, "with error:", err)
 		}
 
-		if _, err := discord.Client.ChannelMessageSend(channelID, "_"+message.User+" "+string(message.Message)+"_"); err != nil {
+		return
+	} else if icon, ok := consoleEventIcons[message.Type]; ok {
+		// Send console events to the "notifications" channel, or to the main channel by default
+		channelID, name := serverChannelOrChat(message.Server, "DISCORD_NOTIFICATIONS_CHANNEL_ID")
+		if _, err := discord.Client.ChannelMessageSend(channelID, discord.serverPrefix(message.Server, name)+delayedPrefix(message)+icon+" _"+message.Message+"_"); err != nil {
 			discord.logger.Error("Failed to send message:", message, "with error:", err)
 		}
-
+		return
+	} else if message.Type == eventhandler.RuleType {
+		// Send messages of console rules to the channel of the rule, or to the "notifications" channel by default
+		// (rules files may be shared by several servers, so their channels are always treated as shared)
+		channelID, name := message.Channel, ""
+		if len(channelID) <= 0 {
+			channelID, name = serverChannelOrChat(message.Server, "DISCORD_NOTIFICATIONS_CHANNEL_ID")
+		}
+		prefix := discord.serverPrefix(message.Server, name)
+		if _, err := discord.Client.ChannelMessageSend(channelID, prefix+delayedPrefix(message)+message.Message); err != nil {
+			discord.logger.Error("Failed to send message for rule", message.Rule, "with error:", err)
+		}
+		return
+	} else if message.Type == eventhandler.SaveType {
+		discord.logger.Trace("Ignoring server save:", message.Message)
 		return
 	}
 
@@ -214,14 +218,77 @@ func (discord *Discord) handleIncomingWebrconMessage(message eventhandler.Messag
 		return
 	}
 
+	// Find where messages of this in-game chat channel go (see DISCORD_CHAT_ROUTES)
+	channelID, prefix := discord.chatRoute(message)
+	if len(channelID) <= 0 {
+		discord.logger.Trace("Ignoring message from", message.ChatChannel, "chat:", message)
+		return
+	}
+
 	// Format the message and send it to the specified channel
-	channelMessage := "" + message.User + ": " + message.Message + ""
+	channelMessage := prefix + delayedPrefix(message) + formatChatMessage(message, discord.emoji)
+	if _, err := discord.Client.ChannelMessageSend(channelID, channelMessage); err != nil {
+		discord.logger.Error("Failed to send message:", message, "with error:", err)
+	}
+}
+
+// formatChatMessage formats a chat (or join/leave) message for Discord, only translating the emoji of what was said
+// (so that usernames are never changed)
+func formatChatMessage(message eventhandler.Message, emoji *emojiMapper) string {
 	if message.Type == eventhandler.JoinType || message.Type == eventhandler.DisconnectType {
-		channelMessage = "_" + message.User + " " + string(message.Message) + "_"
+		return "_" + message.User + " " + message.Message + "_"
 	}
-	if _, err := discord.Client.ChannelMessageSend(os.Getenv("DISCORD_CHAT_CHANNEL_ID"), channelMessage); err != nil {
-		discord.logger.Error("Failed to send message:", message, "with error:", err)
+	return message.User + ": " + emoji.toDiscord(message.Message)
+}
+
+// formatMentions converts "@username" mentions to real Discord mentions, matching against nicknames and usernames of the guild of a server
+func (discord *Discord) formatMentions(serverID string, message string) string {
+	mentionRegexMatches := mentionRegex.FindAllStringSubmatch(message, -1)
+	if len(mentionRegexMatches) <= 0 {
+		return message
+	}
+
+	// Get the bot channel
+	botChannel, botChannelErr := discord.Client.Channel(serverChannel(serverID, "DISCORD_CHAT_CHANNEL_ID"))
+	if botChannelErr != nil {
+		discord.logger.Warning("Failed to find bot channel:", botChannelErr)
+		return message
+	}
+
+	// Get the bot guild from the channel
+	botGuild, botGuildErr := discord.Client.Guild(botChannel.GuildID)
+	if botGuildErr != nil {
+		discord.logger.Warning("Failed to find bot guild:", botGuildErr)
+		return message
+	}
+
+	for _, match := range mentionRegexMatches {
+		mentionUsername := strings.ToUpper(match[1])
+
+		// Loop through each user in the guild
+		for _, member := range botGuild.Members {
+			username := strings.ToUpper(member.Nick)
+			if username == "" {
+				username = strings.ToUpper(member.User.Username)
+			}
+
+			if username == mentionUsername {
+				replacer := newCaseInsensitiveReplacer(`@`+username, `<@!`+member.User.ID+`>`)
+				message = replacer.Replace(message)
+				break
+			}
+		}
+	}
+
+	return message
+}
+
+// delayedPrefix marks messages that were missed while the server was disconnected and are only relayed now
+func delayedPrefix(message eventhandler.Message) string {
+	if message.Delayed {
+		return "⏱ "
 	}
+	return ""
 }
 
 func truncateString(str string, num int) string {
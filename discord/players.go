@@ -2,7 +2,6 @@ package discord
 
 import (
 	"errors"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -12,7 +11,7 @@ import (
 	"github.com/jedib0t/go-pretty/table"
 )
 
-func (discord *Discord) updatePlayers(players []webrcon.PlayerPacket) error {
+func (discord *Discord) updatePlayers(serverID string, players []webrcon.PlayerPacket) error {
 	//discord.logger.Trace("Updating players:", players)
 
 	if !discord.IsReady {
@@ -20,13 +19,14 @@ func (discord *Discord) updatePlayers(players []webrcon.PlayerPacket) error {
 	}
 
 	// Skip if the the channel ID isn't set
-	if len(os.Getenv("DISCORD_PLAYERLIST_CHANNEL_ID")) <= 0 {
+	channelID := serverChannel(serverID, "DISCORD_PLAYERLIST_CHANNEL_ID")
+	if len(channelID) <= 0 {
 		//discord.logger.Trace("DISCORD_PLAYERLIST_CHANNEL_ID not set, skipping player list update")
 		return nil
 	}
 
 	// Get the player list channel
-	playersChannel, err := discord.Client.Channel(os.Getenv("DISCORD_PLAYERLIST_CHANNEL_ID"))
+	playersChannel, err := discord.Client.Channel(channelID)
 	if err != nil {
 		return err
 	}
@@ -51,7 +51,7 @@ func (discord *Discord) updatePlayers(players []webrcon.PlayerPacket) error {
 			playersTable.AppendRow([]interface{}{player.Username, player.Ping, humanize.Time(playerConnectedTime), player.Violations, player.Kicks})
 		}
 	}
-	playersMessage := "```\n"
+	playersMessage := discord.serverPrefix(serverID, "DISCORD_PLAYERLIST_CHANNEL_ID") + "```\n"
 	playersMessage += playersTable.Render()
 	playersMessage += "\n```"
 
@@ -1,12 +1,17 @@
 package discord
 
 import (
+	"errors"
 	"os"
 	"regexp"
+	"sync"
+	"time"
 
 	"github.com/Dids/rustbot/database"
 	"github.com/Dids/rustbot/eventhandler"
 	"github.com/Dids/rustbot/logger"
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/Dids/rustbot/webrcon"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -17,25 +22,64 @@ var escapeMarkdownRegex = regexp.MustCompile(`(\*|_|` + "`" + `|~|\\)`)
 
 // Discord is an abstraction around the Discord client
 type Discord struct {
-	Client                *discordgo.Session
-	EventHandler          *eventhandler.EventHandler
-	Database              *database.Database
-	WebrconMessageHandler chan eventhandler.Message
-	LoggerMessageHandler  chan eventhandler.Message
-	HasPresence           bool
-	IsReady               bool
+	Client                 *discordgo.Session
+	EventHandler           *eventhandler.EventHandler
+	Database               *database.Database
+	RustPlus               *rustplus.RustPlus
+	WebrconMessageHandler  chan eventhandler.Message
+	RustPlusMessageHandler chan eventhandler.Message
+	LoggerMessageHandler   chan eventhandler.Message
+	HasPresence            bool
+	IsReady                bool
 
 	// Private properties
-	logger *logger.Logger
+	logger         *logger.Logger
+	commands       map[string]*command
+	alarmCooldowns map[string]time.Time
+	alarmMutex     sync.Mutex
+	stop           chan struct{}
+	presence       string
+	clock          string
+	presenceMutex  sync.Mutex
+	servers        []string
+	statuses       map[string]string
+	chatRoutes     map[string]map[string]string
+	emoji          *emojiMapper
+	shopAlertsSeen map[int]map[string]bool // Listings each price alert has already seen, so that users are only notified about new listings
+	shopMutex      sync.Mutex
 }
 
-// NewDiscord creates and returns a new instance of Discord
-func NewDiscord(handler *eventhandler.EventHandler, db *database.Database) (*Discord, error) {
+// NewDiscord creates and returns a new instance of Discord (the Rust+ client is optional and may be nil)
+func NewDiscord(handler *eventhandler.EventHandler, db *database.Database, rustPlus *rustplus.RustPlus) (*Discord, error) {
 	discord := &Discord{}
 
 	// Store a reference to the Logger
 	discord.logger = logger.GetLogger()
 
+	// Find the servers that we relay messages for
+	servers, err := webrcon.ServerIDs()
+	if err != nil {
+		return nil, err
+	}
+	discord.servers = servers
+	discord.statuses = make(map[string]string)
+	discord.shopAlertsSeen = make(map[int]map[string]bool)
+
+	// Find where the in-game chat channels of each server go
+	discord.chatRoutes = make(map[string]map[string]string)
+	for _, serverID := range servers {
+		routes, err := parseChatRoutes(serverChannel(serverID, "DISCORD_CHAT_ROUTES"))
+		if err != nil {
+			return nil, errors.New("invalid DISCORD_CHAT_ROUTES: " + err.Error())
+		}
+		discord.chatRoutes[serverID] = routes
+	}
+
+	// Translate emoji between Discord and the game, including the custom emoji of the guild
+	if discord.emoji, err = newEmojiMapper(os.Getenv("DISCORD_EMOJI_ALIASES")); err != nil {
+		return nil, errors.New("invalid DISCORD_EMOJI_ALIASES: " + err.Error())
+	}
+
 	// Initialize the Discord client
 	if discordClient, discordClientErr := discordgo.New("Bot " + os.Getenv("DISCORD_BOT_TOKEN")); discordClientErr == nil {
 		discord.Client = discordClient
@@ -51,23 +95,57 @@ func NewDiscord(handler *eventhandler.EventHandler, db *database.Database) (*Dis
 	discord.Client.AddHandler(discord.handleRateLimit)
 	discord.Client.AddHandler(discord.handleReady)
 	discord.Client.AddHandler(discord.handleMessageCreate)
+	discord.Client.AddHandler(discord.handleInteractionCreate)
 
 	// Setup our custom event handlers
 	discord.WebrconMessageHandler = make(chan eventhandler.Message)
+	discord.RustPlusMessageHandler = make(chan eventhandler.Message)
 	discord.LoggerMessageHandler = make(chan eventhandler.Message)
 	discord.EventHandler = handler
 	discord.EventHandler.AddListener("receive_webrcon_message", discord.WebrconMessageHandler)
+	discord.EventHandler.AddListener("receive_rustplus_message", discord.RustPlusMessageHandler)
 	discord.EventHandler.AddListener("receive_logger_message", discord.LoggerMessageHandler)
 	go func() {
 		for {
-			// TODO: How does this actually work? Also do we need to stop this at some point? Do we need to use "select" etc. here?
-			discord.handleIncomingWebrconMessage(<-discord.WebrconMessageHandler)
-			discord.handleIncomingLoggerMessage(<-discord.LoggerMessageHandler) // TODO: Is this correct? This just runs in a loop and checks both, I guess?
+			// TODO: Do we need to stop this at some point?
+			select {
+			case message := <-discord.WebrconMessageHandler:
+				discord.handleIncomingWebrconMessage(message)
+			case message := <-discord.RustPlusMessageHandler:
+				discord.handleIncomingRustPlusMessage(message)
+			case message := <-discord.LoggerMessageHandler:
+				discord.handleIncomingLoggerMessage(message)
+			}
 		}
 	}()
 
-	// Store the database reference
+	// Store the database and Rust+ references
 	discord.Database = db
+	discord.RustPlus = rustPlus
+
+	// Make sure that our collections exist and have the required indexes
+	if discord.Database != nil && discord.Database.Client != nil {
+		for _, collection := range []string{stateCollection, "alarm_subscriptions"} {
+			result, err := discord.Database.GetCollection(collection)
+			if err != nil {
+				return nil, err
+			}
+			result.Index([]string{"Key"})
+		}
+	}
+
+	// Setup our slash commands
+	if discord.RustPlus != nil {
+		discord.addSwitchCommands()
+		discord.addAlarmCommands()
+		discord.addStorageCommands()
+		discord.addMapCommands()
+		discord.addShopCommands()
+		discord.addCredentialCommands()
+		discord.addTeamCommands()
+		discord.addClockCommands()
+		discord.addCameraCommands()
+	}
 
 	return discord, nil
 }
@@ -75,12 +153,27 @@ func NewDiscord(handler *eventhandler.EventHandler, db *database.Database) (*Dis
 // Open will start the Discord client and connect to the API
 func (discord *Discord) Open() error {
 	discord.logger.Info("Opening Discord..")
-	return discord.Client.Open()
+	if err := discord.Client.Open(); err != nil {
+		return err
+	}
+
+	// Start our background tasks (the pinned map message and price alerts)
+	if discord.RustPlus != nil {
+		discord.stop = make(chan struct{})
+		go discord.startMapUpdates()
+		go discord.startShopAlerts()
+	}
+
+	return nil
 }
 
 // Close will gracefully shutdown and cleanup the Discord client
 func (discord *Discord) Close() error {
 	discord.logger.Info("Closing Discord..")
 	discord.EventHandler.RemoveListener("receive_webrcon_message", discord.WebrconMessageHandler)
+	discord.EventHandler.RemoveListener("receive_rustplus_message", discord.RustPlusMessageHandler)
+	if discord.stop != nil {
+		close(discord.stop)
+	}
 	return discord.Client.Close()
 }
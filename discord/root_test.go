@@ -1,7 +1,225 @@
 package discord
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/rustplus"
+)
 
 func TestDummy(t *testing.T) {
 
 }
+
+func TestParseChatRoutes(t *testing.T) {
+	routes, err := parseChatRoutes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes["global"] != chatRouteChat {
+		t.Error("expected only the global chat to be relayed by default but got", routes)
+	}
+
+	routes, err = parseChatRoutes(" Team = 123456789012345678, server=TAG ,global=drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"global": chatRouteDrop, "team": "123456789012345678", "server": chatRouteTag}
+	for chatChannel, route := range expected {
+		if routes[chatChannel] != route {
+			t.Error("expected", chatChannel, "chat to go to", route, "but got", routes[chatChannel])
+		}
+	}
+
+	for _, value := range []string{"team", "whisper=chat", "team=admins", "team=-123"} {
+		if _, err := parseChatRoutes(value); err == nil {
+			t.Error("expected an error for", value)
+		}
+	}
+}
+
+func TestChatRoute(t *testing.T) {
+	t.Setenv("DISCORD_CHAT_CHANNEL_ID", "100")
+	routes, err := parseChatRoutes("team=200,server=tag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	discord := &Discord{servers: []string{""}, chatRoutes: map[string]map[string]string{"": routes}}
+
+	tests := []struct {
+		chatChannel string
+		channelID   string
+		prefix      string
+	}{
+		{"", "100", ""},
+		{"global", "100", ""},
+		{"team", "200", ""},
+		{"server", "100", "[Server] "},
+		{"cards", "", ""},
+		{"42", "", ""},
+	}
+	for _, test := range tests {
+		channelID, prefix := discord.chatRoute(eventhandler.Message{ChatChannel: test.chatChannel})
+		if channelID != test.channelID || prefix != test.prefix {
+			t.Error("expected", test.chatChannel, "chat to go to", test.channelID, "with", test.prefix, "but got", channelID, "with", prefix)
+		}
+	}
+}
+
+func TestReplaceRawMentions(t *testing.T) {
+	tests := map[string]string{
+		"Hi <@123456789012345678> and <@!42>": "Hi @user and @user",
+		"Ping <@&123456789012345678>":         "Ping @role",
+		"See <#123456789012345678>":           "See #channel",
+		"I <3 Rust":                           "I <3 Rust",
+	}
+	for input, expected := range tests {
+		if output := replaceRawMentions(input); output != expected {
+			t.Errorf("expected %q to become %q but got %q", input, expected, output)
+		}
+	}
+}
+
+func TestEmojiMapper(t *testing.T) {
+	mapper, err := newEmojiMapper("rust=<:rustlogo:123456789012345678>, :gg:=🎉")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toGame := map[string]string{
+		"Nice 👍":                                   "Nice :thumbsup:",
+		"Nice 👍🏽 ❤️ ❤":                             "Nice :thumbsup: :heart: :heart:",
+		"🔥🔥 raid":                                  ":fire::fire: raid",
+		"Hello <:pepe:223456789012345678>":         "Hello :pepe:",
+		"<a:dance:323456789012345678> party":       ":dance: party",
+		"Best game <:rustlogo:123456789012345678>": "Best game :rust:",
+		"Well played 🎉":                            "Well played :gg:",
+		"Unknown 🫠 stays":                          "Unknown 🫠 stays",
+	}
+	for input, expected := range toGame {
+		if output := mapper.toGame(input); output != expected {
+			t.Errorf("expected %q to become %q in the game but got %q", input, expected, output)
+		}
+	}
+
+	toDiscord := map[string]string{
+		"Nice :thumbsup: :+1: :THUMBSUP:": "Nice 👍 👍 👍",
+		`:heart\_eyes: :sweat_smile:`:     "😍 😅",
+		"I love :rust: :gg:":              "I love <:rustlogo:123456789012345678> 🎉",
+		"Meet at 12:30:45 :unknown:":      "Meet at 12:30:45 :unknown:",
+	}
+	for input, expected := range toDiscord {
+		if output := mapper.toDiscord(input); output != expected {
+			t.Errorf("expected %q to become %q in Discord but got %q", input, expected, output)
+		}
+	}
+
+	for _, value := range []string{"rust", "rust=", "rust=text", "rust=<:rustlogo>", "bad alias=🎉"} {
+		if _, err := newEmojiMapper(value); err == nil {
+			t.Error("expected an error for", value)
+		}
+	}
+}
+
+func TestFormatChatMessage(t *testing.T) {
+	mapper, err := newEmojiMapper("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the message itself is translated, never the username
+	for _, test := range []struct {
+		message  eventhandler.Message
+		expected string
+	}{
+		{eventhandler.Message{User: ":fire:Dids", Message: "gg :fire:"}, ":fire:Dids: gg 🔥"},
+		{eventhandler.Message{User: ":fire:Dids", Message: "joined", Type: eventhandler.JoinType}, "_:fire:Dids joined_"},
+		{eventhandler.Message{User: ":fire:Dids", Message: "left", Type: eventhandler.DisconnectType}, "_:fire:Dids left_"},
+	} {
+		if output := formatChatMessage(test.message, mapper); output != test.expected {
+			t.Errorf("expected %q but got %q", test.expected, output)
+		}
+	}
+}
+
+func TestBundledEmoji(t *testing.T) {
+	seen := make(map[string]string)
+	for shortcode, emoji := range bundledEmoji {
+		if !emojiAliasRegex.MatchString(shortcode) {
+			t.Error("invalid shortcode:", shortcode)
+		}
+		if other, ok := seen[emoji]; ok {
+			t.Error("emoji", emoji, "has both", shortcode, "and", other, "as its shortcode (use bundledEmojiAliases instead)")
+		}
+		seen[emoji] = shortcode
+	}
+	for alias, shortcode := range bundledEmojiAliases {
+		if _, ok := bundledEmoji[alias]; ok {
+			t.Error("alias", alias, "is also a shortcode")
+		}
+		if _, ok := bundledEmoji[shortcode]; !ok {
+			t.Error("alias", alias, "points to unknown shortcode", shortcode)
+		}
+	}
+}
+
+func TestShopAlertMatches(t *testing.T) {
+	alert := &shopAlert{ItemID: 1545779598, CurrencyID: scrapItemID, MaxPrice: 100}
+	listing := func(blueprint bool, quantity int32, cost int32, stock int32) *rustplus.ShopListing {
+		return &rustplus.ShopListing{ItemID: 1545779598, ItemIsBlueprint: blueprint, Quantity: quantity, CurrencyID: scrapItemID, CostPerItem: cost, AmountInStock: stock}
+	}
+
+	// Alerts are for the item itself by default, never its blueprint
+	if !alert.matches(listing(false, 1, 100, 1)) {
+		t.Error("expected the item to match")
+	}
+	if alert.matches(listing(true, 1, 50, 1)) {
+		t.Error("expected the blueprint not to match an alert for the item")
+	}
+	alert.Blueprint = true
+	if !alert.matches(listing(true, 1, 50, 1)) || alert.matches(listing(false, 1, 50, 1)) {
+		t.Error("expected only the blueprint to match an alert for the blueprint")
+	}
+
+	// The price is compared per unit, and only listings in stock match
+	if !alert.matches(listing(true, 2, 150, 1)) || alert.matches(listing(true, 1, 150, 1)) || alert.matches(listing(true, 1, 50, 0)) {
+		t.Error("expected only cheap enough listings in stock to match")
+	}
+	other := listing(true, 1, 50, 1)
+	other.CurrencyID = 0
+	if alert.matches(other) {
+		t.Error("expected listings in another currency not to match")
+	}
+}
+
+func TestUnseenShopListings(t *testing.T) {
+	discord := &Discord{shopAlertsSeen: make(map[int]map[string]bool)}
+	alert := &shopAlert{ObjectID: 1, ItemID: 1545779598, CurrencyID: scrapItemID, MaxPrice: 100}
+	cheap := &rustplus.ShopListing{MarkerID: 1, ItemID: 1545779598, Quantity: 1, CurrencyID: scrapItemID, CostPerItem: 50, AmountInStock: 1}
+	expensive := &rustplus.ShopListing{MarkerID: 2, ItemID: 1545779598, Quantity: 1, CurrencyID: scrapItemID, CostPerItem: 500, AmountInStock: 1}
+	other := &rustplus.ShopListing{MarkerID: 3, ItemID: 1545779598, Quantity: 1, CurrencyID: scrapItemID, CostPerItem: 80, AmountInStock: 1}
+
+	// Every matching listing is new to an alert that was never checked
+	if unseen, checked := discord.unseenShopListings(alert, []*rustplus.ShopListing{cheap, expensive}); checked || len(unseen) != 1 || unseen[0] != cheap {
+		t.Fatal("expected only the cheap listing to be new but got", unseen, checked)
+	}
+
+	// Listings are only new once, but become new again after they're gone for a while
+	if unseen, checked := discord.unseenShopListings(alert, []*rustplus.ShopListing{other, cheap}); !checked || len(unseen) != 1 || unseen[0] != other {
+		t.Fatal("expected only the other listing to be new but got", unseen, checked)
+	}
+	discord.unseenShopListings(alert, []*rustplus.ShopListing{other})
+	if unseen, _ := discord.unseenShopListings(alert, []*rustplus.ShopListing{cheap, other}); len(unseen) != 1 || unseen[0] != cheap {
+		t.Fatal("expected the cheap listing to be new again but got", unseen)
+	}
+
+	// Alerts that couldn't see any listings yet still count as checked, while removed ones are forgotten
+	discord.unseenShopListings(&shopAlert{ObjectID: 2}, nil)
+	discord.forgetShopAlerts([]*shopAlert{{ObjectID: 2}})
+	if _, ok := discord.shopAlertsSeen[1]; ok {
+		t.Error("expected the removed alert to be forgotten")
+	}
+	if _, checked := discord.unseenShopListings(&shopAlert{ObjectID: 2}, nil); !checked {
+		t.Error("expected the alert to count as checked")
+	}
+}
@@ -3,6 +3,7 @@ package discord
 import (
 	"errors"
 	"os"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -15,7 +16,7 @@ func (discord *Discord) updateNickname(nickname string) error {
 	// Set the nickname
 	if discord.Client != nil && discord.Client.DataReady && nickname != "" {
 		// Get the bot channel
-		botChannel, botChannelErr := discord.Client.Channel(os.Getenv("DISCORD_CHAT_CHANNEL_ID"))
+		botChannel, botChannelErr := discord.Client.Channel(serverChannel(discord.servers[0], "DISCORD_CHAT_CHANNEL_ID"))
 		if botChannelErr != nil {
 			return botChannelErr
 		}
@@ -66,3 +67,28 @@ func (discord *Discord) updatePresence(presence string) error {
 
 	return nil
 }
+
+// updateStatusPresence updates the presence with the latest server status and/or in-game clock (empty values are left unchanged),
+// only showing the clock if DISCORD_PRESENCE_CLOCK is enabled
+func (discord *Discord) updateStatusPresence(status string, clock string) error {
+	discord.presenceMutex.Lock()
+	if len(status) > 0 {
+		discord.presence = status
+	}
+	if len(clock) > 0 {
+		discord.clock = clock
+	}
+	parts := make([]string, 0)
+	if len(discord.presence) > 0 {
+		parts = append(parts, discord.presence)
+	}
+	if len(discord.clock) > 0 && os.Getenv("DISCORD_PRESENCE_CLOCK") == "true" {
+		parts = append(parts, "🕑 "+discord.clock)
+	}
+	discord.presenceMutex.Unlock()
+
+	if len(parts) <= 0 {
+		return nil
+	}
+	return discord.updatePresence(strings.Join(parts, " | "))
+}
@@ -0,0 +1,104 @@
+package discord
+
+import (
+	"os"
+	"strings"
+
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+// worldEventIcons are the icons for each type of world event, which also determines which messages are world events
+var worldEventIcons = map[eventhandler.MessageType]string{
+	eventhandler.CargoSpawnedType: "🚢",
+	eventhandler.CargoLeftType:    "🚢",
+	eventhandler.CH47SpawnedType:  "🚁",
+	eventhandler.CH47LeftType:     "🚁",
+	eventhandler.CH47OilRigType:   "🛢️",
+	eventhandler.CH47AirdropType:  "📦",
+	eventhandler.HeliSpawnedType:  "🚁",
+	eventhandler.HeliLeftType:     "🚁",
+	eventhandler.HeliDownType:     "💥",
+	eventhandler.CrateSpawnedType: "🔒",
+	eventhandler.CrateGoneType:    "🔓",
+	eventhandler.ExplosionType:    "💥",
+
+	eventhandler.NightfallSoonType: "🌆",
+	eventhandler.NightfallType:     "🌙",
+	eventhandler.SunriseType:       "🌅",
+}
+
+func (discord *Discord) handleIncomingRustPlusMessage(message eventhandler.Message) {
+	discord.logger.Trace("handleIncomingRustPlusMessage:", message)
+
+	// Handle triggered alarms, upkeep warnings and rejected credentials (these use the unescaped entity name)
+	if message.Type == eventhandler.AlarmType {
+		discord.handleAlarm(message)
+		return
+	} else if message.Type == eventhandler.UpkeepType {
+		discord.handleUpkeep(message)
+		return
+	} else if message.Type == eventhandler.CredentialInvalidType {
+		discord.handleCredentialInvalid(message)
+		return
+	} else if message.Type == eventhandler.ClockType {
+		// Only bother Discord with the clock if it's shown in the presence
+		if os.Getenv("DISCORD_PRESENCE_CLOCK") == "true" {
+			if err := discord.updateStatusPresence("", message.Message); err != nil {
+				discord.logger.Error("Failed to update presence:", err)
+			}
+		}
+		return
+	}
+
+	// Format any potential mentions (using the guild of the primary server)
+	message.Message = discord.formatMentions(discord.servers[0], message.Message)
+
+	// Escape both "message.User" and "message.Message" to combat potential Markdown abuse
+	message = escapeMessage(message)
+
+	// Handle server connect/disconnect messages
+	if message.Type == eventhandler.ServerConnectedType || message.Type == eventhandler.ServerDisconnectedType {
+		if len(os.Getenv("DISCORD_NOTIFICATIONS_CHANNEL_ID")) > 0 {
+			if _, err := discord.Client.ChannelMessageSend(os.Getenv("DISCORD_NOTIFICATIONS_CHANNEL_ID"), "`"+message.Message+"`"); err != nil {
+				discord.logger.Error("Failed to send message:", message, "with error:", err)
+			}
+		}
+		return
+	} else if icon, ok := worldEventIcons[message.Type]; ok {
+		// Skip if the events channel isn't set
+		if len(os.Getenv("DISCORD_EVENTS_CHANNEL_ID")) <= 0 {
+			return
+		}
+
+		if _, err := discord.Client.ChannelMessageSend(os.Getenv("DISCORD_EVENTS_CHANNEL_ID"), strings.TrimSpace(icon+" "+message.Message)); err != nil {
+			discord.logger.Error("Failed to send message:", message, "with error:", err)
+		}
+		return
+	} else if icon, ok := teamEventIcons[message.Type]; ok {
+		// Skip if the team events channel isn't set
+		if len(os.Getenv("DISCORD_TEAM_EVENTS_CHANNEL_ID")) <= 0 {
+			return
+		}
+
+		if _, err := discord.Client.ChannelMessageSend(os.Getenv("DISCORD_TEAM_EVENTS_CHANNEL_ID"), icon+" "+message.Message); err != nil {
+			discord.logger.Error("Failed to send message:", message, "with error:", err)
+		}
+		return
+	} else if message.Type == eventhandler.TeamChatType {
+		// Skip if the team chat channel isn't set
+		if len(os.Getenv("DISCORD_TEAMCHAT_CHANNEL_ID")) <= 0 {
+			return
+		}
+
+		// Skip the message if the user is missing
+		if len(message.User) <= 0 {
+			discord.logger.Warning("Skipping team chat message with missing username:", message)
+			return
+		}
+
+		if _, err := discord.Client.ChannelMessageSend(os.Getenv("DISCORD_TEAMCHAT_CHANNEL_ID"), message.User+": "+discord.emoji.toDiscord(message.Message)); err != nil {
+			discord.logger.Error("Failed to send message:", message, "with error:", err)
+		}
+		return
+	}
+}
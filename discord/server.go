@@ -0,0 +1,68 @@
+package discord
+
+import (
+	"os"
+	"strings"
+
+	"github.com/Dids/rustbot/webrcon"
+)
+
+// serverChannel returns the channel of a server (eg. DISCORD_MAIN_CHAT_CHANNEL_ID), falling back to the shared one (eg. DISCORD_CHAT_CHANNEL_ID)
+func serverChannel(serverID string, name string) string {
+	return webrcon.ServerEnv(serverID, name)
+}
+
+// serverChannelOrChat returns the channel of a server, falling back to its chat channel when the channel isn't set,
+// along with the name of the variable that the channel came from
+func serverChannelOrChat(serverID string, name string) (string, string) {
+	if channelID := serverChannel(serverID, name); len(channelID) > 0 {
+		return channelID, name
+	}
+	return serverChannel(serverID, "DISCORD_CHAT_CHANNEL_ID"), "DISCORD_CHAT_CHANNEL_ID"
+}
+
+// serverPrefix returns a "[Name] " prefix for messages of a server that are sent to a channel shared by every server
+// (nothing when there's only one server, or when the server has its own channel, an empty name meaning that the channel is always shared)
+func (discord *Discord) serverPrefix(serverID string, name string) string {
+	if len(discord.servers) <= 1 {
+		return ""
+	}
+	if _, ok := os.LookupEnv(webrcon.ServerEnvName(serverID, name)); ok && len(name) > 0 {
+		return ""
+	}
+	return "[" + webrcon.ServerName(serverID) + "] "
+}
+
+// serversForChannel returns the IDs of the servers using a channel as their chat channel
+func (discord *Discord) serversForChannel(channelID string) []string {
+	servers := make([]string, 0)
+	for _, serverID := range discord.servers {
+		if chatChannelID := serverChannel(serverID, "DISCORD_CHAT_CHANNEL_ID"); len(chatChannelID) > 0 && chatChannelID == channelID {
+			servers = append(servers, serverID)
+		}
+	}
+	return servers
+}
+
+// isPrimaryServer reports whether a server is the first configured one, which is the only one allowed to change the nickname
+func (discord *Discord) isPrimaryServer(serverID string) bool {
+	return len(discord.servers) > 0 && discord.servers[0] == serverID
+}
+
+// serverStatus stores the status of a server and returns the statuses of every server (in the configured order) for the presence
+func (discord *Discord) serverStatus(serverID string, status string) string {
+	discord.presenceMutex.Lock()
+	defer discord.presenceMutex.Unlock()
+	discord.statuses[serverID] = status
+
+	if len(discord.servers) <= 1 {
+		return status
+	}
+	parts := make([]string, 0, len(discord.servers))
+	for _, id := range discord.servers {
+		if value, ok := discord.statuses[id]; ok {
+			parts = append(parts, webrcon.ServerName(id)+" "+value)
+		}
+	}
+	return strings.Join(parts, " | ")
+}
@@ -0,0 +1,354 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// defaultShopAlertInterval is how often vending machines are checked for price alerts, unless configured otherwise
+	defaultShopAlertInterval = 5 * time.Minute
+
+	// maxShopResults is the maximum number of listings to show at once
+	maxShopResults = 15
+)
+
+// scrapItemID is the item ID of scrap, the default currency for price alerts
+const scrapItemID = -932201673
+
+// shopAlert is a user's subscription to listings of an item below a given price per unit
+type shopAlert struct {
+	ObjectID   int
+	UserID     string
+	ItemID     int32
+	Blueprint  bool // Alert on the blueprint of the item instead of the item itself
+	CurrencyID int32
+	MaxPrice   float64
+}
+
+// addShopCommands adds the slash commands for searching vending machines and managing price alerts
+func (discord *Discord) addShopCommands() {
+	itemOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "item", Description: "Name of a common item, or the numeric item ID of any item", Required: true}
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "shop",
+			Description: "Search vending machines for an item",
+			Options: []*discordgo.ApplicationCommandOption{
+				itemOption,
+				{Type: discordgo.ApplicationCommandOptionBoolean, Name: "blueprint", Description: "Only show blueprints (true) or only items (false)"},
+			},
+		},
+		handler: discord.handleShopCommand,
+	})
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "shopalert",
+			Description: "Get a direct message when an item is sold below a given price",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "add", Description: "Add a price alert", Options: []*discordgo.ApplicationCommandOption{
+					itemOption,
+					{Type: discordgo.ApplicationCommandOptionNumber, Name: "max_price", Description: "Maximum price per item", Required: true},
+					{Type: discordgo.ApplicationCommandOptionString, Name: "currency", Description: "Name of a common item, or the numeric item ID of any item (default Scrap)"},
+					{Type: discordgo.ApplicationCommandOptionBoolean, Name: "blueprint", Description: "Alert on the blueprint instead of the item (default false)"},
+				}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "remove", Description: "Remove your price alerts for an item", Options: []*discordgo.ApplicationCommandOption{itemOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "List your price alerts"},
+			},
+		},
+		handler:   discord.handleShopAlertCommand,
+		ephemeral: true,
+	})
+}
+
+func (discord *Discord) handleShopCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	var blueprint *bool
+	if option, ok := options["blueprint"]; ok {
+		value := option.BoolValue()
+		blueprint = &value
+	}
+
+	listings, err := discord.RustPlus.SearchShops(context.Background(), options["item"].StringValue(), blueprint)
+	if err != nil {
+		return nil, err
+	}
+	if len(listings) <= 0 {
+		return &discordgo.WebhookEdit{Content: "Nobody is selling " + escapeMarkdown(options["item"].StringValue()) + " right now"}, nil
+	}
+
+	return &discordgo.WebhookEdit{Content: truncateMessage(formatShopListings(listings))}, nil
+}
+
+func (discord *Discord) handleShopAlertCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	user := interactionUser(interaction)
+	if user == nil {
+		return nil, errors.New("unable to determine the user")
+	}
+
+	switch subcommand {
+	case "add":
+		itemID, err := findSingleItem(options["item"].StringValue())
+		if err != nil {
+			return nil, err
+		}
+		currencyID := int32(scrapItemID)
+		if option, ok := options["currency"]; ok {
+			if currencyID, err = findSingleItem(option.StringValue()); err != nil {
+				return nil, err
+			}
+		}
+		maxPrice := options["max_price"].FloatValue()
+		if maxPrice <= 0 {
+			return nil, errors.New("maximum price must be positive")
+		}
+		alert := &shopAlert{UserID: user.ID, ItemID: itemID, CurrencyID: currencyID, MaxPrice: maxPrice}
+		if option, ok := options["blueprint"]; ok {
+			alert.Blueprint = option.BoolValue()
+		}
+		if err := saveShopAlert(discord.Database, alert); err != nil {
+			return nil, err
+		}
+		content := "You will now receive a direct message when " + formatShopAlert(alert)
+
+		// Show the listings that already match, so that only new ones are sent as direct messages
+		// (if they can't be loaded right now, the next check sends them instead)
+		listings, err := discord.RustPlus.GetShopListings(context.Background())
+		if err != nil {
+			discord.logger.Warning("Failed to get shop listings for a new price alert:", err)
+			discord.unseenShopListings(alert, nil)
+			return &discordgo.WebhookEdit{Content: content}, nil
+		}
+		rustplus.SortShopListings(listings)
+		if matches, _ := discord.unseenShopListings(alert, listings); len(matches) > 0 {
+			content += ", which it already is:\n" + formatShopListings(matches)
+		} else {
+			content += " (nobody is selling it for that right now)"
+		}
+		return &discordgo.WebhookEdit{Content: truncateMessage(content)}, nil
+	case "remove":
+		itemID, err := findSingleItem(options["item"].StringValue())
+		if err != nil {
+			return nil, err
+		}
+		alerts, err := loadShopAlerts(discord.Database)
+		if err != nil {
+			return nil, err
+		}
+		removed := 0
+		for _, alert := range alerts {
+			if alert.UserID == user.ID && alert.ItemID == itemID {
+				if err := discord.Database.Delete("shop_alerts", alert.ObjectID); err != nil {
+					return nil, err
+				}
+				removed++
+			}
+		}
+		if removed <= 0 {
+			return nil, errors.New("you have no price alerts for " + rustplus.ItemName(itemID))
+		}
+		return &discordgo.WebhookEdit{Content: "Removed your price alerts for " + escapeMarkdown(rustplus.ItemName(itemID))}, nil
+	case "list":
+		alerts, err := loadShopAlerts(discord.Database)
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]string, 0)
+		for _, alert := range alerts {
+			if alert.UserID == user.ID {
+				lines = append(lines, "• "+formatShopAlert(alert))
+			}
+		}
+		if len(lines) <= 0 {
+			return &discordgo.WebhookEdit{Content: "You have no price alerts"}, nil
+		}
+		return &discordgo.WebhookEdit{Content: strings.Join(lines, "\n")}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
+
+// startShopAlerts periodically checks vending machines for listings matching the price alerts
+func (discord *Discord) startShopAlerts() {
+	interval := defaultShopAlertInterval
+	if seconds, err := strconv.Atoi(os.Getenv("DISCORD_SHOP_ALERT_INTERVAL")); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	discord.logger.Trace("Creating Discord shop alert ticker..")
+	ticker := time.NewTicker(interval)
+	defer func() {
+		discord.logger.Trace("Stopping Discord shop alert ticker..")
+		ticker.Stop()
+	}()
+
+	// The first check after starting only records the current listings of the alerts added before we started,
+	// as their users were already notified about them
+	notify := false
+
+	for {
+		select {
+		case <-discord.stop:
+			return
+		case <-ticker.C:
+			if err := discord.checkShopAlerts(notify); err != nil {
+				discord.logger.Warning("Failed to check price alerts:", err)
+			} else {
+				notify = true
+			}
+		}
+	}
+}
+
+// checkShopAlerts notifies users about listings below their maximum price that their alerts haven't seen yet
+// (the listings that matched when adding an alert were already shown to the user, and unless notifying, alerts that were never checked only record their listings)
+func (discord *Discord) checkShopAlerts(notify bool) error {
+	alerts, err := loadShopAlerts(discord.Database)
+	if err != nil {
+		return err
+	}
+	discord.forgetShopAlerts(alerts)
+	if len(alerts) <= 0 {
+		return nil
+	}
+	listings, err := discord.RustPlus.GetShopListings(context.Background())
+	if err != nil {
+		return err
+	}
+	rustplus.SortShopListings(listings)
+
+	for _, alert := range alerts {
+		unseen, checked := discord.unseenShopListings(alert, listings)
+		if len(unseen) <= 0 || (!notify && !checked) {
+			continue
+		}
+
+		channel, err := discord.Client.UserChannelCreate(alert.UserID)
+		if err != nil {
+			discord.logger.Error("Failed to open direct message channel for user", alert.UserID, "with error:", err)
+			continue
+		}
+		content := "💰 " + formatItem(alert.ItemID, alert.Blueprint) + " is now being sold for " + formatPrice(alert.MaxPrice) + " " + escapeMarkdown(rustplus.ItemName(alert.CurrencyID)) + " or less:\n" + formatShopListings(unseen)
+		if _, err := discord.Client.ChannelMessageSend(channel.ID, truncateMessage(content)); err != nil {
+			discord.logger.Error("Failed to send price alert to user", alert.UserID, "with error:", err)
+		}
+	}
+
+	return nil
+}
+
+// unseenShopListings returns the listings matching an alert that it hasn't seen yet (in the order of the listings),
+// remembering every listing that currently matches, and reports whether the alert was checked before
+func (discord *Discord) unseenShopListings(alert *shopAlert, listings []*rustplus.ShopListing) ([]*rustplus.ShopListing, bool) {
+	discord.shopMutex.Lock()
+	defer discord.shopMutex.Unlock()
+
+	previous, checked := discord.shopAlertsSeen[alert.ObjectID]
+	seen := make(map[string]bool)
+	unseen := make([]*rustplus.ShopListing, 0)
+	for _, listing := range listings {
+		key := shopListingKey(listing)
+		if !alert.matches(listing) || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if !previous[key] {
+			unseen = append(unseen, listing)
+		}
+	}
+	discord.shopAlertsSeen[alert.ObjectID] = seen
+	return unseen, checked
+}
+
+// forgetShopAlerts forgets the listings seen by alerts that have been removed
+func (discord *Discord) forgetShopAlerts(alerts []*shopAlert) {
+	discord.shopMutex.Lock()
+	defer discord.shopMutex.Unlock()
+
+	for objectID := range discord.shopAlertsSeen {
+		found := false
+		for _, alert := range alerts {
+			if alert.ObjectID == objectID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(discord.shopAlertsSeen, objectID)
+		}
+	}
+}
+
+// matches reports whether a listing is in stock and sells the item (or its blueprint) of the alert for at most the maximum price
+func (alert *shopAlert) matches(listing *rustplus.ShopListing) bool {
+	return listing.ItemID == alert.ItemID && listing.ItemIsBlueprint == alert.Blueprint && listing.CurrencyID == alert.CurrencyID &&
+		listing.AmountInStock > 0 && listing.PricePerUnit() <= alert.MaxPrice
+}
+
+// findSingleItem returns the ID of the item matching the query, failing if there are no or several matches
+func findSingleItem(query string) (int32, error) {
+	itemIDs := rustplus.FindItems(query)
+	if len(itemIDs) <= 0 {
+		return 0, errors.New("no items matching " + strings.TrimSpace(query) + " (" + rustplus.UnknownItemHint + ")")
+	}
+	if len(itemIDs) > 1 {
+		names := make([]string, len(itemIDs))
+		for i, itemID := range itemIDs {
+			names[i] = rustplus.ItemName(itemID)
+		}
+		return 0, errors.New("did you mean one of: " + strings.Join(names, ", "))
+	}
+	return itemIDs[0], nil
+}
+
+// shopListingKey uniquely identifies a listing of a specific vending machine
+func shopListingKey(listing *rustplus.ShopListing) string {
+	return strings.Join([]string{
+		strconv.FormatUint(uint64(listing.MarkerID), 10),
+		strconv.Itoa(int(listing.ItemID)),
+		strconv.FormatBool(listing.ItemIsBlueprint),
+		strconv.Itoa(int(listing.Quantity)),
+		strconv.Itoa(int(listing.CurrencyID)),
+		strconv.Itoa(int(listing.CostPerItem)),
+	}, ":")
+}
+
+// formatShopListing returns a single line describing a listing (eg. "`G12` **Shop**: 100 Sulfur for 50 Scrap (0.5 each, 3 left)")
+func formatShopListing(listing *rustplus.ShopListing) string {
+	shop := listing.Shop
+	if len(strings.TrimSpace(shop)) <= 0 {
+		shop = "Vending Machine"
+	}
+	return "`" + listing.Location + "` **" + escapeMarkdown(shop) + "**: " +
+		strconv.Itoa(int(listing.Quantity)) + " " + formatItem(listing.ItemID, listing.ItemIsBlueprint) + " for " +
+		strconv.Itoa(int(listing.CostPerItem)) + " " + formatItem(listing.CurrencyID, listing.CurrencyIsBlueprint) +
+		" (" + formatPrice(listing.PricePerUnit()) + " each, " + strconv.Itoa(int(listing.AmountInStock)) + " left)"
+}
+
+// formatShopListings returns a line for each listing, up to the maximum number of listings shown at once
+func formatShopListings(listings []*rustplus.ShopListing) string {
+	lines := make([]string, 0)
+	for i, listing := range listings {
+		if i >= maxShopResults {
+			lines = append(lines, "…and "+strconv.Itoa(len(listings)-maxShopResults)+" more")
+			break
+		}
+		lines = append(lines, formatShopListing(listing))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatShopAlert(alert *shopAlert) string {
+	return formatItem(alert.ItemID, alert.Blueprint) + " is sold for " + formatPrice(alert.MaxPrice) + " " + escapeMarkdown(rustplus.ItemName(alert.CurrencyID)) + " or less"
+}
+
+// formatPrice formats a price with at most two decimals (eg. "0.33")
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(float64(int64(price*100+0.5))/100, 'f', -1, 64)
+}
@@ -0,0 +1,143 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxStorageHistory is the maximum number of snapshots to show at once
+const maxStorageHistory = 10
+
+// addStorageCommands adds the slash commands for checking storage monitors
+func (discord *Discord) addStorageCommands() {
+	nameOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Name of the storage monitor", Required: true}
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "storage",
+			Description: "Check the contents of storage monitors",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "check", Description: "Show the contents and what changed since the last check", Options: []*discordgo.ApplicationCommandOption{nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "history", Description: "Show how the contents changed over time", Options: []*discordgo.ApplicationCommandOption{
+					nameOption,
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "count", Description: "Number of snapshots to show (default " + strconv.Itoa(maxStorageHistory) + ")"},
+				}},
+			},
+		},
+		handler: discord.handleStorageCommand,
+	})
+}
+
+func (discord *Discord) handleStorageCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	name := options["name"].StringValue()
+
+	switch subcommand {
+	case "check":
+		current, previous, err := discord.RustPlus.CheckStorage(context.Background(), name)
+		if err != nil {
+			return nil, err
+		}
+		entity, err := discord.RustPlus.GetEntity(name)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := []string{"📦 **" + escapeMarkdown(entity.Name) + "**"}
+		if entity.HasProtection {
+			lines = append(lines, "Upkeep: "+rustplus.FormatDuration(time.Until(time.Unix(int64(entity.ProtectionExpiry), 0))))
+		}
+		items := current.Totals()
+		if len(items) <= 0 {
+			lines = append(lines, "Empty")
+		}
+		for _, item := range items {
+			lines = append(lines, formatItem(item.ItemID, item.Blueprint)+": "+strconv.Itoa(int(item.Quantity)))
+		}
+		if previous != nil {
+			lines = append(lines, "", "Since "+formatTime(previous.Time)+": "+formatStorageChanges(rustplus.DiffStorageSnapshots(previous, current)))
+		}
+		return &discordgo.WebhookEdit{Content: truncateMessage(strings.Join(lines, "\n"))}, nil
+	case "history":
+		count := maxStorageHistory
+		if option, ok := options["count"]; ok && option.IntValue() > 0 && option.IntValue() < maxStorageHistory {
+			count = int(option.IntValue())
+		}
+		snapshots, err := discord.RustPlus.GetStorageSnapshots(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) <= 0 {
+			return &discordgo.WebhookEdit{Content: "No snapshots have been stored yet"}, nil
+		}
+
+		// Snapshots are newest first, so each one is compared to the next (older) one
+		lines := make([]string, 0)
+		for i := 0; i < len(snapshots) && i < count; i++ {
+			var previous *rustplus.StorageSnapshot
+			if i+1 < len(snapshots) {
+				previous = snapshots[i+1]
+			}
+			lines = append(lines, formatTime(snapshots[i].Time)+": "+formatStorageChanges(rustplus.DiffStorageSnapshots(previous, snapshots[i])))
+		}
+		return &discordgo.WebhookEdit{Content: truncateMessage(strings.Join(lines, "\n"))}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
+
+// handleUpkeep sends tool cupboard upkeep warnings to the upkeep channel
+func (discord *Discord) handleUpkeep(message eventhandler.Message) {
+	if len(os.Getenv("DISCORD_UPKEEP_CHANNEL_ID")) <= 0 {
+		return
+	}
+	if _, err := discord.Client.ChannelMessageSend(os.Getenv("DISCORD_UPKEEP_CHANNEL_ID"), "⚠️ "+escapeMarkdown(message.Message)); err != nil {
+		discord.logger.Error("Failed to send upkeep warning:", message, "with error:", err)
+	}
+}
+
+// formatStorageChanges returns a single line describing item changes (eg. "-2000 Sulfur, +50 Scrap")
+func formatStorageChanges(changes []rustplus.StorageChange) string {
+	if len(changes) <= 0 {
+		return "no changes"
+	}
+	parts := make([]string, len(changes))
+	for i, change := range changes {
+		quantity := strconv.Itoa(change.Quantity)
+		if change.Quantity > 0 {
+			quantity = "+" + quantity
+		}
+		parts[i] = quantity + " " + formatItem(change.ItemID, change.Blueprint)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatItem(itemID int32, blueprint bool) string {
+	name := escapeMarkdown(rustplus.ItemName(itemID))
+	if blueprint {
+		name += " (blueprint)"
+	}
+	return name
+}
+
+func formatTime(t time.Time) string {
+	return "<t:" + strconv.FormatInt(t.Unix(), 10) + ":R>"
+}
+
+// truncateMessage makes sure that a message fits within Discord's message length limit
+func truncateMessage(message string) string {
+	const maxLength = 2000
+	if len(message) <= maxLength {
+		return message
+	}
+	if index := strings.LastIndex(message[:maxLength-3], "\n"); index > 0 {
+		return message[:index+1] + "…"
+	}
+	return strings.ToValidUTF8(message[:maxLength-3], "") + "…"
+}
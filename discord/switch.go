@@ -0,0 +1,182 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// addSwitchCommands adds the slash commands for managing paired entities and controlling smart switches
+func (discord *Discord) addSwitchCommands() {
+	nameOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "Name of the entity or group", Required: true}
+	groupOption := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "group", Description: "Name of the group", Required: true}
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "entity",
+			Description: "Manage paired Rust+ entities",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "add", Description: "Pair an entity with a name", Options: []*discordgo.ApplicationCommandOption{
+					nameOption,
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "entity_id", Description: "Entity ID of the paired device", Required: true},
+				}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "remove", Description: "Unpair an entity", Options: []*discordgo.ApplicationCommandOption{nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "List all paired entities"},
+			},
+		},
+		handler: discord.handleEntityCommand,
+	})
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "switch",
+			Description: "Control smart switches",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "on", Description: "Turn a switch or group on", Options: []*discordgo.ApplicationCommandOption{nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "off", Description: "Turn a switch or group off", Options: []*discordgo.ApplicationCommandOption{nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "toggle", Description: "Toggle a switch or group", Options: []*discordgo.ApplicationCommandOption{nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "status", Description: "Show the current state of an entity", Options: []*discordgo.ApplicationCommandOption{nameOption}},
+			},
+		},
+		handler: discord.handleSwitchCommand,
+	})
+
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "group",
+			Description: "Manage groups of smart switches",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "add", Description: "Add an entity to a group", Options: []*discordgo.ApplicationCommandOption{groupOption, nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "remove", Description: "Remove an entity from a group", Options: []*discordgo.ApplicationCommandOption{groupOption, nameOption}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "List all groups"},
+			},
+		},
+		handler: discord.handleGroupCommand,
+	})
+}
+
+func (discord *Discord) handleEntityCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	switch subcommand {
+	case "add":
+		entityID := options["entity_id"].IntValue()
+		if entityID <= 0 || entityID > int64(^uint32(0)) {
+			return nil, errors.New("invalid entity ID")
+		}
+		entity, err := discord.RustPlus.AddEntity(context.Background(), options["name"].StringValue(), uint32(entityID))
+		if err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: "Paired " + formatEntity(entity)}, nil
+	case "remove":
+		if err := discord.RustPlus.RemoveEntity(options["name"].StringValue()); err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: "Unpaired " + escapeMarkdown(options["name"].StringValue())}, nil
+	case "list":
+		entities, err := discord.RustPlus.GetEntities()
+		if err != nil {
+			return nil, err
+		}
+		if len(entities) <= 0 {
+			return &discordgo.WebhookEdit{Content: "No entities have been paired yet"}, nil
+		}
+		lines := make([]string, len(entities))
+		for i, entity := range entities {
+			lines[i] = formatEntity(entity)
+		}
+		return &discordgo.WebhookEdit{Content: strings.Join(lines, "\n")}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
+
+func (discord *Discord) handleSwitchCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	name := options["name"].StringValue()
+
+	var entities []*rustplus.Entity
+	var err error
+	switch subcommand {
+	case "on":
+		entities, err = discord.RustPlus.SetSwitch(context.Background(), name, true)
+	case "off":
+		entities, err = discord.RustPlus.SetSwitch(context.Background(), name, false)
+	case "toggle":
+		entities, err = discord.RustPlus.ToggleSwitch(context.Background(), name)
+	case "status":
+		var entity *rustplus.Entity
+		if entity, err = discord.RustPlus.RefreshEntity(context.Background(), name); err == nil {
+			entities = []*rustplus.Entity{entity}
+		}
+	default:
+		err = errors.New("unknown subcommand: " + subcommand)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, len(entities))
+	for i, entity := range entities {
+		lines[i] = formatEntity(entity)
+	}
+	return &discordgo.WebhookEdit{Content: strings.Join(lines, "\n")}, nil
+}
+
+func (discord *Discord) handleGroupCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	switch subcommand {
+	case "add":
+		if err := discord.RustPlus.AddEntityToGroup(options["group"].StringValue(), options["name"].StringValue()); err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: "Added " + escapeMarkdown(options["name"].StringValue()) + " to " + escapeMarkdown(options["group"].StringValue())}, nil
+	case "remove":
+		if err := discord.RustPlus.RemoveEntityFromGroup(options["group"].StringValue(), options["name"].StringValue()); err != nil {
+			return nil, err
+		}
+		return &discordgo.WebhookEdit{Content: "Removed " + escapeMarkdown(options["name"].StringValue()) + " from " + escapeMarkdown(options["group"].StringValue())}, nil
+	case "list":
+		groups, err := discord.RustPlus.GetEntityGroups()
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) <= 0 {
+			return &discordgo.WebhookEdit{Content: "No groups have been created yet"}, nil
+		}
+		lines := make([]string, len(groups))
+		for i, group := range groups {
+			lines[i] = "**" + escapeMarkdown(group.Name) + "**: " + escapeMarkdown(strings.Join(group.Entities, ", "))
+		}
+		return &discordgo.WebhookEdit{Content: strings.Join(lines, "\n")}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
+
+// formatEntity returns a single line describing a paired entity and its current state
+func formatEntity(entity *rustplus.Entity) string {
+	icon := "❓"
+	state := ""
+	switch entity.Type {
+	case rustplus.AppEntityType_Switch:
+		icon = "💡"
+		state = "off"
+		if entity.Value {
+			state = "on"
+		}
+	case rustplus.AppEntityType_Alarm:
+		icon = "🚨"
+		state = "idle"
+		if entity.Value {
+			state = "triggered"
+		}
+	case rustplus.AppEntityType_StorageMonitor:
+		icon = "📦"
+	}
+
+	line := icon + " **" + escapeMarkdown(entity.Name) + "** (`" + strconv.FormatUint(uint64(entity.EntityID), 10) + "`, " + entity.Type.String() + ")"
+	if len(state) > 0 {
+		line += ": " + state
+	}
+	return line
+}
@@ -0,0 +1,121 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxTeamActivity is the maximum number of team events to show at once
+const maxTeamActivity = 15
+
+// teamEventIcons are the icons for each type of team event, which also determines which messages are team events
+var teamEventIcons = map[eventhandler.MessageType]string{
+	eventhandler.TeamJoinType:    "👋",
+	eventhandler.TeamLeaveType:   "🚪",
+	eventhandler.TeamLeaderType:  "👑",
+	eventhandler.TeamOnlineType:  "🟢",
+	eventhandler.TeamOfflineType: "⚫",
+	eventhandler.TeamDeathType:   "💀",
+	eventhandler.TeamRespawnType: "🛏️",
+}
+
+// addTeamCommands adds the slash commands for checking the team
+func (discord *Discord) addTeamCommands() {
+	discord.addCommand(&command{
+		definition: &discordgo.ApplicationCommand{
+			Name:        "team",
+			Description: "Check the team",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "status", Description: "Show every team member and their status"},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "activity", Description: "Show the recent team activity", Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionString, Name: "player", Description: "Only show the activity of this team member"},
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "count", Description: "Number of events to show (default " + strconv.Itoa(maxTeamActivity) + ")"},
+				}},
+			},
+		},
+		handler: discord.handleTeamCommand,
+	})
+}
+
+func (discord *Discord) handleTeamCommand(interaction *discordgo.InteractionCreate, subcommand string, options map[string]*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.WebhookEdit, error) {
+	switch subcommand {
+	case "status":
+		teamInfo, err := discord.RustPlus.GetTeamInfo(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		world, _ := discord.RustPlus.GetWorldMap(context.Background())
+
+		members := teamInfo.GetMembers()
+		sort.SliceStable(members, func(i, j int) bool {
+			if members[i].GetIsOnline() != members[j].GetIsOnline() {
+				return members[i].GetIsOnline()
+			}
+			return strings.ToLower(members[i].GetName()) < strings.ToLower(members[j].GetName())
+		})
+
+		lines := make([]string, 0, len(members))
+		for _, member := range members {
+			line := "⚫ **" + escapeMarkdown(member.GetName()) + "**"
+			if member.GetIsOnline() {
+				line = "🟢 **" + escapeMarkdown(member.GetName()) + "**"
+			}
+			if member.GetSteamId() == teamInfo.GetLeaderSteamId() {
+				line += " 👑"
+			}
+			if !member.GetIsAlive() {
+				line += " (dead)"
+			} else if member.GetIsOnline() && world != nil {
+				line += " at `" + world.Grid(float64(member.GetX()), float64(member.GetY())) + "`"
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) <= 0 {
+			return &discordgo.WebhookEdit{Content: "Not in a team"}, nil
+		}
+		return &discordgo.WebhookEdit{Content: truncateMessage(strings.Join(lines, "\n"))}, nil
+	case "activity":
+		count := maxTeamActivity
+		if option, ok := options["count"]; ok && option.IntValue() > 0 {
+			count = int(option.IntValue())
+		}
+
+		// Find the team member by name
+		steamID := uint64(0)
+		if option, ok := options["player"]; ok {
+			teamInfo, err := discord.RustPlus.GetTeamInfo(context.Background())
+			if err != nil {
+				return nil, err
+			}
+			for _, member := range teamInfo.GetMembers() {
+				if strings.EqualFold(member.GetName(), strings.TrimSpace(option.StringValue())) {
+					steamID = member.GetSteamId()
+					break
+				}
+			}
+			if steamID <= 0 {
+				return nil, errors.New("no team member named " + option.StringValue())
+			}
+		}
+
+		events, err := discord.RustPlus.GetTeamActivity(steamID, count)
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]string, 0, len(events))
+		for _, event := range events {
+			lines = append(lines, formatTime(event.Time)+" "+strings.TrimSpace(teamEventIcons[event.Type]+" "+escapeMarkdown(event.Message)))
+		}
+		if len(lines) <= 0 {
+			return &discordgo.WebhookEdit{Content: "No team activity yet"}, nil
+		}
+		return &discordgo.WebhookEdit{Content: truncateMessage(strings.Join(lines, "\n"))}, nil
+	}
+	return nil, errors.New("unknown subcommand: " + subcommand)
+}
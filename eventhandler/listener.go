@@ -0,0 +1,56 @@
+package eventhandler
+
+import "sync"
+
+// listener delivers messages to a listener channel in the order they were emitted, without ever blocking the emitter
+type listener struct {
+	channel chan Message
+	mutex   sync.Mutex
+	queue   []Message
+	ready   chan struct{} // Signaled when a message is queued
+	done    chan struct{} // Closed when the listener is removed
+}
+
+func newListener(channel chan Message) *listener {
+	listener := &listener{channel: channel, ready: make(chan struct{}, 1), done: make(chan struct{})}
+	go listener.deliver()
+	return listener
+}
+
+// push queues a message for the listener
+func (listener *listener) push(message Message) {
+	listener.mutex.Lock()
+	listener.queue = append(listener.queue, message)
+	listener.mutex.Unlock()
+
+	select {
+	case listener.ready <- struct{}{}:
+	default:
+	}
+}
+
+// deliver passes queued messages on to the listener channel, until the listener is removed
+func (listener *listener) deliver() {
+	for {
+		listener.mutex.Lock()
+		if len(listener.queue) <= 0 {
+			listener.mutex.Unlock()
+			select {
+			case <-listener.ready:
+				continue
+			case <-listener.done:
+				return
+			}
+		}
+		message := listener.queue[0]
+		listener.queue[0] = Message{}
+		listener.queue = listener.queue[1:]
+		listener.mutex.Unlock()
+
+		select {
+		case listener.channel <- message:
+		case <-listener.done:
+			return
+		}
+	}
+}
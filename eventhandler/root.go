@@ -1,9 +1,14 @@
 package eventhandler
 
+import "sync"
+
 // EventHandler is used for two-way communication between the Discord and Webrcon clients
 type EventHandler struct {
-	Name      string
-	Listeners map[string][]chan Message
+	Name string
+
+	// Private properties
+	listeners map[string][]*listener
+	mutex     sync.RWMutex // Listeners are added and removed while other goroutines are emitting
 }
 
 // MessageType represents the type of a message
@@ -28,6 +33,76 @@ const (
 	ServerConnectedType MessageType = "ServerConnected"
 	// ServerDisconnectedType is a message type
 	ServerDisconnectedType MessageType = "ServerDisconnected"
+	// TeamChatType is a message type
+	TeamChatType MessageType = "TeamChat"
+	// AlarmType is a message type
+	AlarmType MessageType = "Alarm"
+	// UpkeepType is a message type
+	UpkeepType MessageType = "Upkeep"
+	// CredentialInvalidType is a message type
+	CredentialInvalidType MessageType = "CredentialInvalid"
+	// TeamJoinType is a message type
+	TeamJoinType MessageType = "TeamJoin"
+	// TeamLeaveType is a message type
+	TeamLeaveType MessageType = "TeamLeave"
+	// TeamLeaderType is a message type
+	TeamLeaderType MessageType = "TeamLeader"
+	// TeamOnlineType is a message type
+	TeamOnlineType MessageType = "TeamOnline"
+	// TeamOfflineType is a message type
+	TeamOfflineType MessageType = "TeamOffline"
+	// TeamDeathType is a message type
+	TeamDeathType MessageType = "TeamDeath"
+	// TeamRespawnType is a message type
+	TeamRespawnType MessageType = "TeamRespawn"
+	// CargoSpawnedType is a message type
+	CargoSpawnedType MessageType = "CargoSpawned"
+	// CargoLeftType is a message type
+	CargoLeftType MessageType = "CargoLeft"
+	// CH47SpawnedType is a message type
+	CH47SpawnedType MessageType = "CH47Spawned"
+	// CH47LeftType is a message type
+	CH47LeftType MessageType = "CH47Left"
+	// CH47OilRigType is a message type
+	CH47OilRigType MessageType = "CH47OilRig"
+	// CH47AirdropType is a message type
+	CH47AirdropType MessageType = "CH47Airdrop"
+	// HeliSpawnedType is a message type
+	HeliSpawnedType MessageType = "HeliSpawned"
+	// HeliLeftType is a message type
+	HeliLeftType MessageType = "HeliLeft"
+	// HeliDownType is a message type
+	HeliDownType MessageType = "HeliDown"
+	// CrateSpawnedType is a message type
+	CrateSpawnedType MessageType = "CrateSpawned"
+	// CrateGoneType is a message type
+	CrateGoneType MessageType = "CrateGone"
+	// ExplosionType is a message type
+	ExplosionType MessageType = "Explosion"
+	// ClockType is a message type
+	ClockType MessageType = "Clock"
+	// NightfallSoonType is a message type
+	NightfallSoonType MessageType = "NightfallSoon"
+	// NightfallType is a message type
+	NightfallType MessageType = "Nightfall"
+	// SunriseType is a message type
+	SunriseType MessageType = "Sunrise"
+	// KickType is a message type
+	KickType MessageType = "Kick"
+	// EACKickType is a message type
+	EACKickType MessageType = "EACKick"
+	// BanType is a message type
+	BanType MessageType = "Ban"
+	// ReportType is a message type
+	ReportType MessageType = "Report"
+	// SaveType is a message type
+	SaveType MessageType = "Save"
+	// StartupType is a message type
+	StartupType MessageType = "Startup"
+	// AirdropType is a message type
+	AirdropType MessageType = "Airdrop"
+	// RuleType is a message type
+	RuleType MessageType = "Rule"
 	// TraceLogType is a message type
 	TraceLogType MessageType = "TraceLog"
 	// InfoLogType is a message type
@@ -42,47 +117,52 @@ const (
 
 // Message is used for emitting data through the EventHandler
 type Message struct {
-	Event   string
-	User    string
-	Message string
-	Type    MessageType
+	Event       string
+	User        string
+	Message     string
+	Type        MessageType
+	Server      string // ID of the Rust server the message is from or for (empty when there's only one server)
+	Delayed     bool   // Set for messages that were missed while disconnected and are only relayed now
+	Rule        string // Event name of the console rule that created the message (for RuleType messages)
+	Channel     string // Discord channel to send the message to, instead of the default one
+	ChatChannel string // In-game chat channel of chat messages (eg. "global" or "team")
 }
 
 // AddListener adds an event listener to the EventHandler struct instance
+// (each listener receives its messages in the order they were emitted)
 func (handler *EventHandler) AddListener(name string, channel chan Message) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
 	// Create the listeners if they don't yet exist
-	if handler.Listeners == nil {
-		handler.Listeners = make(map[string][]chan Message)
-	}
-	// Find/set the listener
-	if _, ok := handler.Listeners[name]; ok {
-		handler.Listeners[name] = append(handler.Listeners[name], channel)
-	} else {
-		handler.Listeners[name] = []chan Message{channel}
+	if handler.listeners == nil {
+		handler.listeners = make(map[string][]*listener)
 	}
+	handler.listeners[name] = append(handler.listeners[name], newListener(channel))
 }
 
-// RemoveListener removes an event listener from the EventHandler struct instance
+// RemoveListener removes an event listener from the EventHandler struct instance, dropping any messages it didn't receive yet
 func (handler *EventHandler) RemoveListener(name string, channel chan Message) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+
 	// Find the listener
-	if _, ok := handler.Listeners[name]; ok {
-		for i := range handler.Listeners[name] {
-			if handler.Listeners[name][i] == channel {
-				handler.Listeners[name] = append(handler.Listeners[name][:i], handler.Listeners[name][i+1:]...)
-				break
-			}
+	for i, listener := range handler.listeners[name] {
+		if listener.channel == channel {
+			close(listener.done)
+			handler.listeners[name] = append(handler.listeners[name][:i], handler.listeners[name][i+1:]...)
+			break
 		}
 	}
 }
 
 // Emit emits an event on the EventHandler struct instance
 func (handler *EventHandler) Emit(message Message) {
-	// Find the listener
-	if _, ok := handler.Listeners[message.Event]; ok {
-		for _, handler := range handler.Listeners[message.Event] {
-			go func(handler chan Message) {
-				handler <- message
-			}(handler)
-		}
+	handler.mutex.RLock()
+	defer handler.mutex.RUnlock()
+
+	// Queue the message for each listener, so a slow listener can't block us or receive messages out of order
+	for _, listener := range handler.listeners[message.Event] {
+		listener.push(message)
 	}
 }
@@ -1,7 +1,48 @@
 package eventhandler
 
-import "testing"
+import (
+	"strconv"
+	"testing"
+	"time"
+)
 
 func TestDummy(t *testing.T) {
 
 }
+
+func TestEmitOrder(t *testing.T) {
+	handler := &EventHandler{}
+	messages := make(chan Message)
+	handler.AddListener("test", messages)
+	defer handler.RemoveListener("test", messages)
+
+	// Messages are delivered in the order they were emitted, even when the listener isn't reading yet
+	for i := 0; i < 100; i++ {
+		handler.Emit(Message{Event: "test", Message: strconv.Itoa(i)})
+	}
+	for i := 0; i < 100; i++ {
+		select {
+		case message := <-messages:
+			if message.Message != strconv.Itoa(i) {
+				t.Fatal("expected message", i, "but got", message.Message)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for message", i)
+		}
+	}
+}
+
+func TestRemoveListener(t *testing.T) {
+	handler := &EventHandler{}
+	messages := make(chan Message, 1)
+	handler.AddListener("test", messages)
+	handler.RemoveListener("test", messages)
+
+	// Removed listeners don't receive any more messages
+	handler.Emit(Message{Event: "test"})
+	select {
+	case message := <-messages:
+		t.Fatal("did not expect message:", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
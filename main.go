@@ -16,14 +16,11 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 )
 
-// TODO: Remove this after we actually start using it
-var foo rustplus.AppEntityType
-
 var eventHandler eventhandler.EventHandler
 
 func main() {
 	// Initialize our own event handler
-	eventHandler = eventhandler.EventHandler{Name: "rustbot", Listeners: nil}
+	eventHandler = eventhandler.EventHandler{Name: "rustbot"}
 
 	// Determine our log level
 	logLevel := logger.Trace
@@ -52,8 +49,18 @@ func main() {
 		logger.Panic("Failed to open database:", databaseErr)
 	}
 
+	// Initialize the Rust+ client (optional)
+	var rustPlus *rustplus.RustPlus
+	if len(os.Getenv("RUSTPLUS_HOST")) > 0 {
+		var rustPlusErr error
+		rustPlus, rustPlusErr = rustplus.NewRustPlus(&eventHandler, database)
+		if rustPlusErr != nil {
+			logger.Panic("Failed to initialize Rust+:", rustPlusErr)
+		}
+	}
+
 	// Initialize and open the Discord client
-	discord, discordErr := discord.NewDiscord(&eventHandler, database)
+	discord, discordErr := discord.NewDiscord(&eventHandler, database, rustPlus)
 	if discordErr != nil {
 		logger.Panic("Failed to initialize Discord:", discordErr)
 	}
@@ -61,15 +68,43 @@ func main() {
 		logger.Panic("Failed to open Discord:", discordErr)
 	}
 
-	// Initialize and open the Webrcon client
-	webrcon, webrconErr := webrcon.NewWebrcon(&eventHandler, database)
-	if webrconErr != nil {
-		logger.Panic("Failed to initialize Webrcon:", webrconErr)
+	// Initialize and open a Webrcon client for each server
+	serverIDs, serverIDsErr := webrcon.ServerIDs()
+	if serverIDsErr != nil {
+		logger.Panic("Failed to initialize Webrcon:", serverIDsErr)
 	}
-	if webrconErr := webrcon.Open(); webrconErr != nil {
-		logger.Panic("Failed to open Webrcon:", webrconErr)
+	webrcons := make([]*webrcon.Webrcon, 0, len(serverIDs))
+	for _, serverID := range serverIDs {
+		webrcon, webrconErr := webrcon.NewWebrcon(&eventHandler, database, serverID)
+		if webrconErr != nil {
+			logger.Panic("Failed to initialize Webrcon:", webrconErr)
+		}
+		if webrconErr := webrcon.Open(); webrconErr != nil {
+			logger.Panic("Failed to open Webrcon:", webrconErr)
+		}
+		webrcons = append(webrcons, webrcon)
 	}
 
+	// Open the Rust+ client (optional)
+	if rustPlus != nil {
+		if rustPlusErr := rustPlus.Open(); rustPlusErr != nil {
+			logger.Panic("Failed to open Rust+:", rustPlusErr)
+		}
+	}
+
+	// Reload the console rules when SIGHUP is received
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			for _, webrcon := range webrcons {
+				if err := webrcon.ReloadRules(); err != nil {
+					logger.Error("Failed to reload console rules:", err)
+				}
+			}
+		}
+	}()
+
 	// Wait here until CTRL-C or other term signal is received.
 	logger.Info("RustBot is now running. Press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
@@ -80,8 +115,15 @@ func main() {
 	logger.Info("Stopping..")
 
 	// Properly dispose of the clients when exiting
-	if err := webrcon.Close(); err != nil {
-		logger.Panic("Failed to close Webrcon:", err)
+	if rustPlus != nil {
+		if err := rustPlus.Close(); err != nil {
+			logger.Panic("Failed to close Rust+:", err)
+		}
+	}
+	for _, webrcon := range webrcons {
+		if err := webrcon.Close(); err != nil {
+			logger.Panic("Failed to close Webrcon:", err)
+		}
 	}
 	if err := discord.Close(); err != nil {
 		logger.Panic("Failed to close Discord:", err)
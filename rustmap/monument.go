@@ -0,0 +1,61 @@
+package rustmap
+
+import "strings"
+
+// monumentNames maps monument tokens to their friendly names (an empty name means the monument is ignored)
+var monumentNames = map[string]string{
+	"AbandonedMilitaryBase":              "Abandoned Military Base",
+	"airfield_display_name":              "Airfield",
+	"arctic_base_a":                      "Arctic Research Base",
+	"bandit_camp":                        "Bandit Camp",
+	"dome_monument_name":                 "The Dome",
+	"DungeonBase":                        "",
+	"excavator":                          "Giant Excavator Pit",
+	"ferryterminal":                      "Ferry Terminal",
+	"fishing_village_display_name":       "Fishing Village",
+	"gas_station":                        "Oxum's Gas Station",
+	"harbor_2_display_name":              "Harbor",
+	"harbor_display_name":                "Harbor",
+	"junkyard_display_name":              "Junkyard",
+	"large_fishing_village_display_name": "Large Fishing Village",
+	"large_oil_rig":                      "Large Oil Rig",
+	"launchsite":                         "Launch Site",
+	"lighthouse_display_name":            "Lighthouse",
+	"military_tunnels_display_name":      "Military Tunnels",
+	"mining_outpost_display_name":        "Mining Outpost",
+	"mining_quarry_hqm_display_name":     "HQM Quarry",
+	"mining_quarry_stone_display_name":   "Stone Quarry",
+	"mining_quarry_sulfur_display_name":  "Sulfur Quarry",
+	"missile_silo_monument":              "Missile Silo",
+	"oil_rig_small":                      "Oil Rig",
+	"outpost":                            "Outpost",
+	"power_plant_display_name":           "Power Plant",
+	"satellite_dish_display_name":        "Satellite Dish",
+	"sewer_display_name":                 "Sewer Branch",
+	"stables_a":                          "Ranch",
+	"stables_b":                          "Large Barn",
+	"supermarket":                        "Abandoned Supermarket",
+	"swamp_c":                            "Abandoned Cabins",
+	"train_tunnel_display_name":          "",
+	"train_tunnel_link_display_name":     "",
+	"train_yard_display_name":            "Train Yard",
+	"underwater_lab":                     "Underwater Lab",
+	"water_treatment_plant_display_name": "Water Treatment Plant",
+}
+
+// MonumentName returns the friendly name of a monument token, or an empty string for monuments that should be ignored
+// (unknown tokens are made readable, eg. "new_monument_display_name" becomes "New Monument")
+func MonumentName(token string) string {
+	if name, ok := monumentNames[token]; ok {
+		return name
+	}
+	if strings.HasPrefix(token, "DungeonBase") {
+		return ""
+	}
+
+	words := strings.Fields(strings.ReplaceAll(strings.TrimSuffix(token, "_display_name"), "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
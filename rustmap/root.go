@@ -0,0 +1,132 @@
+package rustmap
+
+import (
+	"math"
+	"strconv"
+)
+
+// CellSize is the approximate size of a single grid cell in world units (the actual size is adjusted to fit the map exactly)
+const CellSize = 146.3
+
+// nearDistance is how close to a monument (in grid cells) a position must be to be considered near it
+const nearDistance = 1.5
+
+// Map describes a server map, translating world positions to grid cells, image pixels and monuments
+type Map struct {
+	// Size is the size of the map in world units (AppInfo.mapSize)
+	Size uint32
+
+	// Width and Height are the size of the map image in pixels (AppMap.width and AppMap.height)
+	Width  uint32
+	Height uint32
+
+	// OceanMargin is the width of the ocean surrounding the map in the map image, in pixels (AppMap.oceanMargin)
+	OceanMargin int32
+
+	// Monuments are the monuments on the map (AppMap.monuments)
+	Monuments []Monument
+}
+
+// Monument is a single monument on the map
+type Monument struct {
+	Token string
+	X     float64
+	Y     float64
+}
+
+// CellCount returns the number of grid cells along each side of the map
+func (m *Map) CellCount() int {
+	count := int(math.Floor(float64(m.Size) / CellSize))
+	if count < 1 {
+		return 1
+	}
+	return count
+}
+
+// CellSize returns the actual size of a single grid cell in world units
+func (m *Map) CellSize() float64 {
+	return float64(m.Size) / float64(m.CellCount())
+}
+
+// Cell returns the column and row of the grid cell of a world position, where positions outside the map use the closest cell
+func (m *Map) Cell(x float64, y float64) (int, int) {
+	count := m.CellCount()
+	cellSize := m.CellSize()
+	column := int(math.Floor(x / cellSize))
+	row := int(math.Floor((float64(m.Size) - y) / cellSize))
+	return clamp(column, 0, count-1), clamp(row, 0, count-1)
+}
+
+// Grid returns the grid cell of a world position (eg. "K14")
+func (m *Map) Grid(x float64, y float64) string {
+	column, row := m.Cell(x, y)
+	return CellName(column, row)
+}
+
+// ToPixel translates a world position (origin at the bottom left) to a position in the map image (origin at the top left)
+func (m *Map) ToPixel(x float64, y float64) (float64, float64) {
+	margin := float64(m.OceanMargin)
+	width := float64(m.Width) - 2*margin
+	height := float64(m.Height) - 2*margin
+	return margin + x/float64(m.Size)*width, float64(m.Height) - (margin + y/float64(m.Size)*height)
+}
+
+// Nearest returns the monument closest to a world position and its distance, ignoring monuments without a name (or nil if there are none)
+func (m *Map) Nearest(x float64, y float64) (*Monument, float64) {
+	var nearest *Monument
+	nearestDistance := math.Inf(1)
+	for i := range m.Monuments {
+		monument := &m.Monuments[i]
+		if len(MonumentName(monument.Token)) <= 0 {
+			continue
+		}
+		if distance := math.Hypot(monument.X-x, monument.Y-y); distance < nearestDistance {
+			nearest = monument
+			nearestDistance = distance
+		}
+	}
+	return nearest, nearestDistance
+}
+
+// Near returns "near <monument>" if a world position is close to a monument, and an empty string otherwise
+func (m *Map) Near(x float64, y float64) string {
+	monument, distance := m.Nearest(x, y)
+	if monument == nil || distance > nearDistance*m.CellSize() {
+		return ""
+	}
+	return "near " + MonumentName(monument.Token)
+}
+
+// Location returns the grid cell of a world position, along with the monument it's near (eg. "K14 near Launch Site")
+func (m *Map) Location(x float64, y float64) string {
+	location := m.Grid(x, y)
+	if near := m.Near(x, y); len(near) > 0 {
+		location += " " + near
+	}
+	return location
+}
+
+// ColumnName returns the letter(s) of a grid column (A-Z, then AA, AB and so on)
+func ColumnName(column int) string {
+	name := ""
+	for column >= 0 {
+		name = string(rune('A'+column%26)) + name
+		column = column/26 - 1
+	}
+	return name
+}
+
+// CellName returns the name of a grid cell (eg. "K14")
+func CellName(column int, row int) string {
+	return ColumnName(column) + strconv.Itoa(row)
+}
+
+func clamp(value int, min int, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,126 @@
+package rustmap
+
+import "testing"
+
+func TestCellCount(t *testing.T) {
+	for _, test := range []struct {
+		size     uint32
+		count    int
+		cellSize float64
+	}{
+		{3000, 20, 150},
+		{3500, 23, 3500.0 / 23},
+		{4000, 27, 4000.0 / 27},
+		{4250, 29, 4250.0 / 29},
+		{4500, 30, 150},
+		{6000, 41, 6000.0 / 41},
+		{100, 1, 100},
+	} {
+		m := &Map{Size: test.size}
+		if count := m.CellCount(); count != test.count {
+			t.Error("expected", test.count, "cells for map size", test.size, "but got", count)
+		}
+		if cellSize := m.CellSize(); cellSize != test.cellSize {
+			t.Error("expected cell size", test.cellSize, "for map size", test.size, "but got", cellSize)
+		}
+	}
+}
+
+func TestGrid(t *testing.T) {
+	for _, test := range []struct {
+		size     uint32
+		x, y     float64
+		expected string
+	}{
+		{3000, 0, 3000, "A0"},
+		{3000, 2999, 0, "T19"},
+		{3000, 1500, 1500, "K10"},
+		{3000, -100, 3100, "A0"},
+		{3000, 3100, -100, "T19"},
+		{4000, 0, 4000, "A0"},
+		{4000, 3999, 1, "AA26"},
+		{4000, 2000, 2000, "N13"},
+		{4500, 4499, 4499, "AD0"},
+		{4500, 150, 4350, "B1"},
+		{4500, 149.9, 4350.1, "A0"},
+		{6000, 5854.7, 0, "AO40"},
+	} {
+		m := &Map{Size: test.size}
+		if grid := m.Grid(test.x, test.y); grid != test.expected {
+			t.Error("expected", test.expected, "for", test.x, test.y, "on map size", test.size, "but got", grid)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for column, expected := range map[int]string{0: "A", 10: "K", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA"} {
+		if name := ColumnName(column); name != expected {
+			t.Error("expected", expected, "for column", column, "but got", name)
+		}
+	}
+}
+
+func TestToPixel(t *testing.T) {
+	m := &Map{Size: 4000, Width: 2000, Height: 2000, OceanMargin: 500}
+	for _, test := range []struct {
+		x, y           float64
+		pixelX, pixelY float64
+	}{
+		{0, 0, 500, 1500},
+		{4000, 4000, 1500, 500},
+		{2000, 2000, 1000, 1000},
+		{1000, 3000, 750, 750},
+	} {
+		if pixelX, pixelY := m.ToPixel(test.x, test.y); pixelX != test.pixelX || pixelY != test.pixelY {
+			t.Error("expected", test.pixelX, test.pixelY, "for", test.x, test.y, "but got", pixelX, pixelY)
+		}
+	}
+}
+
+func TestMonumentName(t *testing.T) {
+	for token, expected := range map[string]string{
+		"launchsite":                "Launch Site",
+		"oil_rig_small":             "Oil Rig",
+		"large_oil_rig":             "Large Oil Rig",
+		"airfield_display_name":     "Airfield",
+		"DungeonBase":               "",
+		"DungeonBaseA":              "",
+		"train_tunnel_display_name": "",
+		"new_monument_display_name": "New Monument",
+	} {
+		if name := MonumentName(token); name != expected {
+			t.Error("expected", expected, "for", token, "but got", name)
+		}
+	}
+}
+
+func TestNear(t *testing.T) {
+	m := &Map{Size: 4000, Monuments: []Monument{
+		{Token: "launchsite", X: 1000, Y: 1000},
+		{Token: "oil_rig_small", X: 3500, Y: 3500},
+		{Token: "train_tunnel_display_name", X: 1010, Y: 1010},
+	}}
+	for _, test := range []struct {
+		x, y     float64
+		near     string
+		location string
+	}{
+		{1100, 1000, "near Launch Site", "H20 near Launch Site"},
+		{1010, 1010, "near Launch Site", "G20 near Launch Site"},
+		{1200, 1000, "near Launch Site", "I20 near Launch Site"},
+		{1250, 1000, "", "I20"},
+		{2000, 2000, "", "N13"},
+		{3400, 3600, "near Oil Rig", "W2 near Oil Rig"},
+	} {
+		if near := m.Near(test.x, test.y); near != test.near {
+			t.Error("expected", test.near, "for", test.x, test.y, "but got", near)
+		}
+		if location := m.Location(test.x, test.y); location != test.location {
+			t.Error("expected", test.location, "for", test.x, test.y, "but got", location)
+		}
+	}
+
+	if near := (&Map{Size: 4000}).Near(1000, 1000); near != "" {
+		t.Error("expected nothing to be near on a map without monuments, but got", near)
+	}
+}
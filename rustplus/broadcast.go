@@ -0,0 +1,81 @@
+package rustplus
+
+// Number of broadcasts that can be waiting for a slow listener, before any newer ones are dropped
+const broadcastQueueSize = 256
+
+// broadcastListener delivers broadcasts to a listener in the order they were received, without blocking the read loop
+type broadcastListener struct {
+	channel chan *AppBroadcast
+	queue   chan *AppBroadcast
+	done    chan struct{}
+}
+
+// deliver passes queued broadcasts on to the listener, until the listener is removed
+func (listener *broadcastListener) deliver() {
+	for {
+		select {
+		case <-listener.done:
+			return
+		case broadcast := <-listener.queue:
+			select {
+			case listener.channel <- broadcast:
+			case <-listener.done:
+				return
+			}
+		}
+	}
+}
+
+// AddBroadcastListener adds a channel that receives every AppBroadcast sent by the server
+func (rustplus *RustPlus) AddBroadcastListener(channel chan *AppBroadcast) {
+	rustplus.broadcastMutex.Lock()
+	defer rustplus.broadcastMutex.Unlock()
+
+	listener := &broadcastListener{channel: channel, queue: make(chan *AppBroadcast, broadcastQueueSize), done: make(chan struct{})}
+	rustplus.broadcastListeners = append(rustplus.broadcastListeners, listener)
+	go listener.deliver()
+}
+
+// RemoveBroadcastListener removes a channel previously added with AddBroadcastListener, dropping any broadcasts it didn't receive yet
+func (rustplus *RustPlus) RemoveBroadcastListener(channel chan *AppBroadcast) {
+	rustplus.broadcastMutex.Lock()
+	defer rustplus.broadcastMutex.Unlock()
+
+	for i, listener := range rustplus.broadcastListeners {
+		if listener.channel == channel {
+			close(listener.done)
+			rustplus.broadcastListeners = append(rustplus.broadcastListeners[:i], rustplus.broadcastListeners[i+1:]...)
+			break
+		}
+	}
+}
+
+func (rustplus *RustPlus) handleBroadcast(broadcast *AppBroadcast) {
+	if rustplus.isShuttingDown() {
+		return
+	}
+
+	if broadcast.TeamMessage != nil && broadcast.TeamMessage.Message != nil {
+		rustplus.relayTeamMessage(broadcast.TeamMessage.Message, false)
+	}
+	if broadcast.EntityChanged != nil {
+		rustplus.handleEntityChanged(broadcast.EntityChanged)
+	}
+	if broadcast.TeamChanged != nil && broadcast.TeamChanged.TeamInfo != nil {
+		rustplus.handleTeamChanged(broadcast.TeamChanged.TeamInfo)
+	}
+}
+
+func (rustplus *RustPlus) emitBroadcast(broadcast *AppBroadcast) {
+	rustplus.broadcastMutex.Lock()
+	defer rustplus.broadcastMutex.Unlock()
+
+	// Queue the broadcast for each listener, so a slow listener can't block the read loop or receive broadcasts out of order
+	for _, listener := range rustplus.broadcastListeners {
+		select {
+		case listener.queue <- broadcast:
+		default:
+			rustplus.logger.Warning("Dropping Rust+ broadcast for a listener that isn't keeping up")
+		}
+	}
+}
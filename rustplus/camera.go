@@ -0,0 +1,96 @@
+package rustplus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"strings"
+	"time"
+)
+
+// maxCameraFrames is the maximum number of frames to capture at once
+const maxCameraFrames = 30
+
+// GetCameraImage returns the latest frame of a CCTV camera as a JPEG image
+func (rustplus *RustPlus) GetCameraImage(ctx context.Context, identifier string) ([]byte, error) {
+	identifier = strings.TrimSpace(identifier)
+	if len(identifier) <= 0 {
+		return nil, errors.New("camera identifier is empty")
+	}
+
+	frame, err := rustplus.GetCameraFrame(ctx, identifier, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(frame.GetJpgImage()) <= 0 {
+		return nil, errors.New("camera " + identifier + " did not return an image")
+	}
+	return frame.GetJpgImage(), nil
+}
+
+// RecordCamera captures a sequence of frames from a CCTV camera as JPEG images, waiting for the interval between each frame
+func (rustplus *RustPlus) RecordCamera(ctx context.Context, identifier string, count int, interval time.Duration) ([][]byte, error) {
+	identifier = strings.TrimSpace(identifier)
+	if len(identifier) <= 0 {
+		return nil, errors.New("camera identifier is empty")
+	}
+	if count <= 0 || count > maxCameraFrames {
+		return nil, errors.New("frame count must be between 1 and 30")
+	}
+
+	frames := make([][]byte, 0, count)
+	next := uint32(0)
+	for attempt := 0; len(frames) < count; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		frame, err := rustplus.GetCameraFrame(ctx, identifier, next)
+		if err != nil {
+			return nil, err
+		}
+		if len(frame.GetJpgImage()) > 0 {
+			frames = append(frames, frame.GetJpgImage())
+		}
+		next = frame.GetFrame() + 1
+	}
+
+	return frames, nil
+}
+
+// EncodeCameraGIF combines JPEG frames into an animated GIF, showing each frame for the given delay
+func EncodeCameraGIF(frames [][]byte, delay time.Duration) ([]byte, error) {
+	if len(frames) <= 0 {
+		return nil, errors.New("no frames to encode")
+	}
+
+	animation := &gif.GIF{}
+	for _, data := range frames {
+		frame, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		// GIFs are limited to 256 colors, so dither the frame to a fixed palette
+		paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, frame.Bounds(), frame, frame.Bounds().Min)
+
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, int(delay/(10*time.Millisecond)))
+	}
+
+	var buffer bytes.Buffer
+	if err := gif.EncodeAll(&buffer, animation); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,291 @@
+package rustplus
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+const (
+	// defaultTimePollInterval is how often the in-game time is checked, unless configured otherwise
+	defaultTimePollInterval = 30 * time.Second
+
+	// defaultNightWarning is how long before sunset to warn about nightfall, unless configured otherwise
+	defaultNightWarning = 5 * time.Minute
+)
+
+// Time triggers run when the day/night state changes
+const (
+	// SunsetTrigger runs when night falls
+	SunsetTrigger = "sunset"
+	// SunriseTrigger runs when the sun rises
+	SunriseTrigger = "sunrise"
+)
+
+// Clock is the in-game time of day, along with how fast it passes
+type Clock struct {
+	Time             float64
+	Sunrise          float64
+	Sunset           float64
+	DayLengthMinutes float64
+	TimeScale        float64
+}
+
+// TimeTrigger switches a smart switch (or a group) on or off at sunset or sunrise
+type TimeTrigger struct {
+	ObjectID int
+	Name     string
+	Event    string
+	Value    bool
+}
+
+// clockTracker detects day/night changes by comparing consecutive clocks
+type clockTracker struct {
+	isDay       bool
+	nightWarned bool
+	seeded      bool
+}
+
+// NewClock converts an AppTime to a Clock
+func NewClock(appTime *AppTime) *Clock {
+	return &Clock{
+		Time:             float64(appTime.GetTime()),
+		Sunrise:          float64(appTime.GetSunrise()),
+		Sunset:           float64(appTime.GetSunset()),
+		DayLengthMinutes: float64(appTime.GetDayLengthMinutes()),
+		TimeScale:        float64(appTime.GetTimeScale()),
+	}
+}
+
+// IsDay reports whether the sun is up
+func (clock *Clock) IsDay() bool {
+	return clock.Time >= clock.Sunrise && clock.Time < clock.Sunset
+}
+
+// String returns the time of day as HH:MM
+func (clock *Clock) String() string {
+	minutes := int(math.Floor(clock.Time*60)) % (24 * 60)
+	return twoDigits(minutes/60) + ":" + twoDigits(minutes%60)
+}
+
+// Until returns how long (in real time) it takes until the given in-game hour
+func (clock *Clock) Until(hour float64) time.Duration {
+	hours := math.Mod(hour-clock.Time+24, 24)
+	return time.Duration(hours * float64(clock.hourDuration()))
+}
+
+// UntilSunset returns how long (in real time) it takes until night falls
+func (clock *Clock) UntilSunset() time.Duration {
+	return clock.Until(clock.Sunset)
+}
+
+// UntilSunrise returns how long (in real time) it takes until the sun rises
+func (clock *Clock) UntilSunrise() time.Duration {
+	return clock.Until(clock.Sunrise)
+}
+
+// hourDuration returns how long (in real time) a single in-game hour lasts
+func (clock *Clock) hourDuration() time.Duration {
+	dayLength := clock.DayLengthMinutes
+	if dayLength <= 0 {
+		dayLength = 60
+	}
+	timeScale := clock.TimeScale
+	if timeScale <= 0 {
+		timeScale = 1
+	}
+	return time.Duration(dayLength / 24 / timeScale * float64(time.Minute))
+}
+
+// GetClock returns the current in-game time
+func (rustplus *RustPlus) GetClock(ctx context.Context) (*Clock, error) {
+	appTime, err := rustplus.GetTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewClock(appTime), nil
+}
+
+// AddTimeTrigger makes a smart switch (or a group) turn on or off at sunset or sunrise
+func (rustplus *RustPlus) AddTimeTrigger(name string, event string, value bool) (*TimeTrigger, error) {
+	event = strings.ToLower(strings.TrimSpace(event))
+	if event != SunsetTrigger && event != SunriseTrigger {
+		return nil, errors.New("unknown time trigger: " + event)
+	}
+	if _, err := rustplus.resolveSwitches(name); err != nil {
+		return nil, err
+	}
+
+	// Use the actual name of the entity (or group)
+	if entity, err := rustplus.GetEntity(name); err == nil {
+		name = entity.Name
+	} else if group, err := rustplus.GetEntityGroup(name); err == nil {
+		name = group.Name
+	}
+
+	trigger := &TimeTrigger{Name: name, Event: event, Value: value}
+	if existing, err := loadTimeTrigger(rustplus.Database, name, event); err == nil {
+		trigger.ObjectID = existing.ObjectID
+	} else if err != ErrEntityNotFound {
+		return nil, err
+	}
+	if err := saveTimeTrigger(rustplus.Database, trigger); err != nil {
+		return nil, err
+	}
+
+	return trigger, nil
+}
+
+// RemoveTimeTrigger removes a time trigger
+func (rustplus *RustPlus) RemoveTimeTrigger(name string, event string) error {
+	trigger, err := loadTimeTrigger(rustplus.Database, name, strings.ToLower(strings.TrimSpace(event)))
+	if err != nil {
+		return err
+	}
+	return rustplus.Database.Delete("time_triggers", trigger.ObjectID)
+}
+
+// GetTimeTriggers returns every time trigger, sorted by name
+func (rustplus *RustPlus) GetTimeTriggers() ([]*TimeTrigger, error) {
+	return loadTimeTriggers(rustplus.Database)
+}
+
+// startClockPolling periodically requests the in-game time and emits any day/night changes
+func (rustplus *RustPlus) startClockPolling() {
+	interval := defaultTimePollInterval
+	if seconds, err := strconv.Atoi(os.Getenv("RUSTPLUS_TIME_INTERVAL")); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	rustplus.logger.Trace("Creating Rust+ clock ticker..")
+	ticker := time.NewTicker(interval)
+	defer func() {
+		rustplus.logger.Trace("Stopping Rust+ clock ticker..")
+		ticker.Stop()
+	}()
+
+	for {
+		select {
+		case <-rustplus.stop:
+			return
+		case <-ticker.C:
+		}
+
+		// Skip while we're not connected, the reconnect logic will take care of it
+		if rustplus.connection() == nil {
+			continue
+		}
+
+		clock, err := rustplus.GetClock(WithPriority(context.Background(), PriorityLow))
+		if err != nil {
+			rustplus.logger.Warning("Failed to get time:", err)
+			continue
+		}
+		rustplus.updateClock(clock)
+	}
+}
+
+// updateClock emits the current in-game time along with any day/night changes, and runs the matching time triggers
+func (rustplus *RustPlus) updateClock(clock *Clock) {
+	rustplus.clockMutex.Lock()
+	events := rustplus.clockTracker.update(clock, rustplus.nightWarning)
+	rustplus.clockMutex.Unlock()
+
+	rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: "", Message: clock.String(), Type: eventhandler.ClockType})
+
+	for _, event := range events {
+		rustplus.logger.Info("Day/night change:", event.Message)
+		rustplus.EventHandler.Emit(event)
+
+		switch event.Type {
+		case eventhandler.NightfallType:
+			rustplus.runTimeTriggers(SunsetTrigger)
+		case eventhandler.SunriseType:
+			rustplus.runTimeTriggers(SunriseTrigger)
+		}
+	}
+}
+
+// runTimeTriggers switches every smart switch (or group) with a trigger for the given event
+func (rustplus *RustPlus) runTimeTriggers(event string) {
+	triggers, err := rustplus.GetTimeTriggers()
+	if err != nil {
+		rustplus.logger.Error("Failed to load time triggers:", err)
+		return
+	}
+
+	for _, trigger := range triggers {
+		if trigger.Event != event {
+			continue
+		}
+		if _, err := rustplus.SetSwitch(context.Background(), trigger.Name, trigger.Value); err != nil {
+			rustplus.logger.Warning("Failed to run", event, "trigger for", trigger.Name+":", err)
+		}
+	}
+}
+
+// resetClock forgets the day/night state, so that changes made while we were disconnected aren't announced
+func (rustplus *RustPlus) resetClock() {
+	rustplus.clockMutex.Lock()
+	defer rustplus.clockMutex.Unlock()
+
+	rustplus.clockTracker = &clockTracker{}
+}
+
+// update compares the clock with the previous one and returns the resulting events
+// (the first update only records whether it's day or night, as we don't know when it changed)
+func (tracker *clockTracker) update(clock *Clock, nightWarning time.Duration) []eventhandler.Message {
+	events := make([]eventhandler.Message, 0)
+	isDay := clock.IsDay()
+
+	if !tracker.seeded {
+		tracker.seeded = true
+		tracker.isDay = isDay
+		tracker.nightWarned = isDay && clock.UntilSunset() <= nightWarning
+		return events
+	}
+
+	if isDay && !tracker.isDay {
+		events = append(events, worldEvent(eventhandler.SunriseType, "The sun is rising"))
+		tracker.nightWarned = false
+	} else if !isDay && tracker.isDay {
+		events = append(events, worldEvent(eventhandler.NightfallType, "Night has fallen"))
+	}
+	tracker.isDay = isDay
+
+	if isDay && !tracker.nightWarned && clock.UntilSunset() <= nightWarning {
+		events = append(events, worldEvent(eventhandler.NightfallSoonType, "Night falls in "+FormatDuration(clock.UntilSunset().Round(time.Minute))))
+		tracker.nightWarned = true
+	}
+
+	return events
+}
+
+// parseNightWarning parses how long before sunset to warn about nightfall (zero disables the warning)
+func parseNightWarning(value string) (time.Duration, error) {
+	if len(strings.TrimSpace(value)) <= 0 {
+		return defaultNightWarning, nil
+	}
+	warning, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	if warning < 0 {
+		return 0, errors.New("warning must not be negative")
+	}
+	return warning, nil
+}
+
+// twoDigits formats a number with a leading zero
+func twoDigits(value int) string {
+	if value < 10 {
+		return "0" + strconv.Itoa(value)
+	}
+	return strconv.Itoa(value)
+}
@@ -0,0 +1,153 @@
+package rustplus
+
+import (
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/gorilla/websocket"
+	"github.com/sacOO7/gowebsocket"
+)
+
+// Time to wait before attempting to reconnect to the server
+const reconnectDelay = 30 * time.Second
+
+// connect connects to the server with a new websocket client, as the client updates its state from the reader without any locking
+// (reusing it would race with the reader of the previous connection, whose late callbacks could then carry the new connection)
+func (rustplus *RustPlus) connect() {
+	rustplus.connMutex.Lock()
+	rustplus.generation++
+	generation := rustplus.generation
+	rustplus.connMutex.Unlock()
+
+	socket := gowebsocket.New(rustplus.url)
+	socket.OnConnected = func(socket gowebsocket.Socket) {
+		rustplus.handleConnect(generation, socket.Conn)
+	}
+	socket.OnDisconnected = func(err error, socket gowebsocket.Socket) {
+		rustplus.handleDisconnect(generation, err)
+	}
+	socket.OnConnectError = func(err error, socket gowebsocket.Socket) {
+		rustplus.handleConnectError(err)
+	}
+	socket.OnBinaryMessage = rustplus.handleBinaryMessage
+	socket.Connect()
+}
+
+func (rustplus *RustPlus) handleConnect(generation uint64, conn *websocket.Conn) {
+	// We may have been closed (or started connecting again) while connecting
+	rustplus.connMutex.Lock()
+	if rustplus.isShuttingDown() || generation != rustplus.generation {
+		rustplus.connMutex.Unlock()
+		rustplus.logger.Warning("Discarding a Rust+ connection that is no longer needed")
+		conn.Close()
+		return
+	}
+	rustplus.conn = conn
+	rustplus.connGeneration = generation
+	rustplus.connMutex.Unlock()
+
+	rustplus.logger.Info("Connected to Rust+ server")
+
+	// Send server connected message to Discord
+	rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: "", Message: "Connected to Rust+", Type: eventhandler.ServerConnectedType})
+
+	// The server may have wiped while we were disconnected
+	rustplus.clearMapCache()
+	rustplus.resetEvents()
+	rustplus.resetTeam()
+	rustplus.resetClock()
+
+	// Catch up on anything we missed while disconnected
+	rustplus.startTask(rustplus.backfillTeamChat)
+	rustplus.startTask(rustplus.refreshEntities)
+	rustplus.startTask(rustplus.refreshTeam)
+}
+
+func (rustplus *RustPlus) handleDisconnect(generation uint64, err error) {
+	// Only the current connection counts, as the client may report the same disconnect twice
+	// (and a previous connection may report its disconnect late, or we may have closed it ourselves)
+	rustplus.connMutex.Lock()
+	active := rustplus.conn != nil && rustplus.connGeneration == generation
+	if active {
+		rustplus.conn = nil
+	}
+	rustplus.connMutex.Unlock()
+	if !active {
+		return
+	}
+
+	// Any requests still waiting for a response will never get one
+	rustplus.cancelPendingRequests()
+
+	if rustplus.isShuttingDown() {
+		return
+	}
+
+	rustplus.logger.Info("Disconnected from Rust+ server", err)
+
+	// When a disconnect error occurs, this means that we didn't gracefully shutdown, but the connection was lost etc.
+	if err != nil {
+		// Send server disconnected message to Discord
+		rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: "", Message: "Disconnected from Rust+!", Type: eventhandler.ServerDisconnectedType})
+
+		rustplus.scheduleReconnect()
+	}
+}
+
+func (rustplus *RustPlus) handleConnectError(err error) {
+	if rustplus.isShuttingDown() {
+		rustplus.logger.Warning("Already shutting down!")
+		return
+	}
+
+	rustplus.logger.Warning("Could not connect to Rust+ server", err)
+
+	rustplus.scheduleReconnect()
+}
+
+// scheduleReconnect attempts to connect again after a delay, ignoring any duplicate calls in the meantime
+func (rustplus *RustPlus) scheduleReconnect() {
+	rustplus.reconnectMutex.Lock()
+	defer rustplus.reconnectMutex.Unlock()
+	if rustplus.isReconnecting {
+		return
+	}
+	rustplus.isReconnecting = true
+
+	rustplus.logger.Info("Reconnecting to Rust+ server in", reconnectDelay)
+	time.AfterFunc(reconnectDelay, func() {
+		rustplus.reconnectMutex.Lock()
+		rustplus.isReconnecting = false
+		rustplus.reconnectMutex.Unlock()
+
+		if rustplus.isShuttingDown() {
+			return
+		}
+		rustplus.connect()
+	})
+}
+
+// connection returns the current websocket connection, or nil when we're not connected
+// (gowebsocket updates its own connection state without any locking, so we keep track of it ourselves)
+func (rustplus *RustPlus) connection() *websocket.Conn {
+	rustplus.connMutex.Lock()
+	defer rustplus.connMutex.Unlock()
+	return rustplus.conn
+}
+
+// closeConnection gracefully closes the current connection, which makes the socket's reader report the disconnect
+func (rustplus *RustPlus) closeConnection() {
+	rustplus.connMutex.Lock()
+	conn := rustplus.conn
+	rustplus.conn = nil
+	rustplus.connMutex.Unlock()
+
+	// Only close the connection if we actually managed to connect
+	if conn == nil {
+		return
+	}
+	rustplus.writeMutex.Lock()
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+	rustplus.writeMutex.Unlock()
+	conn.Close()
+}
@@ -0,0 +1,148 @@
+package rustplus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+// ErrCredentialNotFound is returned when a Discord user hasn't registered any credentials
+var ErrCredentialNotFound = errors.New("no Rust+ credentials have been registered")
+
+// Credential is a pair of Rust+ player credentials, registered by a Discord user (or configured with environment variables)
+type Credential struct {
+	ObjectID    int
+	DiscordID   string
+	PlayerID    uint64
+	PlayerToken int32
+	Valid       bool
+	Error       string
+	Registered  time.Time
+}
+
+// RegisterCredential verifies a Discord user's player credentials with the server and stores them, replacing any previous ones
+func (rustplus *RustPlus) RegisterCredential(ctx context.Context, discordID string, playerID uint64, playerToken int32) (*Credential, error) {
+	if len(discordID) <= 0 {
+		return nil, errors.New("Discord user ID is empty")
+	}
+	if playerID <= 0 {
+		return nil, errors.New("invalid player ID")
+	}
+
+	credential := &Credential{DiscordID: discordID, PlayerID: playerID, PlayerToken: playerToken}
+	if _, err := rustplus.sendRequestAs(ctx, &AppRequest{GetInfo: &AppEmpty{}}, credential); err != nil {
+		if IsError(err, AccessDeniedError) || IsError(err, NoPlayerError) || IsError(err, BannedError) {
+			return nil, errors.New("the server rejected these credentials (" + err.(*Error).Message + ")")
+		}
+		return nil, err
+	}
+	credential.Valid = true
+	credential.Registered = time.Now()
+
+	// Replace the existing credentials of the user
+	if existing, err := loadCredential(rustplus.Database, discordID); err == nil {
+		credential.ObjectID = existing.ObjectID
+	} else if err != ErrCredentialNotFound {
+		return nil, err
+	}
+	if err := saveCredential(rustplus.Database, credential); err != nil {
+		return nil, err
+	}
+
+	rustplus.logger.Info("Registered Rust+ credentials for Discord user", discordID)
+	return credential, nil
+}
+
+// UnregisterCredential removes a Discord user's player credentials
+func (rustplus *RustPlus) UnregisterCredential(discordID string) error {
+	credential, err := loadCredential(rustplus.Database, discordID)
+	if err != nil {
+		return err
+	}
+	return rustplus.Database.Delete("credentials", credential.ObjectID)
+}
+
+// GetCredential returns a Discord user's player credentials
+func (rustplus *RustPlus) GetCredential(discordID string) (*Credential, error) {
+	return loadCredential(rustplus.Database, discordID)
+}
+
+// validCredentials returns every valid credential in the order they should be used:
+// registered credentials first (longest registered first), followed by the ones from the environment variables
+func (rustplus *RustPlus) validCredentials() ([]*Credential, error) {
+	credentials, err := loadCredentials(rustplus.Database)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].Registered.Before(credentials[j].Registered)
+	})
+
+	valid := make([]*Credential, 0, len(credentials)+1)
+	for _, credential := range credentials {
+		if credential.Valid {
+			valid = append(valid, credential)
+		}
+	}
+
+	rustplus.credentialMutex.Lock()
+	if rustplus.PlayerID > 0 && !rustplus.isEnvCredentialInvalid {
+		valid = append(valid, &Credential{PlayerID: rustplus.PlayerID, PlayerToken: rustplus.PlayerToken, Valid: true})
+	}
+	rustplus.credentialMutex.Unlock()
+
+	if len(valid) <= 0 {
+		return nil, ErrNoCredentials
+	}
+	return valid, nil
+}
+
+// isBotPlayer reports whether the bot may send messages as a player, ie. the player's credentials are either configured or registered
+// (including invalid ones, as they may have been rejected right after sending something)
+func (rustplus *RustPlus) isBotPlayer(playerID uint64) bool {
+	if playerID <= 0 {
+		return false
+	}
+	if playerID == rustplus.PlayerID {
+		return true
+	}
+
+	credentials, err := loadCredentials(rustplus.Database)
+	if err != nil {
+		rustplus.logger.Error("Failed to load Rust+ credentials:", err)
+		return false
+	}
+	for _, credential := range credentials {
+		if credential.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidateCredential marks credentials as invalid after the server rejected them, notifying the user who registered them
+func (rustplus *RustPlus) invalidateCredential(credential *Credential, message string) {
+	rustplus.logger.Warning("Rust+ credentials of player", credential.PlayerID, "were rejected:", message)
+
+	if len(credential.DiscordID) <= 0 {
+		rustplus.credentialMutex.Lock()
+		rustplus.isEnvCredentialInvalid = true
+		rustplus.credentialMutex.Unlock()
+		return
+	}
+
+	credential.Valid = false
+	credential.Error = message
+	if err := saveCredential(rustplus.Database, credential); err != nil {
+		rustplus.logger.Error("Failed to invalidate Rust+ credentials:", err)
+	}
+	rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: credential.DiscordID, Message: "Your Rust+ credentials were rejected by the server (" + message + "), please register new ones", Type: eventhandler.CredentialInvalidType})
+}
+
+// isCredentialError reports whether an AppError means that the player credentials are no longer valid
+func isCredentialError(message string) bool {
+	return message == AccessDeniedError || message == NoPlayerError || message == BannedError
+}
@@ -0,0 +1,430 @@
+package rustplus
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dids/rustbot/database"
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+// stateCollection is where single persisted values are stored (see database.GetState)
+const stateCollection = "rustplus_state"
+
+func saveEntity(database *database.Database, entity *Entity) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	objectID, err := database.Set("entities", entity.ObjectID, map[string]interface{}{
+		"Key":      strings.ToLower(entity.Name),
+		"Name":     entity.Name,
+		"EntityID": entity.EntityID,
+		"Type":     entity.Type.String(),
+		"Value":    entity.Value,
+
+		"HasProtection":    entity.HasProtection,
+		"ProtectionExpiry": entity.ProtectionExpiry,
+	})
+	if err != nil {
+		return err
+	}
+	entity.ObjectID = objectID
+
+	return nil
+}
+
+func loadEntity(database *database.Database, name string) (*Entity, error) {
+	objectID, object, err := queryByKey(database, "entities", name)
+	if err != nil {
+		return nil, err
+	}
+	return entityFromObject(objectID, object), nil
+}
+
+func loadEntityByID(database *database.Database, entityID uint32) (*Entity, error) {
+	entities, err := loadEntities(database)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		if entity.EntityID == entityID {
+			return entity, nil
+		}
+	}
+	return nil, ErrEntityNotFound
+}
+
+func loadEntities(database *database.Database) ([]*Entity, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	objects, err := database.Query("entities", `"all"`)
+	if err != nil {
+		return nil, err
+	}
+	entities := make([]*Entity, 0, len(objects))
+	for objectID, object := range objects {
+		entities = append(entities, entityFromObject(objectID, object))
+	}
+	return entities, nil
+}
+
+func entityFromObject(objectID int, object map[string]interface{}) *Entity {
+	entity := &Entity{ObjectID: objectID}
+	entity.Name, _ = object["Name"].(string)
+	entity.EntityID = uint32(toFloat(object["EntityID"]))
+	if typeName, ok := object["Type"].(string); ok {
+		entity.Type = AppEntityType(AppEntityType_value[typeName])
+	}
+	entity.Value, _ = object["Value"].(bool)
+	entity.HasProtection, _ = object["HasProtection"].(bool)
+	entity.ProtectionExpiry = uint32(toFloat(object["ProtectionExpiry"]))
+	return entity
+}
+
+func saveEntityGroup(database *database.Database, group *EntityGroup) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	entities := make([]interface{}, len(group.Entities))
+	for i, name := range group.Entities {
+		entities[i] = name
+	}
+	objectID, err := database.Set("entity_groups", group.ObjectID, map[string]interface{}{
+		"Key":      strings.ToLower(group.Name),
+		"Name":     group.Name,
+		"Entities": entities,
+	})
+	if err != nil {
+		return err
+	}
+	group.ObjectID = objectID
+
+	return nil
+}
+
+func loadEntityGroup(database *database.Database, name string) (*EntityGroup, error) {
+	objectID, object, err := queryByKey(database, "entity_groups", name)
+	if err != nil {
+		return nil, err
+	}
+	return entityGroupFromObject(objectID, object), nil
+}
+
+func loadEntityGroups(database *database.Database) ([]*EntityGroup, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	objects, err := database.Query("entity_groups", `"all"`)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*EntityGroup, 0, len(objects))
+	for objectID, object := range objects {
+		groups = append(groups, entityGroupFromObject(objectID, object))
+	}
+	return groups, nil
+}
+
+func entityGroupFromObject(objectID int, object map[string]interface{}) *EntityGroup {
+	group := &EntityGroup{ObjectID: objectID}
+	group.Name, _ = object["Name"].(string)
+	if entities, ok := object["Entities"].([]interface{}); ok {
+		for _, entity := range entities {
+			if name, ok := entity.(string); ok {
+				group.Entities = append(group.Entities, name)
+			}
+		}
+	}
+	return group
+}
+
+func saveStorageSnapshot(database *database.Database, snapshot *StorageSnapshot) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	items := make([]interface{}, len(snapshot.Items))
+	for i, item := range snapshot.Items {
+		items[i] = map[string]interface{}{
+			"ItemID":    item.ItemID,
+			"Quantity":  item.Quantity,
+			"Blueprint": item.Blueprint,
+		}
+	}
+	objectID, err := database.Set("storage_snapshots", snapshot.ObjectID, map[string]interface{}{
+		"Key":      strings.ToLower(snapshot.Name),
+		"Name":     snapshot.Name,
+		"Time":     snapshot.Time.UnixMilli(),
+		"Items":    items,
+		"Capacity": snapshot.Capacity,
+	})
+	if err != nil {
+		return err
+	}
+	snapshot.ObjectID = objectID
+
+	return nil
+}
+
+// loadStorageSnapshots returns every stored snapshot of a storage monitor, oldest first
+func loadStorageSnapshots(database *database.Database, name string) ([]*StorageSnapshot, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	objects, err := database.Query("storage_snapshots", `[{"eq": `+strconv.Quote(strings.ToLower(strings.TrimSpace(name)))+`, "in": ["Key"]}]`)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*StorageSnapshot, 0, len(objects))
+	for objectID, object := range objects {
+		snapshots = append(snapshots, storageSnapshotFromObject(objectID, object))
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Time.Equal(snapshots[j].Time) {
+			return snapshots[i].ObjectID < snapshots[j].ObjectID
+		}
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+func storageSnapshotFromObject(objectID int, object map[string]interface{}) *StorageSnapshot {
+	snapshot := &StorageSnapshot{ObjectID: objectID}
+	snapshot.Name, _ = object["Name"].(string)
+	snapshot.Time = time.UnixMilli(int64(toFloat(object["Time"])))
+	snapshot.Capacity = int32(toFloat(object["Capacity"]))
+	if items, ok := object["Items"].([]interface{}); ok {
+		for _, item := range items {
+			if fields, ok := item.(map[string]interface{}); ok {
+				blueprint, _ := fields["Blueprint"].(bool)
+				snapshot.Items = append(snapshot.Items, StorageItem{
+					ItemID:    int32(toFloat(fields["ItemID"])),
+					Quantity:  int32(toFloat(fields["Quantity"])),
+					Blueprint: blueprint,
+				})
+			}
+		}
+	}
+	return snapshot
+}
+
+func saveCredential(database *database.Database, credential *Credential) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	// Steam IDs are too large to be stored as (floating point) numbers
+	objectID, err := database.Set("credentials", credential.ObjectID, map[string]interface{}{
+		"Key":         credential.DiscordID,
+		"DiscordID":   credential.DiscordID,
+		"PlayerID":    strconv.FormatUint(credential.PlayerID, 10),
+		"PlayerToken": credential.PlayerToken,
+		"Valid":       credential.Valid,
+		"Error":       credential.Error,
+		"Registered":  credential.Registered.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+	credential.ObjectID = objectID
+
+	return nil
+}
+
+func loadCredential(database *database.Database, discordID string) (*Credential, error) {
+	objectID, object, err := queryByKey(database, "credentials", discordID)
+	if err == ErrEntityNotFound {
+		return nil, ErrCredentialNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return credentialFromObject(objectID, object), nil
+}
+
+func loadCredentials(database *database.Database) ([]*Credential, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	objects, err := database.Query("credentials", `"all"`)
+	if err != nil {
+		return nil, err
+	}
+	credentials := make([]*Credential, 0, len(objects))
+	for objectID, object := range objects {
+		credentials = append(credentials, credentialFromObject(objectID, object))
+	}
+	return credentials, nil
+}
+
+func credentialFromObject(objectID int, object map[string]interface{}) *Credential {
+	credential := &Credential{ObjectID: objectID}
+	credential.DiscordID, _ = object["DiscordID"].(string)
+	if playerID, ok := object["PlayerID"].(string); ok {
+		credential.PlayerID, _ = strconv.ParseUint(playerID, 10, 64)
+	}
+	credential.PlayerToken = int32(toFloat(object["PlayerToken"]))
+	credential.Valid, _ = object["Valid"].(bool)
+	credential.Error, _ = object["Error"].(string)
+	credential.Registered = time.UnixMilli(int64(toFloat(object["Registered"])))
+	return credential
+}
+
+func saveTeamEvent(database *database.Database, event *TeamEvent) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	objectID, err := database.Set("team_events", event.ObjectID, map[string]interface{}{
+		"Key":     strconv.FormatUint(event.SteamID, 10),
+		"SteamID": strconv.FormatUint(event.SteamID, 10),
+		"Name":    event.Name,
+		"Type":    string(event.Type),
+		"Message": event.Message,
+		"Time":    event.Time.UnixMilli(),
+	})
+	if err != nil {
+		return err
+	}
+	event.ObjectID = objectID
+
+	return nil
+}
+
+// loadTeamEvents returns every stored team event, oldest first
+func loadTeamEvents(database *database.Database) ([]*TeamEvent, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	objects, err := database.Query("team_events", `"all"`)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*TeamEvent, 0, len(objects))
+	for objectID, object := range objects {
+		event := &TeamEvent{ObjectID: objectID}
+		if steamID, ok := object["SteamID"].(string); ok {
+			event.SteamID, _ = strconv.ParseUint(steamID, 10, 64)
+		}
+		event.Name, _ = object["Name"].(string)
+		if eventType, ok := object["Type"].(string); ok {
+			event.Type = eventhandler.MessageType(eventType)
+		}
+		event.Message, _ = object["Message"].(string)
+		event.Time = time.UnixMilli(int64(toFloat(object["Time"])))
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Time.Equal(events[j].Time) {
+			return events[i].ObjectID < events[j].ObjectID
+		}
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
+
+func saveTimeTrigger(database *database.Database, trigger *TimeTrigger) error {
+	if database == nil || database.Client == nil {
+		return errors.New("Database is nil")
+	}
+
+	objectID, err := database.Set("time_triggers", trigger.ObjectID, map[string]interface{}{
+		"Key":   strings.ToLower(trigger.Name) + ":" + trigger.Event,
+		"Name":  trigger.Name,
+		"Event": trigger.Event,
+		"Value": trigger.Value,
+	})
+	if err != nil {
+		return err
+	}
+	trigger.ObjectID = objectID
+
+	return nil
+}
+
+func loadTimeTrigger(database *database.Database, name string, event string) (*TimeTrigger, error) {
+	objectID, object, err := queryByKey(database, "time_triggers", strings.TrimSpace(name)+":"+event)
+	if err != nil {
+		return nil, err
+	}
+	return timeTriggerFromObject(objectID, object), nil
+}
+
+// loadTimeTriggers returns every time trigger, sorted by name and event
+func loadTimeTriggers(database *database.Database) ([]*TimeTrigger, error) {
+	if database == nil || database.Client == nil {
+		return nil, errors.New("Database is nil")
+	}
+
+	objects, err := database.Query("time_triggers", `"all"`)
+	if err != nil {
+		return nil, err
+	}
+	triggers := make([]*TimeTrigger, 0, len(objects))
+	for objectID, object := range objects {
+		triggers = append(triggers, timeTriggerFromObject(objectID, object))
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		if !strings.EqualFold(triggers[i].Name, triggers[j].Name) {
+			return strings.ToLower(triggers[i].Name) < strings.ToLower(triggers[j].Name)
+		}
+		return triggers[i].Event < triggers[j].Event
+	})
+	return triggers, nil
+}
+
+func timeTriggerFromObject(objectID int, object map[string]interface{}) *TimeTrigger {
+	trigger := &TimeTrigger{ObjectID: objectID}
+	trigger.Name, _ = object["Name"].(string)
+	trigger.Event, _ = object["Event"].(string)
+	trigger.Value, _ = object["Value"].(bool)
+	return trigger
+}
+
+// queryByKey returns the object whose (lowercase) "Key" field matches the given name
+func queryByKey(database *database.Database, collection string, name string) (int, map[string]interface{}, error) {
+	if database == nil || database.Client == nil {
+		return 0, nil, errors.New("Database is nil")
+	}
+
+	matches, err := database.Query(collection, `[{"eq": `+strconv.Quote(strings.ToLower(strings.TrimSpace(name)))+`, "in": ["Key"]}]`)
+	if err != nil {
+		return 0, nil, err
+	}
+	for objectID, object := range matches {
+		return objectID, object, nil
+	}
+
+	return 0, nil, ErrEntityNotFound
+}
+
+// toFloat converts a stored number to a float64 (JSON unmarshaling converts all numbers to floats)
+func toFloat(value interface{}) float64 {
+	switch number := value.(type) {
+	case float64:
+		return number
+	case float32:
+		return float64(number)
+	case int:
+		return float64(number)
+	case int32:
+		return float64(number)
+	case int64:
+		return float64(number)
+	case uint32:
+		return float64(number)
+	case uint64:
+		return float64(number)
+	}
+	return 0
+}
@@ -0,0 +1,306 @@
+package rustplus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+// ErrEntityNotFound is returned when no entity (or group) has been registered with the given name
+var ErrEntityNotFound = errors.New("no paired entity or group with that name")
+
+// Entity is a paired smart device (switch, alarm or storage monitor) with a friendly name
+type Entity struct {
+	ObjectID int
+	Name     string
+	EntityID uint32
+	Type     AppEntityType
+	Value    bool
+
+	// Tool cupboard protection (only reported by storage monitors attached to a tool cupboard)
+	HasProtection    bool
+	ProtectionExpiry uint32
+}
+
+// EntityGroup is a named set of entities that can be switched at once
+type EntityGroup struct {
+	ObjectID int
+	Name     string
+	Entities []string
+}
+
+// AddEntity pairs a new entity with a friendly name, verifying that it exists on the server
+func (rustplus *RustPlus) AddEntity(ctx context.Context, name string, entityID uint32) (*Entity, error) {
+	name = strings.TrimSpace(name)
+	if len(name) <= 0 {
+		return nil, errors.New("entity name is empty")
+	}
+	if existing, _ := rustplus.GetEntity(name); existing != nil {
+		return nil, errors.New("an entity named " + existing.Name + " already exists")
+	}
+
+	// Verify the entity (this also subscribes us to its AppEntityChanged broadcasts)
+	info, err := rustplus.GetEntityInfo(ctx, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	entity := &Entity{Name: name, EntityID: entityID, Type: info.Type}
+	if err := rustplus.updateEntity(entity, info.GetPayload()); err != nil {
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+// RemoveEntity unpairs an entity and removes it from every group
+func (rustplus *RustPlus) RemoveEntity(name string) error {
+	entity, err := rustplus.GetEntity(name)
+	if err != nil {
+		return err
+	}
+
+	groups, err := rustplus.GetEntityGroups()
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err := rustplus.RemoveEntityFromGroup(group.Name, entity.Name); err != nil && err != ErrEntityNotFound {
+			return err
+		}
+	}
+
+	return rustplus.Database.Delete("entities", entity.ObjectID)
+}
+
+// GetEntity returns a paired entity by its name
+func (rustplus *RustPlus) GetEntity(name string) (*Entity, error) {
+	return loadEntity(rustplus.Database, name)
+}
+
+// GetEntities returns every paired entity, sorted by name
+func (rustplus *RustPlus) GetEntities() ([]*Entity, error) {
+	entities, err := loadEntities(rustplus.Database)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entities, func(i, j int) bool {
+		return strings.ToLower(entities[i].Name) < strings.ToLower(entities[j].Name)
+	})
+	return entities, nil
+}
+
+// RefreshEntity fetches the current state of a paired entity from the server
+func (rustplus *RustPlus) RefreshEntity(ctx context.Context, name string) (*Entity, error) {
+	entity, err := rustplus.GetEntity(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := rustplus.GetEntityInfo(ctx, entity.EntityID)
+	if err != nil {
+		return nil, err
+	}
+
+	entity.Type = info.Type
+	if err := rustplus.updateEntity(entity, info.GetPayload()); err != nil {
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+// SetSwitch turns a smart switch, or every smart switch in a group, on or off
+func (rustplus *RustPlus) SetSwitch(ctx context.Context, name string, value bool) ([]*Entity, error) {
+	entities, err := rustplus.resolveSwitches(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range entities {
+		if err := rustplus.SetEntityValue(ctx, entity.EntityID, value); err != nil {
+			return nil, errors.New("failed to switch " + entity.Name + ": " + err.Error())
+		}
+		entity.Value = value
+		if err := saveEntity(rustplus.Database, entity); err != nil {
+			return nil, err
+		}
+	}
+
+	return entities, nil
+}
+
+// ToggleSwitch flips a smart switch, or turns a group off if any of its switches are on (and on otherwise)
+func (rustplus *RustPlus) ToggleSwitch(ctx context.Context, name string) ([]*Entity, error) {
+	entities, err := rustplus.resolveSwitches(name)
+	if err != nil {
+		return nil, err
+	}
+
+	value := true
+	for _, entity := range entities {
+		if entity.Value {
+			value = false
+			break
+		}
+	}
+
+	return rustplus.SetSwitch(ctx, name, value)
+}
+
+// resolveSwitches returns the smart switch with the given name, or the smart switches in the group with the given name
+func (rustplus *RustPlus) resolveSwitches(name string) ([]*Entity, error) {
+	entities := make([]*Entity, 0)
+	if entity, err := rustplus.GetEntity(name); err == nil {
+		entities = append(entities, entity)
+	} else if err != ErrEntityNotFound {
+		return nil, err
+	} else {
+		group, err := rustplus.GetEntityGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entityName := range group.Entities {
+			entity, err := rustplus.GetEntity(entityName)
+			if err != nil {
+				return nil, err
+			}
+			entities = append(entities, entity)
+		}
+	}
+
+	for _, entity := range entities {
+		if entity.Type != AppEntityType_Switch {
+			return nil, errors.New(entity.Name + " is not a smart switch")
+		}
+	}
+
+	return entities, nil
+}
+
+// AddEntityToGroup adds a paired entity to a group, creating the group if it doesn't exist yet
+func (rustplus *RustPlus) AddEntityToGroup(groupName string, name string) error {
+	groupName = strings.TrimSpace(groupName)
+	if len(groupName) <= 0 {
+		return errors.New("group name is empty")
+	}
+
+	entity, err := rustplus.GetEntity(name)
+	if err != nil {
+		return err
+	}
+
+	group, err := rustplus.GetEntityGroup(groupName)
+	if err == ErrEntityNotFound {
+		group = &EntityGroup{Name: groupName}
+	} else if err != nil {
+		return err
+	}
+
+	for _, existing := range group.Entities {
+		if strings.EqualFold(existing, entity.Name) {
+			return nil
+		}
+	}
+	group.Entities = append(group.Entities, entity.Name)
+
+	return saveEntityGroup(rustplus.Database, group)
+}
+
+// RemoveEntityFromGroup removes an entity from a group, removing the group itself once it's empty
+func (rustplus *RustPlus) RemoveEntityFromGroup(groupName string, name string) error {
+	group, err := rustplus.GetEntityGroup(groupName)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for i, existing := range group.Entities {
+		if strings.EqualFold(existing, name) {
+			group.Entities = append(group.Entities[:i], group.Entities[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrEntityNotFound
+	}
+
+	if len(group.Entities) <= 0 {
+		return rustplus.Database.Delete("entity_groups", group.ObjectID)
+	}
+	return saveEntityGroup(rustplus.Database, group)
+}
+
+// GetEntityGroup returns an entity group by its name
+func (rustplus *RustPlus) GetEntityGroup(name string) (*EntityGroup, error) {
+	return loadEntityGroup(rustplus.Database, name)
+}
+
+// GetEntityGroups returns every entity group, sorted by name
+func (rustplus *RustPlus) GetEntityGroups() ([]*EntityGroup, error) {
+	groups, err := loadEntityGroups(rustplus.Database)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name)
+	})
+	return groups, nil
+}
+
+// refreshEntities fetches the state of every paired entity, which also (re)subscribes us to their broadcasts
+func (rustplus *RustPlus) refreshEntities() {
+	entities, err := rustplus.GetEntities()
+	if err != nil {
+		rustplus.logger.Error("Failed to load paired entities:", err)
+		return
+	}
+
+	for _, entity := range entities {
+		if _, err := rustplus.RefreshEntity(context.Background(), entity.Name); err != nil {
+			rustplus.logger.Warning("Failed to refresh entity", entity.Name+":", err)
+		}
+	}
+}
+
+func (rustplus *RustPlus) handleEntityChanged(entityChanged *AppEntityChanged) {
+	entity, err := loadEntityByID(rustplus.Database, entityChanged.EntityId)
+	if err != nil {
+		if err != ErrEntityNotFound {
+			rustplus.logger.Error("Failed to load entity", entityChanged.EntityId, err)
+		}
+		return
+	}
+
+	if err := rustplus.updateEntity(entity, entityChanged.GetPayload()); err != nil {
+		rustplus.logger.Error("Failed to update entity", entity.Name+":", err)
+	}
+
+	// Send triggered alarms to Discord
+	if entity.Type == AppEntityType_Alarm && entity.Value {
+		rustplus.logger.Info("Alarm triggered:", entity.Name)
+		rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: entity.Name, Message: entity.Name + " was triggered!", Type: eventhandler.AlarmType})
+	}
+}
+
+// updateEntity stores the latest payload of an entity, keeping track of storage monitor contents and upkeep
+func (rustplus *RustPlus) updateEntity(entity *Entity, payload *AppEntityPayload) error {
+	hadProtection := entity.HasProtection
+
+	entity.Value = payload.GetValue()
+	entity.HasProtection = payload.GetHasProtection()
+	entity.ProtectionExpiry = payload.GetProtectionExpiry()
+	if err := saveEntity(rustplus.Database, entity); err != nil {
+		return err
+	}
+
+	if entity.Type == AppEntityType_StorageMonitor {
+		rustplus.updateStorage(entity, payload, hadProtection)
+	}
+
+	return nil
+}
@@ -0,0 +1,312 @@
+package rustplus
+
+import (
+	"context"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/rustmap"
+)
+
+const (
+	// defaultEventPollInterval is how often map markers are checked for world events, unless configured otherwise
+	defaultEventPollInterval = 10 * time.Second
+
+	// heliDownDistance is how close to the last known position of the patrol helicopter an explosion must be to count as it being taken down
+	heliDownDistance = 300
+
+	// ch47HeadingTolerance is how far off the heading of a CH47 (in radians) an oil rig may be to count as its destination
+	ch47HeadingTolerance = math.Pi / 12
+
+	// ch47OilRigDistance is how close to an oil rig a CH47 must be to count as going there, no matter where it's heading
+	ch47OilRigDistance = 300
+
+	// ch47DropDistance is how close to a CH47 a new locked crate must appear to count as being dropped by it
+	ch47DropDistance = 200
+)
+
+// oilRigTokens are the monument tokens of the oil rigs, where a CH47 drops off scientists instead of a crate
+var oilRigTokens = map[string]bool{"large_oil_rig": true, "oil_rig_small": true}
+
+// directions are the compass directions, starting from east and going counter-clockwise (like angles do)
+var directions = []string{"east", "north-east", "north", "north-west", "west", "south-west", "south", "south-east"}
+
+// trackedMarker is a map marker we've seen, along with where we first saw it
+type trackedMarker struct {
+	marker    *AppMarker
+	firstX    float32
+	firstY    float32
+	announced bool
+	oilRig    bool // Set for a CH47 that's on its way to an oil rig
+}
+
+// markerTracker detects world events by comparing consecutive sets of map markers
+type markerTracker struct {
+	markers map[uint32]*trackedMarker
+	seeded  bool
+}
+
+// startEventPolling periodically requests the map markers and emits any world events
+func (rustplus *RustPlus) startEventPolling() {
+	interval := defaultEventPollInterval
+	if seconds, err := strconv.Atoi(os.Getenv("RUSTPLUS_EVENT_INTERVAL")); err == nil && seconds > 0 {
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	rustplus.logger.Trace("Creating Rust+ event ticker..")
+	ticker := time.NewTicker(interval)
+	defer func() {
+		rustplus.logger.Trace("Stopping Rust+ event ticker..")
+		ticker.Stop()
+	}()
+
+	for {
+		select {
+		case <-rustplus.stop:
+			return
+		case <-ticker.C:
+		}
+
+		// Skip while we're not connected, the reconnect logic will take care of it
+		if rustplus.connection() == nil {
+			continue
+		}
+
+		// Polling shouldn't hold up team chat or commands
+		ctx := WithPriority(context.Background(), PriorityLow)
+		world, err := rustplus.GetWorldMap(ctx)
+		if err != nil {
+			rustplus.logger.Warning("Failed to get map:", err)
+			continue
+		}
+		markers, err := rustplus.GetMapMarkers(ctx)
+		if err != nil {
+			rustplus.logger.Warning("Failed to get map markers:", err)
+			continue
+		}
+
+		rustplus.eventMutex.Lock()
+		events := rustplus.markerTracker.update(markers.GetMarkers(), world)
+		rustplus.eventMutex.Unlock()
+
+		for _, event := range events {
+			rustplus.logger.Info("World event:", event.Message)
+			rustplus.EventHandler.Emit(event)
+		}
+	}
+}
+
+// resetEvents forgets every tracked marker, so that markers already on the map after (re)connecting aren't announced
+func (rustplus *RustPlus) resetEvents() {
+	rustplus.eventMutex.Lock()
+	defer rustplus.eventMutex.Unlock()
+
+	rustplus.markerTracker = &markerTracker{}
+}
+
+// update compares the markers with the previous ones and returns the resulting events
+// (the first update only records the markers, as we don't know when they appeared)
+func (tracker *markerTracker) update(markers []*AppMarker, world *rustmap.Map) []eventhandler.Message {
+	events := make([]eventhandler.Message, 0)
+	if tracker.markers == nil {
+		tracker.markers = make(map[uint32]*trackedMarker)
+	}
+
+	current := make(map[uint32]*AppMarker)
+	for _, marker := range markers {
+		if isEventMarker(marker.GetType()) {
+			current[marker.GetId()] = marker
+		}
+	}
+
+	if !tracker.seeded {
+		tracker.seeded = true
+		for id, marker := range current {
+			tracker.markers[id] = &trackedMarker{marker: marker, firstX: marker.GetX(), firstY: marker.GetY(), announced: true}
+		}
+		return events
+	}
+
+	// New explosions (which may also mean that the patrol helicopter was taken down)
+	explosions := make([]*AppMarker, 0)
+	for id, marker := range current {
+		if _, ok := tracker.markers[id]; !ok && marker.GetType() == AppMarkerType_Explosion {
+			explosions = append(explosions, marker)
+		}
+	}
+
+	// Markers that disappeared
+	for id, tracked := range tracker.markers {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		delete(tracker.markers, id)
+		if !tracked.announced {
+			continue
+		}
+
+		marker := tracked.marker
+		location := world.Location(float64(marker.GetX()), float64(marker.GetY()))
+		switch marker.GetType() {
+		case AppMarkerType_CargoShip:
+			events = append(events, worldEvent(eventhandler.CargoLeftType, "Cargo ship left the map"))
+		case AppMarkerType_CH47:
+			events = append(events, worldEvent(eventhandler.CH47LeftType, "CH47 left the map"))
+		case AppMarkerType_PatrolHelicopter:
+			if explosion := nearestMarker(marker, explosions, heliDownDistance); explosion != nil {
+				explosions = removeMarker(explosions, explosion)
+				location = world.Location(float64(explosion.GetX()), float64(explosion.GetY()))
+				events = append(events, worldEvent(eventhandler.HeliDownType, "Patrol helicopter was taken down at "+location))
+			} else {
+				events = append(events, worldEvent(eventhandler.HeliLeftType, "Patrol helicopter left the map"))
+			}
+		case AppMarkerType_Crate:
+			events = append(events, worldEvent(eventhandler.CrateGoneType, "Locked crate at "+location+" is gone"))
+		}
+	}
+
+	// Markers that appeared, or have moved since they appeared
+	for id, marker := range current {
+		tracked, ok := tracker.markers[id]
+		if !ok {
+			tracked = &trackedMarker{marker: marker, firstX: marker.GetX(), firstY: marker.GetY()}
+			tracker.markers[id] = tracked
+		}
+		tracked.marker = marker
+		if tracked.announced {
+			continue
+		}
+
+		location := world.Location(float64(marker.GetX()), float64(marker.GetY()))
+		switch marker.GetType() {
+		case AppMarkerType_Explosion:
+			tracked.announced = true
+			for _, explosion := range explosions {
+				if explosion == marker {
+					events = append(events, worldEvent(eventhandler.ExplosionType, "Explosion at "+location))
+				}
+			}
+		case AppMarkerType_Crate:
+			tracked.announced = true
+			if tracker.droppedByCH47(marker, current) {
+				events = append(events, worldEvent(eventhandler.CH47AirdropType, "CH47 dropped a locked crate at "+location))
+			} else {
+				events = append(events, worldEvent(eventhandler.CrateSpawnedType, "Locked crate appeared at "+location))
+			}
+		default:
+			// Vehicles are only announced once they've moved, so that we can tell where they're heading
+			if !ok {
+				continue
+			}
+			direction := travelDirection(tracked.firstX, tracked.firstY, marker.GetX(), marker.GetY())
+			if len(direction) <= 0 {
+				continue
+			}
+			tracked.announced = true
+			switch marker.GetType() {
+			case AppMarkerType_CargoShip:
+				events = append(events, worldEvent(eventhandler.CargoSpawnedType, "Cargo ship entered the map at "+location+", heading "+direction))
+			case AppMarkerType_CH47:
+				if oilRig := oilRigAhead(world, tracked.firstX, tracked.firstY, marker.GetX(), marker.GetY()); oilRig != nil {
+					tracked.oilRig = true
+					events = append(events, worldEvent(eventhandler.CH47OilRigType, "CH47 spotted at "+location+", heading to the "+rustmap.MonumentName(oilRig.Token)))
+				} else {
+					events = append(events, worldEvent(eventhandler.CH47SpawnedType, "CH47 spotted at "+location+", heading "+direction))
+				}
+			case AppMarkerType_PatrolHelicopter:
+				events = append(events, worldEvent(eventhandler.HeliSpawnedType, "Patrol helicopter entered the map at "+location+", heading "+direction))
+			}
+		}
+	}
+
+	return events
+}
+
+// droppedByCH47 reports whether a new locked crate was dropped by a CH47, ie. it appeared right next to one that isn't on its way to an oil rig
+func (tracker *markerTracker) droppedByCH47(crate *AppMarker, current map[uint32]*AppMarker) bool {
+	for id, marker := range current {
+		if marker.GetType() != AppMarkerType_CH47 {
+			continue
+		}
+		if tracked, ok := tracker.markers[id]; ok && tracked.oilRig {
+			continue
+		}
+		if math.Hypot(float64(marker.GetX()-crate.GetX()), float64(marker.GetY()-crate.GetY())) <= ch47DropDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// oilRigAhead returns the closest oil rig that a vehicle travelling between two positions is heading to or is already at
+// (or nil if there's no such oil rig)
+func oilRigAhead(world *rustmap.Map, fromX float32, fromY float32, toX float32, toY float32) *rustmap.Monument {
+	heading := math.Atan2(float64(toY-fromY), float64(toX-fromX))
+
+	var ahead *rustmap.Monument
+	aheadDistance := math.Inf(1)
+	for i := range world.Monuments {
+		monument := &world.Monuments[i]
+		if !oilRigTokens[monument.Token] {
+			continue
+		}
+		dx := monument.X - float64(toX)
+		dy := monument.Y - float64(toY)
+		distance := math.Hypot(dx, dy)
+		offset := math.Abs(math.Remainder(math.Atan2(dy, dx)-heading, 2*math.Pi))
+		if (distance <= ch47OilRigDistance || offset <= ch47HeadingTolerance) && distance < aheadDistance {
+			ahead = monument
+			aheadDistance = distance
+		}
+	}
+	return ahead
+}
+
+func worldEvent(messageType eventhandler.MessageType, message string) eventhandler.Message {
+	return eventhandler.Message{Event: "receive_rustplus_message", Message: message, Type: messageType}
+}
+
+func isEventMarker(markerType AppMarkerType) bool {
+	switch markerType {
+	case AppMarkerType_CargoShip, AppMarkerType_CH47, AppMarkerType_PatrolHelicopter, AppMarkerType_Crate, AppMarkerType_Explosion:
+		return true
+	}
+	return false
+}
+
+// travelDirection returns the compass direction between two world positions (or an empty string if they're the same)
+func travelDirection(fromX float32, fromY float32, toX float32, toY float32) string {
+	dx := float64(toX - fromX)
+	dy := float64(toY - fromY)
+	if math.Abs(dx) < 1 && math.Abs(dy) < 1 {
+		return ""
+	}
+	angle := math.Atan2(dy, dx)
+	index := int(math.Round(angle/(math.Pi/4))+8) % 8
+	return directions[index]
+}
+
+// nearestMarker returns the closest of the markers within the maximum distance (or nil if there are none)
+func nearestMarker(target *AppMarker, markers []*AppMarker, maxDistance float64) *AppMarker {
+	var nearest *AppMarker
+	for _, marker := range markers {
+		distance := math.Hypot(float64(marker.GetX()-target.GetX()), float64(marker.GetY()-target.GetY()))
+		if distance <= maxDistance {
+			nearest = marker
+			maxDistance = distance
+		}
+	}
+	return nearest
+}
+
+func removeMarker(markers []*AppMarker, target *AppMarker) []*AppMarker {
+	for i, marker := range markers {
+		if marker == target {
+			return append(markers[:i], markers[i+1:]...)
+		}
+	}
+	return markers
+}
@@ -0,0 +1,660 @@
+package rustplus_test
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dids/rustbot/database"
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/rustplus"
+	"github.com/Dids/rustbot/rustplus/rustplustest"
+)
+
+// Credentials of the player the bot runs as
+const (
+	testPlayerID    uint64 = 76561197960287930
+	testPlayerToken int32  = 1234
+)
+
+// Maximum time to wait for asynchronous messages and state changes
+const testTimeout = 5 * time.Second
+
+// testClient is a Rust+ client connected to a mock server, along with every message it sends to Discord
+type testClient struct {
+	*rustplus.RustPlus
+	server   *rustplustest.Server
+	handler  *eventhandler.EventHandler
+	messages chan eventhandler.Message
+}
+
+// newTestClient connects a new Rust+ client (with an empty database) to the mock server
+func newTestClient(t *testing.T, server *rustplustest.Server) *testClient {
+	return newTestClientWithEnvCredential(t, server, true)
+}
+
+// newTestClientWithEnvCredential connects a new Rust+ client to the mock server, optionally without the credentials from the environment variables
+// (leaving it without any credentials until some are registered)
+func newTestClientWithEnvCredential(t *testing.T, server *rustplustest.Server, envCredential bool) *testClient {
+	db, err := database.NewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(db.Path)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("RUSTPLUS_HOST", server.Host)
+	t.Setenv("RUSTPLUS_PORT", server.Port)
+	if envCredential {
+		t.Setenv("RUSTPLUS_PLAYER_ID", "76561197960287930")
+		t.Setenv("RUSTPLUS_PLAYER_TOKEN", "1234")
+	} else {
+		t.Setenv("RUSTPLUS_PLAYER_ID", "")
+		t.Setenv("RUSTPLUS_PLAYER_TOKEN", "")
+	}
+	t.Setenv("RUSTPLUS_EVENT_INTERVAL", "1")
+	t.Setenv("RUSTPLUS_TIME_INTERVAL", "1")
+
+	client := &testClient{server: server, handler: &eventhandler.EventHandler{}, messages: make(chan eventhandler.Message, 100)}
+	client.handler.AddListener("receive_rustplus_message", client.messages)
+	client.RustPlus, err = rustplus.NewRustPlus(client.handler, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+		db.Close()
+		os.RemoveAll(db.Path)
+	})
+	if err := client.Open(); err != nil {
+		t.Fatal(err)
+	}
+	client.waitForMessage(t, eventhandler.ServerConnectedType)
+
+	// Wait for the team chat backfill, so it doesn't interfere with the tests (which can't happen without any credentials)
+	if envCredential {
+		client.eventually(t, "team chat backfill", func() bool {
+			return server.RequestCount("getTeamChat") > 0
+		})
+	}
+
+	return client
+}
+
+// waitForMessage returns the next message of the given type, skipping any other messages
+func (client *testClient) waitForMessage(t *testing.T, messageType eventhandler.MessageType) eventhandler.Message {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case message := <-client.messages:
+			if message.Type == messageType {
+				return message
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for a message of type", messageType)
+		}
+	}
+}
+
+// expectNoMessage fails if a message of the given type is received within a short time
+func (client *testClient) expectNoMessage(t *testing.T, messageType eventhandler.MessageType) {
+	t.Helper()
+	timeout := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case message := <-client.messages:
+			if message.Type == messageType {
+				t.Fatal("did not expect message:", message)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// eventually waits for a condition to become true
+func (client *testClient) eventually(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for", description)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIntegrationRequests(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.AddPlayer(testPlayerID, testPlayerToken)
+	client := newTestClient(t, server)
+
+	info, err := client.GetInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "Mock Server" || info.MapSize != 3000 {
+		t.Fatal("unexpected server info:", info)
+	}
+
+	// Every request is authenticated with the player credentials
+	for _, request := range server.Requests() {
+		if request.PlayerId != testPlayerID || request.PlayerToken != testPlayerToken {
+			t.Fatal("unexpected credentials in request:", request)
+		}
+	}
+
+	// Errors are returned as Rust+ errors
+	server.InjectError("getTime", rustplus.NotFoundError)
+	if _, err := client.GetTime(context.Background()); !rustplus.IsError(err, rustplus.NotFoundError) {
+		t.Fatal("expected a not found error but got", err)
+	}
+	if _, err := client.GetTime(context.Background()); err != nil {
+		t.Fatal("injected errors should only apply once:", err)
+	}
+
+	// Rate limiting
+	server.SetRateLimit(2, time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetInfo(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.GetInfo(context.Background()); !rustplus.IsError(err, rustplus.RateLimitError) {
+		t.Fatal("expected a rate limit error but got", err)
+	}
+	server.SetRateLimit(0, 0)
+
+	// Rate limited requests are retried once the rate limit recovers
+	server.InjectError("getInfo", rustplus.RateLimitError)
+	if _, err := client.GetInfo(context.Background()); err != nil {
+		t.Fatal("expected the rate limited request to be retried:", err)
+	}
+
+	// Lost connections fail the request instead of waiting for the timeout
+	server.Disconnect()
+	client.waitForMessage(t, eventhandler.ServerDisconnectedType)
+	if _, err := client.GetInfo(context.Background()); err != rustplus.ErrNotConnected {
+		t.Fatal("expected a not connected error but got", err)
+	}
+}
+
+func TestIntegrationCredentials(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.AddPlayer(testPlayerID, testPlayerToken)
+	client := newTestClient(t, server)
+
+	// Credentials are verified when registering them
+	if _, err := client.RegisterCredential(context.Background(), "100", 76561197960287931, 1); err == nil {
+		t.Fatal("expected unknown credentials to be rejected")
+	}
+	if _, err := client.GetCredential("100"); err != rustplus.ErrCredentialNotFound {
+		t.Fatal("rejected credentials should not be stored:", err)
+	}
+	server.AddPlayer(76561197960287931, 1)
+	if _, err := client.RegisterCredential(context.Background(), "100", 76561197960287931, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Registered credentials are preferred over the ones from the environment variables
+	if _, err := client.GetInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if request := requests[len(requests)-1]; request.PlayerId != 76561197960287931 {
+		t.Fatal("expected the registered credentials to be used:", request)
+	}
+
+	// Once the credentials are rejected, they are marked as invalid and the next ones are used
+	server.AddPlayer(76561197960287931, 2)
+	if _, err := client.GetInfo(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	requests = server.Requests()
+	if request := requests[len(requests)-1]; request.PlayerId != testPlayerID {
+		t.Fatal("expected the fallback credentials to be used:", request)
+	}
+	if message := client.waitForMessage(t, eventhandler.CredentialInvalidType); message.User != "100" {
+		t.Fatal("expected the user to be notified but got", message)
+	}
+	if credential, err := client.GetCredential("100"); err != nil || credential.Valid || credential.Error != rustplus.AccessDeniedError {
+		t.Fatal("expected invalid credentials but got", credential, err)
+	}
+
+	// Without any valid credentials requests fail right away
+	server.AddPlayer(testPlayerID, 0)
+	if _, err := client.GetInfo(context.Background()); err != rustplus.ErrNoCredentials {
+		t.Fatal("expected no valid credentials but got", err)
+	}
+}
+
+func TestIntegrationTeamChat(t *testing.T) {
+	t.Run("EnvCredential", func(t *testing.T) {
+		server := rustplustest.NewServer()
+		server.SetTeamInfo(&rustplus.AppTeamInfo{LeaderSteamId: testPlayerID, Members: []*rustplus.AppTeamInfo_Member{
+			{SteamId: testPlayerID, Name: "Bot", IsOnline: true, IsAlive: true},
+			{SteamId: 1, Name: "Alice", IsOnline: true, IsAlive: true},
+		}})
+		server.AddTeamMessage(&rustplus.AppChatMessage{SteamId: 1, Name: "Alice", Message: "missed message", Time: uint32(time.Now().Unix()) - 60})
+		client := newTestClient(t, server)
+
+		// Messages sent while disconnected are backfilled
+		if message := client.waitForMessage(t, eventhandler.TeamChatType); message.User != "Alice" || message.Message != "missed message" {
+			t.Fatal("unexpected backfilled message:", message)
+		}
+
+		// New messages are relayed as they arrive
+		server.SendTeamMessage(&rustplus.AppChatMessage{SteamId: 1, Name: "Alice", Message: "hello", Time: uint32(time.Now().Unix())})
+		if message := client.waitForMessage(t, eventhandler.TeamChatType); message.User != "Alice" || message.Message != "hello" {
+			t.Fatal("unexpected team message:", message)
+		}
+
+		// Discord messages are relayed to the team chat, without echoing them back
+		client.handler.Emit(eventhandler.Message{Event: "receive_discord_team_message", User: "Bob", Message: "hi from Discord"})
+		client.eventually(t, "the Discord message", func() bool {
+			messages := server.TeamChat()
+			return messages[len(messages)-1].Message == "[DISCORD] Bob: hi from Discord"
+		})
+		client.expectNoMessage(t, eventhandler.TeamChatType)
+
+		// Rich text is stripped from Discord messages, and long ones are split into multiple lines
+		client.handler.Emit(eventhandler.Message{Event: "receive_discord_team_message", User: "<b>Bob</b>", Message: "<color=red>long</color>\n" + strings.Repeat("word ", 40)})
+		client.eventually(t, "the long Discord message", func() bool {
+			return len(server.TeamChat()) >= 5
+		})
+		for _, message := range server.TeamChat()[3:] {
+			if !strings.HasPrefix(message.Message, "[DISCORD] Bob: ") || strings.ContainsAny(message.Message, "<>\n") || len([]rune(message.Message)) > 128 {
+				t.Errorf("unexpected team message %q", message.Message)
+			}
+		}
+		client.expectNoMessage(t, eventhandler.TeamChatType)
+	})
+
+	t.Run("RegisteredCredential", func(t *testing.T) {
+		server := rustplustest.NewServer()
+		client := newTestClientWithEnvCredential(t, server, false)
+		if _, err := client.RegisterCredential(context.Background(), "100", 76561197960287931, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		// Without the credentials from the environment variables, Discord messages are sent with the registered ones
+		// (and still aren't echoed back)
+		client.handler.Emit(eventhandler.Message{Event: "receive_discord_team_message", User: "Bob", Message: "hi from Discord"})
+		client.eventually(t, "the Discord message", func() bool {
+			messages := server.TeamChat()
+			return len(messages) > 0 && messages[len(messages)-1].Message == "[DISCORD] Bob: hi from Discord"
+		})
+		if messages := server.TeamChat(); messages[len(messages)-1].SteamId != 76561197960287931 {
+			t.Fatal("expected the registered credentials to be used:", messages[len(messages)-1])
+		}
+		client.expectNoMessage(t, eventhandler.TeamChatType)
+	})
+}
+
+func TestIntegrationSwitches(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.SetEntity(1, &rustplus.AppEntityInfo{Type: rustplus.AppEntityType_Switch, Payload: &rustplus.AppEntityPayload{}})
+	server.SetEntity(2, &rustplus.AppEntityInfo{Type: rustplus.AppEntityType_Switch, Payload: &rustplus.AppEntityPayload{Value: true}})
+	server.SetEntity(3, &rustplus.AppEntityInfo{Type: rustplus.AppEntityType_Alarm, Payload: &rustplus.AppEntityPayload{}})
+	client := newTestClient(t, server)
+
+	// Pairing verifies that the entity exists
+	if _, err := client.AddEntity(context.Background(), "Missing", 99); !rustplus.IsError(err, rustplus.NotFoundError) {
+		t.Fatal("expected a not found error but got", err)
+	}
+	for name, entityID := range map[string]uint32{"Lights": 1, "Turret": 2, "Door": 3} {
+		if _, err := client.AddEntity(context.Background(), name, entityID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entity, err := client.GetEntity("turret"); err != nil || !entity.Value || entity.Type != rustplus.AppEntityType_Switch {
+		t.Fatal("unexpected entity:", entity, err)
+	}
+
+	// Switching a single entity
+	if _, err := client.SetSwitch(context.Background(), "lights", true); err != nil {
+		t.Fatal(err)
+	}
+	if info := server.Entity(1); !info.Payload.Value {
+		t.Fatal("expected the switch to be on:", info)
+	}
+	if _, err := client.SetSwitch(context.Background(), "door", true); err == nil {
+		t.Fatal("expected an error when switching an alarm")
+	}
+
+	// Toggling a group turns everything off if anything is on
+	for _, name := range []string{"Lights", "Turret"} {
+		if err := client.AddEntityToGroup("Base", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.ToggleSwitch(context.Background(), "base"); err != nil {
+		t.Fatal(err)
+	}
+	if server.Entity(1).Payload.Value || server.Entity(2).Payload.Value {
+		t.Fatal("expected every switch in the group to be off")
+	}
+
+	// Changes made in-game are picked up from broadcasts
+	server.ChangeEntity(2, &rustplus.AppEntityPayload{Value: true})
+	client.eventually(t, "the switch to turn on", func() bool {
+		entity, err := client.GetEntity("turret")
+		return err == nil && entity.Value
+	})
+}
+
+func TestIntegrationAlarms(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.SetEntity(1, &rustplus.AppEntityInfo{Type: rustplus.AppEntityType_Alarm, Payload: &rustplus.AppEntityPayload{}})
+	client := newTestClient(t, server)
+
+	if _, err := client.AddEntity(context.Background(), "Front Door", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	server.ChangeEntity(1, &rustplus.AppEntityPayload{Value: true})
+	if message := client.waitForMessage(t, eventhandler.AlarmType); message.User != "Front Door" {
+		t.Fatal("unexpected alarm message:", message)
+	}
+
+	// Alarms turning off again aren't reported
+	server.ChangeEntity(1, &rustplus.AppEntityPayload{Value: false})
+	client.expectNoMessage(t, eventhandler.AlarmType)
+}
+
+func TestIntegrationStorage(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.SetEntity(1, &rustplus.AppEntityInfo{Type: rustplus.AppEntityType_StorageMonitor, Payload: &rustplus.AppEntityPayload{
+		Capacity:         24,
+		Items:            []*rustplus.AppEntityPayload_Item{{ItemId: -1581843485, Quantity: 1000}},
+		HasProtection:    true,
+		ProtectionExpiry: uint32(time.Now().Add(2 * time.Hour).Unix()),
+	}})
+	client := newTestClient(t, server)
+
+	// Pairing a tool cupboard with little upkeep left warns right away
+	if _, err := client.AddEntity(context.Background(), "TC", 1); err != nil {
+		t.Fatal(err)
+	}
+	if message := client.waitForMessage(t, eventhandler.UpkeepType); !strings.Contains(message.Message, "less than 6h") {
+		t.Fatal("unexpected upkeep message:", message)
+	}
+
+	// Content changes are stored as snapshots
+	server.ChangeEntity(1, &rustplus.AppEntityPayload{
+		Capacity:         24,
+		Items:            []*rustplus.AppEntityPayload_Item{{ItemId: -1581843485, Quantity: 3000}},
+		HasProtection:    true,
+		ProtectionExpiry: uint32(time.Now().Add(2 * time.Hour).Unix()),
+	})
+	client.eventually(t, "a new snapshot", func() bool {
+		snapshots, err := client.GetStorageSnapshots("tc")
+		return err == nil && len(snapshots) == 2
+	})
+	current, previous, err := client.CheckStorage(context.Background(), "tc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := rustplus.DiffStorageSnapshots(previous, current)
+	if len(changes) != 1 || changes[0].ItemID != -1581843485 || changes[0].Quantity != 2000 {
+		t.Fatal("unexpected storage changes:", changes)
+	}
+
+	// Running out of upkeep is reported immediately
+	server.ChangeEntity(1, &rustplus.AppEntityPayload{Capacity: 24})
+	if message := client.waitForMessage(t, eventhandler.UpkeepType); !strings.Contains(message.Message, "out of upkeep") {
+		t.Fatal("unexpected upkeep message:", message)
+	}
+}
+
+func TestIntegrationMap(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.SetMap(&rustplus.AppMap{
+		Width:       300,
+		Height:      300,
+		JpgImage:    rustplustest.SolidJPEG(300, 300, color.RGBA{R: 60, G: 90, B: 120, A: 255}),
+		OceanMargin: 0,
+		Monuments:   []*rustplus.AppMap_Monument{{Token: "launchsite", X: 1500, Y: 1500}},
+	})
+	server.SetMarkers(
+		&rustplus.AppMarker{Id: 1, Type: rustplus.AppMarkerType_Player, X: 100, Y: 100, Name: "Alice"},
+		&rustplus.AppMarker{Id: 2, Type: rustplus.AppMarkerType_CargoShip, X: 2900, Y: 100},
+	)
+	client := newTestClient(t, server)
+
+	world, err := client.GetWorldMap(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location := world.Location(1500, 1450); location != "K10 near Launch Site" {
+		t.Fatal("unexpected location:", location)
+	}
+
+	data, err := client.RenderMap(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal("expected a PNG image:", err)
+	}
+}
+
+func TestIntegrationShops(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.SetMarkers(
+		&rustplus.AppMarker{Id: 1, Type: rustplus.AppMarkerType_VendingMachine, X: 1500, Y: 1500, Name: "Cheap Sulfur", SellOrders: []*rustplus.AppMarker_SellOrder{
+			{ItemId: -1581843485, Quantity: 1000, CurrencyId: -932201673, CostPerItem: 100, AmountInStock: 5},
+			{ItemId: 69511070, Quantity: 100, CurrencyId: -932201673, CostPerItem: 50, AmountInStock: 0},
+		}},
+		&rustplus.AppMarker{Id: 2, Type: rustplus.AppMarkerType_VendingMachine, X: 100, Y: 100, Name: "Expensive Sulfur", SellOrders: []*rustplus.AppMarker_SellOrder{
+			{ItemId: -1581843485, Quantity: 100, CurrencyId: -932201673, CostPerItem: 50, AmountInStock: 2},
+		}},
+	)
+	client := newTestClient(t, server)
+
+	listings, err := client.SearchShops(context.Background(), "sulfur", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listings) != 2 || listings[0].Shop != "Cheap Sulfur" || listings[1].Shop != "Expensive Sulfur" {
+		t.Fatal("unexpected listings:", listings)
+	}
+
+	// Sold out listings are skipped
+	if listings, err := client.SearchShops(context.Background(), "metal fragments", nil); err != nil || len(listings) != 0 {
+		t.Fatal("expected no listings but got", listings, err)
+	}
+}
+
+func TestIntegrationWorldEvents(t *testing.T) {
+	server := rustplustest.NewServer()
+	client := newTestClient(t, server)
+
+	// Wait for the first poll, which only records the existing markers
+	client.eventually(t, "the first marker poll", func() bool {
+		return server.RequestCount("getMapMarkers") > 0
+	})
+
+	server.SetMarkers(&rustplus.AppMarker{Id: 1, Type: rustplus.AppMarkerType_Crate, X: 1500, Y: 1500})
+	if message := client.waitForMessage(t, eventhandler.CrateSpawnedType); message.Message != "Locked crate appeared at K10" {
+		t.Fatal("unexpected event:", message)
+	}
+
+	server.SetMarkers()
+	if message := client.waitForMessage(t, eventhandler.CrateGoneType); message.Message != "Locked crate at K10 is gone" {
+		t.Fatal("unexpected event:", message)
+	}
+}
+
+func TestIntegrationTeam(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.SetTeamInfo(&rustplus.AppTeamInfo{LeaderSteamId: testPlayerID, Members: []*rustplus.AppTeamInfo_Member{
+		{SteamId: testPlayerID, Name: "Bot", IsOnline: true, IsAlive: true},
+		{SteamId: 1, Name: "Alice", IsOnline: true, IsAlive: true},
+	}})
+	server.SetCamera("CCTV1", rustplustest.SolidJPEG(16, 16, color.RGBA{A: 255}))
+	client := newTestClient(t, server)
+
+	if err := client.PromoteToLeader(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if message := client.waitForMessage(t, eventhandler.TeamLeaderType); message.Message != "Alice is now the team leader" {
+		t.Fatal("unexpected team event:", message)
+	}
+	teamInfo, err := client.GetTeamInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if teamInfo.LeaderSteamId != 1 {
+		t.Fatal("expected Alice to be the leader:", teamInfo)
+	}
+
+	frame, err := client.GetCameraFrame(context.Background(), "CCTV1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frame.JpgImage) <= 0 {
+		t.Fatal("expected a camera frame")
+	}
+	if _, err := client.GetCameraFrame(context.Background(), "CCTV2", 0); !rustplus.IsError(err, rustplus.NotFoundError) {
+		t.Fatal("expected a not found error but got", err)
+	}
+}
+
+func TestIntegrationTeamEvents(t *testing.T) {
+	server := rustplustest.NewServer()
+	alice := &rustplus.AppTeamInfo_Member{SteamId: 1, Name: "Alice", IsOnline: true, IsAlive: true}
+	bot := &rustplus.AppTeamInfo_Member{SteamId: testPlayerID, Name: "Bot", IsOnline: true, IsAlive: true}
+	server.SetTeamInfo(&rustplus.AppTeamInfo{LeaderSteamId: testPlayerID, Members: []*rustplus.AppTeamInfo_Member{bot, alice}})
+	client := newTestClient(t, server)
+
+	// Team changes only use the cached map for their locations
+	client.eventually(t, "the map", func() bool {
+		_, err := client.GetWorldMap(context.Background())
+		return err == nil
+	})
+
+	// The initial team (which isn't announced) is fetched in the background, so keep killing Alice until it's noticed
+	dead := &rustplus.AppTeamInfo{LeaderSteamId: testPlayerID, Members: []*rustplus.AppTeamInfo_Member{
+		bot,
+		{SteamId: 1, Name: "Alice", IsOnline: true, IsAlive: false, DeathTime: uint32(time.Now().Unix()), X: 1500, Y: 1450},
+	}}
+	alive := &rustplus.AppTeamInfo{LeaderSteamId: testPlayerID, Members: []*rustplus.AppTeamInfo_Member{bot, alice}}
+	deadline := time.After(testTimeout)
+	for died := false; !died; {
+		server.ChangeTeam(testPlayerID, alive)
+		time.Sleep(100 * time.Millisecond)
+		server.ChangeTeam(testPlayerID, dead)
+		retry := time.After(500 * time.Millisecond)
+		for waiting := true; waiting && !died; {
+			select {
+			case message := <-client.messages:
+				if message.Type == eventhandler.TeamDeathType {
+					if message.Message != "Alice died at K10" {
+						t.Fatal("unexpected team event:", message)
+					}
+					died = true
+				}
+			case <-retry:
+				waiting = false
+			case <-deadline:
+				t.Fatal("timed out waiting for a message of type", eventhandler.TeamDeathType)
+			}
+		}
+	}
+
+	server.ChangeTeam(testPlayerID, &rustplus.AppTeamInfo{LeaderSteamId: testPlayerID, Members: []*rustplus.AppTeamInfo_Member{bot}})
+	if message := client.waitForMessage(t, eventhandler.TeamLeaveType); message.Message != "Alice left the team" {
+		t.Fatal("unexpected team event:", message)
+	}
+
+	// Team events are kept as the activity history
+	client.eventually(t, "the team activity", func() bool {
+		events, err := client.GetTeamActivity(1, 0)
+		return err == nil && len(events) >= 2 && events[0].Type == eventhandler.TeamLeaveType && events[1].Type == eventhandler.TeamDeathType
+	})
+}
+
+func TestIntegrationClock(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.SetTime(&rustplus.AppTime{DayLengthMinutes: 60, TimeScale: 1, Sunrise: 7, Sunset: 20, Time: 19.5})
+	server.SetEntity(1, &rustplus.AppEntityInfo{Type: rustplus.AppEntityType_Switch, Payload: &rustplus.AppEntityPayload{}})
+	client := newTestClient(t, server)
+
+	if _, err := client.AddEntity(context.Background(), "Lights", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddTimeTrigger("lights", rustplus.SunsetTrigger, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddTimeTrigger("lights", "noon", true); err == nil {
+		t.Fatal("expected an error for an unknown trigger")
+	}
+
+	// The clock is sent to Discord on every poll
+	if message := client.waitForMessage(t, eventhandler.ClockType); message.Message != "19:30" {
+		t.Fatal("unexpected clock:", message)
+	}
+
+	// Night falling turns on the lights
+	server.SetTime(&rustplus.AppTime{DayLengthMinutes: 60, TimeScale: 1, Sunrise: 7, Sunset: 20, Time: 20.1})
+	client.waitForMessage(t, eventhandler.NightfallType)
+	client.eventually(t, "the lights to turn on", func() bool {
+		return server.Entity(1).Payload.Value
+	})
+
+	// Triggers run before the next poll, so wait for it to make sure they're done
+	// (messages may arrive out of order, so the first one could still be from the same poll)
+	client.waitForMessage(t, eventhandler.ClockType)
+	client.waitForMessage(t, eventhandler.ClockType)
+}
+
+func TestIntegrationCamera(t *testing.T) {
+	server := rustplustest.NewServer()
+	server.SetCamera("CCTV1", rustplustest.SolidJPEG(160, 90, color.RGBA{R: 200, A: 255}))
+	client := newTestClient(t, server)
+
+	data, err := client.GetCameraImage(context.Background(), "CCTV1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) <= 0 {
+		t.Fatal("expected a camera image")
+	}
+	if _, err := client.GetCameraImage(context.Background(), "CCTV2"); !rustplus.IsError(err, rustplus.NotFoundError) {
+		t.Fatal("expected a not found error but got", err)
+	}
+
+	frames, err := client.RecordCamera(context.Background(), "CCTV1", 3, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatal("expected 3 frames but got", len(frames))
+	}
+	if count := server.RequestCount("getCameraFrame"); count != 5 {
+		t.Fatal("expected 5 camera frame requests but got", count)
+	}
+
+	animation, err := rustplus.EncodeCameraGIF(frames, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(animation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Image) != 3 || decoded.Delay[0] != 10 {
+		t.Fatal("unexpected animation:", len(decoded.Image), decoded.Delay)
+	}
+}
@@ -0,0 +1,93 @@
+package rustplus
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnknownItemHint explains how to find items that can't be found by name
+const UnknownItemHint = "only common items are known by name, use the numeric item ID for any other item"
+
+// itemNames maps the item IDs of common items to their display names (any other item can only be found by its numeric ID)
+var itemNames = map[int32]string{
+	-2099697608: "Stones",
+	-2072273936: "Bandage",
+	-1994909036: "Sheet Metal",
+	-1982036270: "High Quality Metal Ore",
+	-1938052175: "Charcoal",
+	-1878475007: "Satchel Charge",
+	-1812555177: "LR-300 Assault Rifle",
+	-1685290200: "12 Gauge Buckshot",
+	-1673693549: "Empty Propane Tank",
+	-1581843485: "Sulfur",
+	-1321651331: "Explosive 5.56 Rifle Ammo",
+	-1211166256: "5.56 Rifle Ammo",
+	-1157596551: "Sulfur Ore",
+	-1021495308: "Metal Spring",
+	-1018587433: "Animal Fat",
+	-946369541:  "Low Grade Fuel",
+	-932201673:  "Scrap",
+	-858312878:  "Cloth",
+	-742865266:  "Rocket",
+	-592016202:  "Explosives",
+	-321733511:  "Crude Oil",
+	-265876753:  "Gun Powder",
+	-151838493:  "Wood",
+	-4031221:    "Metal Ore",
+	69511070:    "Metal Fragments",
+	73681876:    "Tech Trash",
+	95950017:    "Metal Pipe",
+	176787552:   "Rifle Body",
+	254522515:   "Large Medkit",
+	317398316:   "High Quality Metal",
+	479143914:   "Gears",
+	573926264:   "Semi Automatic Body",
+	634478325:   "CCTV Camera",
+	785728077:   "Pistol Bullet",
+	1079279582:  "Medical Syringe",
+	1199391518:  "Road Signs",
+	1230323789:  "SMG Body",
+	1234880403:  "Sewing Kit",
+	1248356124:  "Timed Explosive Charge",
+	1381010055:  "Leather",
+	1397052267:  "Supply Signal",
+	1414245522:  "Rope",
+	1523195708:  "Targeting Computer",
+	1545779598:  "Assault Rifle",
+	1588298435:  "Bolt Action Rifle",
+	1840822026:  "Beancan Grenade",
+	1882709339:  "Metal Blade",
+	2019042823:  "Tarp",
+}
+
+// ItemName returns the display name of an item, or a generic name if the item is unknown
+func ItemName(itemID int32) string {
+	if name, ok := itemNames[itemID]; ok {
+		return name
+	}
+	return "Item " + strconv.FormatInt(int64(itemID), 10)
+}
+
+// FindItems returns the IDs of the items whose name contains the query (or the item ID itself if the query is a number),
+// with an exact name match always being the only result
+func FindItems(query string) []int32 {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if itemID, err := strconv.ParseInt(query, 10, 32); err == nil {
+		return []int32{int32(itemID)}
+	}
+
+	matches := make([]int32, 0)
+	for itemID, name := range itemNames {
+		if strings.ToLower(name) == query {
+			return []int32{itemID}
+		}
+		if len(query) > 0 && strings.Contains(strings.ToLower(name), query) {
+			matches = append(matches, itemID)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return itemNames[matches[i]] < itemNames[matches[j]]
+	})
+	return matches
+}
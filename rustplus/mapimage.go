@@ -0,0 +1,379 @@
+package rustplus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"github.com/Dids/rustbot/rustmap"
+)
+
+// maxMapImageSize is the maximum width and height of a rendered map, which keeps the PNG small enough for Discord
+const maxMapImageSize = 1600
+
+// Colors used when rendering the map
+var (
+	gridColor           = color.RGBA{0, 0, 0, 64}
+	gridLabelColor      = color.RGBA{255, 255, 255, 255}
+	monumentColor       = color.RGBA{230, 230, 230, 255}
+	playerColor         = color.RGBA{90, 220, 90, 255}
+	vendingMachineColor = color.RGBA{255, 160, 40, 255}
+	cargoShipColor      = color.RGBA{60, 130, 230, 255}
+	ch47Color           = color.RGBA{240, 200, 40, 255}
+	helicopterColor     = color.RGBA{230, 50, 50, 255}
+	explosionColor      = color.RGBA{255, 90, 0, 255}
+	outlineColor        = color.RGBA{0, 0, 0, 255}
+)
+
+// mapBase is the (decoded and resized) map image with the grid and monuments already drawn on it
+type mapBase struct {
+	image *image.RGBA
+	world *rustmap.Map
+	scale int
+}
+
+// mapRenderer draws on a copy of the map image, translating world positions to pixels
+type mapRenderer struct {
+	*mapBase
+	image *image.RGBA
+}
+
+// RenderMap renders the server map with the grid, monuments and current map markers as a PNG image
+func (rustplus *RustPlus) RenderMap(ctx context.Context) ([]byte, error) {
+	base, err := rustplus.getMapBase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	markers, err := rustplus.GetMapMarkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer := base.newRenderer()
+	renderer.drawMarkers(markers.GetMarkers())
+
+	var buffer bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(&buffer, renderer.image); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// GetWorldMap returns the (cached) map description, used for translating world positions to grid cells and monuments
+func (rustplus *RustPlus) GetWorldMap(ctx context.Context) (*rustmap.Map, error) {
+	rustplus.mapMutex.Lock()
+	defer rustplus.mapMutex.Unlock()
+
+	return rustplus.getWorldMap(ctx)
+}
+
+// cachedWorldMap returns the map description if it has been cached, requesting it in the background otherwise
+// (for callers that can't wait for the server, like the broadcast handler)
+func (rustplus *RustPlus) cachedWorldMap() *rustmap.Map {
+	rustplus.mapMutex.Lock()
+	world := rustplus.worldMap
+	rustplus.mapMutex.Unlock()
+
+	if world == nil {
+		rustplus.startTask(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+			defer cancel()
+			if _, err := rustplus.GetWorldMap(ctx); err != nil {
+				rustplus.logger.Warning("Failed to get map:", err)
+			}
+		})
+	}
+	return world
+}
+
+// getWorldMap requests the map and server info if they haven't been cached yet (mapMutex must be held)
+func (rustplus *RustPlus) getWorldMap(ctx context.Context) (*rustmap.Map, error) {
+	if rustplus.worldMap != nil {
+		return rustplus.worldMap, nil
+	}
+
+	info, err := rustplus.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if info.GetMapSize() <= 0 {
+		return nil, errors.New("invalid map size")
+	}
+	appMap, err := rustplus.GetMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	world := &rustmap.Map{Size: info.GetMapSize(), Width: appMap.GetWidth(), Height: appMap.GetHeight(), OceanMargin: appMap.GetOceanMargin()}
+	for _, monument := range appMap.GetMonuments() {
+		world.Monuments = append(world.Monuments, rustmap.Monument{Token: monument.GetToken(), X: float64(monument.GetX()), Y: float64(monument.GetY())})
+	}
+
+	rustplus.worldMap = world
+	rustplus.mapImage = appMap.GetJpgImage()
+	return world, nil
+}
+
+// getMapBase returns the cached map image, requesting and preparing it first if necessary
+func (rustplus *RustPlus) getMapBase(ctx context.Context) (*mapBase, error) {
+	rustplus.mapMutex.Lock()
+	defer rustplus.mapMutex.Unlock()
+
+	if rustplus.mapBase != nil {
+		return rustplus.mapBase, nil
+	}
+
+	world, err := rustplus.getWorldMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	base, err := newMapBase(rustplus.mapImage, world)
+	if err != nil {
+		return nil, err
+	}
+
+	rustplus.mapBase = base
+	return base, nil
+}
+
+// clearMapCache forgets the cached map (eg. after reconnecting, as the server may have wiped)
+func (rustplus *RustPlus) clearMapCache() {
+	rustplus.mapMutex.Lock()
+	defer rustplus.mapMutex.Unlock()
+
+	rustplus.worldMap = nil
+	rustplus.mapImage = nil
+	rustplus.mapBase = nil
+}
+
+// newMapBase decodes and resizes the map image, and draws the grid and monuments on it
+func newMapBase(jpgImage []byte, world *rustmap.Map) (*mapBase, error) {
+	decoded, err := jpeg.Decode(bytes.NewReader(jpgImage))
+	if err != nil {
+		return nil, errors.New("failed to decode map image: " + err.Error())
+	}
+
+	// Shrink the image to fit within our maximum size
+	factor := 1.0
+	bounds := decoded.Bounds()
+	if longest := math.Max(float64(bounds.Dx()), float64(bounds.Dy())); longest > maxMapImageSize {
+		factor = maxMapImageSize / longest
+	}
+	resized := resizeImage(decoded, int(float64(bounds.Dx())*factor), int(float64(bounds.Dy())*factor))
+
+	// Describe the resized image instead of the original one
+	resizedWorld := *world
+	resizedWorld.Width = uint32(resized.Bounds().Dx())
+	resizedWorld.Height = uint32(resized.Bounds().Dy())
+	resizedWorld.OceanMargin = int32(math.Round(float64(world.OceanMargin) * factor))
+
+	base := &mapBase{
+		image: resized,
+		world: &resizedWorld,
+		scale: int(math.Max(1, math.Round(float64(resized.Bounds().Dx())/800))),
+	}
+
+	renderer := &mapRenderer{mapBase: base, image: base.image}
+	renderer.drawGrid()
+	renderer.drawMonuments()
+
+	return base, nil
+}
+
+// newRenderer returns a renderer that draws on a copy of the map image
+func (base *mapBase) newRenderer() *mapRenderer {
+	copied := image.NewRGBA(base.image.Bounds())
+	draw.Draw(copied, copied.Bounds(), base.image, base.image.Bounds().Min, draw.Src)
+	return &mapRenderer{mapBase: base, image: copied}
+}
+
+// worldToPixel translates a world position to a pixel position in the map image
+func (renderer *mapRenderer) worldToPixel(x float64, y float64) (int, int) {
+	pixelX, pixelY := renderer.world.ToPixel(x, y)
+	return int(math.Round(pixelX)), int(math.Round(pixelY))
+}
+
+func (renderer *mapRenderer) drawGrid() {
+	count := renderer.world.CellCount()
+	cellSize := renderer.world.CellSize()
+	mapSize := float64(renderer.world.Size)
+
+	// Grid lines
+	for i := 0; i <= count; i++ {
+		offset := float64(i) * cellSize
+		x0, y0 := renderer.worldToPixel(offset, 0)
+		x1, y1 := renderer.worldToPixel(offset, mapSize)
+		renderer.drawLine(x0, y0, x1, y1, gridColor)
+		x0, y0 = renderer.worldToPixel(0, offset)
+		x1, y1 = renderer.worldToPixel(mapSize, offset)
+		renderer.drawLine(x0, y0, x1, y1, gridColor)
+	}
+
+	// Cell labels in the top left corner of each cell
+	for column := 0; column < count; column++ {
+		for row := 0; row < count; row++ {
+			x, y := renderer.worldToPixel(float64(column)*cellSize, mapSize-float64(row)*cellSize)
+			renderer.drawText(rustmap.CellName(column, row), x+2*renderer.scale, y+2*renderer.scale, renderer.scale, gridLabelColor)
+		}
+	}
+}
+
+func (renderer *mapRenderer) drawMonuments() {
+	for _, monument := range renderer.world.Monuments {
+		name := rustmap.MonumentName(monument.Token)
+		if len(name) <= 0 {
+			continue
+		}
+		x, y := renderer.worldToPixel(monument.X, monument.Y)
+		renderer.drawText(name, x-textWidth(name, renderer.scale)/2, y-glyphHeight*renderer.scale/2, renderer.scale, monumentColor)
+	}
+}
+
+func (renderer *mapRenderer) drawMarkers(markers []*AppMarker) {
+	size := 4 * renderer.scale
+
+	// Draw vending machines first, so that they don't cover anything more important
+	for _, marker := range markers {
+		if marker.GetType() == AppMarkerType_VendingMachine {
+			x, y := renderer.worldToPixel(float64(marker.GetX()), float64(marker.GetY()))
+			renderer.fillRect(x-size/2-1, y-size/2-1, size+2, size+2, outlineColor)
+			renderer.fillRect(x-size/2, y-size/2, size, size, vendingMachineColor)
+		}
+	}
+
+	for _, marker := range markers {
+		x, y := renderer.worldToPixel(float64(marker.GetX()), float64(marker.GetY()))
+		switch marker.GetType() {
+		case AppMarkerType_Player:
+			renderer.fillCircle(x, y, size+1, outlineColor)
+			renderer.fillCircle(x, y, size, playerColor)
+			renderer.drawLabel(marker.GetName(), x, y+size+2*renderer.scale, playerColor)
+		case AppMarkerType_CargoShip:
+			renderer.drawVehicle(x, y, 3*size, "Cargo", cargoShipColor)
+		case AppMarkerType_CH47:
+			renderer.drawVehicle(x, y, 2*size, "CH47", ch47Color)
+		case AppMarkerType_PatrolHelicopter:
+			renderer.drawVehicle(x, y, 2*size, "Heli", helicopterColor)
+		case AppMarkerType_Explosion:
+			for offset := -renderer.scale; offset <= renderer.scale; offset++ {
+				renderer.drawLine(x-2*size+offset, y-2*size, x+2*size+offset, y+2*size, explosionColor)
+				renderer.drawLine(x-2*size+offset, y+2*size, x+2*size+offset, y-2*size, explosionColor)
+			}
+		}
+	}
+}
+
+// drawVehicle draws a labeled ring for large moving objects (cargo ship, helicopters)
+func (renderer *mapRenderer) drawVehicle(x int, y int, radius int, label string, fill color.RGBA) {
+	renderer.fillCircle(x, y, radius+1, outlineColor)
+	renderer.fillCircle(x, y, radius, fill)
+	renderer.fillCircle(x, y, radius/2, outlineColor)
+	renderer.drawLabel(label, x, y+radius+2*renderer.scale, fill)
+}
+
+// drawLabel draws text horizontally centered below a marker
+func (renderer *mapRenderer) drawLabel(text string, x int, y int, fill color.RGBA) {
+	if len(text) <= 0 {
+		return
+	}
+	renderer.drawText(text, x-textWidth(text, renderer.scale)/2, y, renderer.scale, fill)
+}
+
+// blend alpha blends a single pixel with the given color
+func (renderer *mapRenderer) blend(x int, y int, c color.RGBA) {
+	if !(image.Point{x, y}.In(renderer.image.Bounds())) {
+		return
+	}
+	if c.A == 255 {
+		renderer.image.SetRGBA(x, y, c)
+		return
+	}
+	existing := renderer.image.RGBAAt(x, y)
+	alpha := uint32(c.A)
+	mix := func(a uint8, b uint8) uint8 {
+		return uint8((uint32(a)*alpha + uint32(b)*(255-alpha)) / 255)
+	}
+	renderer.image.SetRGBA(x, y, color.RGBA{mix(c.R, existing.R), mix(c.G, existing.G), mix(c.B, existing.B), 255})
+}
+
+func (renderer *mapRenderer) fillRect(x int, y int, width int, height int, c color.RGBA) {
+	for py := y; py < y+height; py++ {
+		for px := x; px < x+width; px++ {
+			renderer.blend(px, py, c)
+		}
+	}
+}
+
+func (renderer *mapRenderer) fillCircle(x int, y int, radius int, c color.RGBA) {
+	for py := -radius; py <= radius; py++ {
+		for px := -radius; px <= radius; px++ {
+			if px*px+py*py <= radius*radius {
+				renderer.blend(x+px, y+py, c)
+			}
+		}
+	}
+}
+
+// drawLine draws a one pixel wide line using Bresenham's algorithm
+func (renderer *mapRenderer) drawLine(x0 int, y0 int, x1 int, y1 int, c color.RGBA) {
+	dx := int(math.Abs(float64(x1 - x0)))
+	dy := -int(math.Abs(float64(y1 - y0)))
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		renderer.blend(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// resizeImage scales an image to the given size, averaging the source pixels covered by each destination pixel
+func resizeImage(source image.Image, width int, height int) *image.RGBA {
+	bounds := source.Bounds()
+	result := image.NewRGBA(image.Rect(0, 0, width, height))
+	if width == bounds.Dx() && height == bounds.Dy() {
+		draw.Draw(result, result.Bounds(), source, bounds.Min, draw.Src)
+		return result
+	}
+
+	for y := 0; y < height; y++ {
+		sy0 := bounds.Min.Y + y*bounds.Dy()/height
+		sy1 := int(math.Max(float64(sy0+1), float64(bounds.Min.Y+(y+1)*bounds.Dy()/height)))
+		for x := 0; x < width; x++ {
+			sx0 := bounds.Min.X + x*bounds.Dx()/width
+			sx1 := int(math.Max(float64(sx0+1), float64(bounds.Min.X+(x+1)*bounds.Dx()/width)))
+			var r, g, b, count uint32
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, _ := source.At(sx, sy).RGBA()
+					r, g, b, count = r+pr>>8, g+pg>>8, b+pb>>8, count+1
+				}
+			}
+			result.SetRGBA(x, y, color.RGBA{uint8(r / count), uint8(g / count), uint8(b / count), 255})
+		}
+	}
+	return result
+}
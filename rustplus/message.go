@@ -0,0 +1,55 @@
+package rustplus
+
+import (
+	"github.com/sacOO7/gowebsocket"
+	"google.golang.org/protobuf/proto"
+)
+
+func (rustplus *RustPlus) handleBinaryMessage(data []byte, socket gowebsocket.Socket) {
+	if rustplus.isShuttingDown() {
+		return
+	}
+
+	// Parse the incoming message as an AppMessage
+	message := &AppMessage{}
+	if err := proto.Unmarshal(data, message); err != nil {
+		rustplus.logger.Error("Failed to parse Rust+ message:", err)
+		return
+	}
+
+	// Responses are routed back to whoever sent the matching request
+	if message.Response != nil {
+		rustplus.handleResponse(message.Response)
+	}
+
+	// Broadcasts are relayed to every listener
+	if message.Broadcast != nil {
+		rustplus.emitBroadcast(message.Broadcast)
+	}
+}
+
+func (rustplus *RustPlus) handleResponse(response *AppResponse) {
+	rustplus.pendingMutex.Lock()
+	defer rustplus.pendingMutex.Unlock()
+
+	responseChannel, ok := rustplus.pendingRequests[response.Seq]
+	if !ok {
+		rustplus.logger.Warning("Received Rust+ response for unknown request:", response.Seq)
+		return
+	}
+	delete(rustplus.pendingRequests, response.Seq)
+
+	// The channel is buffered, so this never blocks
+	responseChannel <- response
+}
+
+// cancelPendingRequests closes every pending response channel, which unblocks the waiting requests
+func (rustplus *RustPlus) cancelPendingRequests() {
+	rustplus.pendingMutex.Lock()
+	defer rustplus.pendingMutex.Unlock()
+
+	for seq, responseChannel := range rustplus.pendingRequests {
+		close(responseChannel)
+		delete(rustplus.pendingRequests, seq)
+	}
+}
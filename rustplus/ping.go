@@ -0,0 +1,45 @@
+package rustplus
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Send pings to peer with this period
+	pingPeriod = 50 * time.Second
+)
+
+func (rustplus *RustPlus) startPinging() {
+	rustplus.logger.Trace("Creating Rust+ PING ticker..")
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		rustplus.logger.Trace("Stopping Rust+ PING ticker..")
+		ticker.Stop()
+	}()
+
+	for {
+		select {
+		case <-rustplus.stop:
+			return
+		case <-ticker.C:
+		}
+
+		// Skip while we're not connected, the reconnect logic will take care of it
+		conn := rustplus.connection()
+		if conn == nil {
+			continue
+		}
+
+		// Send PING
+		rustplus.writeMutex.Lock()
+		if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+			rustplus.logger.Warning("Failed to send PING to Rust+ server:", err)
+		}
+		rustplus.writeMutex.Unlock()
+	}
+}
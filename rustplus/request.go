@@ -0,0 +1,277 @@
+package rustplus
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// Time allowed for the server to respond, unless the context already has a deadline
+const requestTimeout = 10 * time.Second
+
+// Known AppError values returned by the server
+const (
+	// NotFoundError means that the entity (or camera) does not exist
+	NotFoundError = "not_found"
+	// RateLimitError means that too many requests have been sent
+	RateLimitError = "rate_limit"
+	// AccessDeniedError means that the player credentials are not valid for the request
+	AccessDeniedError = "access_denied"
+	// NoPlayerError means that the player is not known to the server
+	NoPlayerError = "no_player"
+	// NoTeamError means that the player is not in a team
+	NoTeamError = "no_team"
+	// BannedError means that the player is banned
+	BannedError = "banned"
+	// MessageNotSentError means that a team message could not be sent
+	MessageNotSentError = "message_not_sent"
+	// WrongTypeError means that the request does not apply to the entity type
+	WrongTypeError = "wrong_type"
+)
+
+// ErrNotConnected is returned when sending a request without an active connection
+var ErrNotConnected = errors.New("not connected to the Rust+ server")
+
+// ErrNoCredentials is returned when there are no valid player credentials to send requests with
+var ErrNoCredentials = errors.New("no valid Rust+ credentials, register some with /rustplus register")
+
+// ErrConnectionLost is returned when the connection is lost before a response is received
+var ErrConnectionLost = errors.New("connection lost before receiving a response")
+
+// Error is returned when the server responds to a request with an AppError
+type Error struct {
+	Message string
+}
+
+func (err *Error) Error() string {
+	return "Rust+ request failed: " + err.Message
+}
+
+// IsError reports whether err is a Rust+ AppError with the given message
+func IsError(err error, message string) bool {
+	var appError *Error
+	return errors.As(err, &appError) && appError.Message == message
+}
+
+// SendRequest sends a request to the server and waits for the matching response, using the first valid credentials
+// (credentials that are rejected by the server are marked as invalid, and the request is retried with the next ones,
+// while rate limited requests are retried once the rate limit has recovered)
+func (rustplus *RustPlus) SendRequest(ctx context.Context, request *AppRequest) (*AppResponse, error) {
+	credentials, err := rustplus.validCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, credential := range credentials {
+		response, err := rustplus.sendRequestAs(ctx, request, credential)
+
+		// Wait for the rate limit to recover and try again
+		for attempt := 0; IsError(err, RateLimitError) && attempt < maxRateLimitRetries; attempt++ {
+			rustplus.logger.Warning("Rust+ request was rate limited, retrying..")
+			rustplus.scheduler.drain()
+			response, err = rustplus.sendRequestAs(ctx, request, credential)
+		}
+
+		var appError *Error
+		if errors.As(err, &appError) && isCredentialError(appError.Message) {
+			rustplus.invalidateCredential(credential, appError.Message)
+			continue
+		}
+		return response, err
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// sendRequestAs sends a request to the server using specific credentials and waits for the matching response
+func (rustplus *RustPlus) sendRequestAs(ctx context.Context, request *AppRequest, credential *Credential) (*AppResponse, error) {
+	if rustplus.isShuttingDown() {
+		return nil, errors.New("shutdown in progress")
+	}
+	if rustplus.connection() == nil {
+		return nil, ErrNotConnected
+	}
+
+	// Apply the default timeout if the caller didn't set one
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	// Give up as soon as we're closed, so that Close doesn't have to wait for the request to time out
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-rustplus.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Wait for our turn, so that we stay below the rate limits of the server
+	requestType := RequestType(request)
+	if err := rustplus.scheduler.acquire(ctx, requestType, requestPriority(ctx, requestType)); err != nil {
+		return nil, err
+	}
+	conn := rustplus.connection()
+	if conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	// Assign the next sequence number and start waiting for the response
+	responseChannel := make(chan *AppResponse, 1)
+	rustplus.pendingMutex.Lock()
+	rustplus.sequence++
+	if rustplus.sequence == 0 {
+		rustplus.sequence++
+	}
+	request.Seq = rustplus.sequence
+	rustplus.pendingRequests[request.Seq] = responseChannel
+	rustplus.pendingMutex.Unlock()
+	defer func() {
+		rustplus.pendingMutex.Lock()
+		delete(rustplus.pendingRequests, request.Seq)
+		rustplus.pendingMutex.Unlock()
+	}()
+
+	// Every request is authenticated with the player credentials
+	request.PlayerId = credential.PlayerID
+	request.PlayerToken = credential.PlayerToken
+
+	// Convert the request to protobuf and send it
+	data, err := proto.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	rustplus.writeMutex.Lock()
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	err = conn.WriteMessage(websocket.BinaryMessage, data)
+	rustplus.writeMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for the response
+	select {
+	case response, ok := <-responseChannel:
+		if !ok {
+			return nil, ErrConnectionLost
+		}
+		if response.Error != nil {
+			return nil, &Error{Message: response.Error.Error}
+		}
+		return response, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetInfo returns information about the server
+func (rustplus *RustPlus) GetInfo(ctx context.Context) (*AppInfo, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{GetInfo: &AppEmpty{}})
+	if err != nil {
+		return nil, err
+	}
+	return response.Info, nil
+}
+
+// GetTime returns the current in-game time
+func (rustplus *RustPlus) GetTime(ctx context.Context) (*AppTime, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{GetTime: &AppEmpty{}})
+	if err != nil {
+		return nil, err
+	}
+	return response.Time, nil
+}
+
+// GetMap returns the map image and monuments
+func (rustplus *RustPlus) GetMap(ctx context.Context) (*AppMap, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{GetMap: &AppEmpty{}})
+	if err != nil {
+		return nil, err
+	}
+	return response.Map, nil
+}
+
+// GetTeamInfo returns the team of the player
+func (rustplus *RustPlus) GetTeamInfo(ctx context.Context) (*AppTeamInfo, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{GetTeamInfo: &AppEmpty{}})
+	if err != nil {
+		return nil, err
+	}
+	return response.TeamInfo, nil
+}
+
+// GetTeamChat returns the recent team chat history
+func (rustplus *RustPlus) GetTeamChat(ctx context.Context) (*AppTeamChat, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{GetTeamChat: &AppEmpty{}})
+	if err != nil {
+		return nil, err
+	}
+	return response.TeamChat, nil
+}
+
+// SendTeamMessage sends a message to the team chat
+func (rustplus *RustPlus) SendTeamMessage(ctx context.Context, message string) error {
+	_, err := rustplus.SendRequest(ctx, &AppRequest{SendTeamMessage: &AppSendMessage{Message: message}})
+	return err
+}
+
+// GetEntityInfo returns the type and current state of a paired entity
+func (rustplus *RustPlus) GetEntityInfo(ctx context.Context, entityID uint32) (*AppEntityInfo, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{EntityId: entityID, GetEntityInfo: &AppEmpty{}})
+	if err != nil {
+		return nil, err
+	}
+	return response.EntityInfo, nil
+}
+
+// SetEntityValue changes the value of a paired entity (eg. turns a smart switch on or off)
+func (rustplus *RustPlus) SetEntityValue(ctx context.Context, entityID uint32, value bool) error {
+	_, err := rustplus.SendRequest(ctx, &AppRequest{EntityId: entityID, SetEntityValue: &AppSetEntityValue{Value: value}})
+	return err
+}
+
+// CheckSubscription reports whether notifications are enabled for a paired entity
+func (rustplus *RustPlus) CheckSubscription(ctx context.Context, entityID uint32) (bool, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{EntityId: entityID, CheckSubscription: &AppEmpty{}})
+	if err != nil {
+		return false, err
+	}
+	return response.Flag.GetValue(), nil
+}
+
+// SetSubscription enables or disables notifications for a paired entity
+func (rustplus *RustPlus) SetSubscription(ctx context.Context, entityID uint32, value bool) error {
+	_, err := rustplus.SendRequest(ctx, &AppRequest{EntityId: entityID, SetSubscription: &AppFlag{Value: value}})
+	return err
+}
+
+// GetMapMarkers returns the current map markers
+func (rustplus *RustPlus) GetMapMarkers(ctx context.Context) (*AppMapMarkers, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{GetMapMarkers: &AppEmpty{}})
+	if err != nil {
+		return nil, err
+	}
+	return response.MapMarkers, nil
+}
+
+// GetCameraFrame returns a single frame from a CCTV camera
+func (rustplus *RustPlus) GetCameraFrame(ctx context.Context, identifier string, frame uint32) (*AppCameraFrame, error) {
+	response, err := rustplus.SendRequest(ctx, &AppRequest{GetCameraFrame: &AppCameraFrameRequest{Identifier: identifier, Frame: frame}})
+	if err != nil {
+		return nil, err
+	}
+	return response.CameraFrame, nil
+}
+
+// PromoteToLeader makes another team member the team leader
+func (rustplus *RustPlus) PromoteToLeader(ctx context.Context, steamID uint64) error {
+	_, err := rustplus.SendRequest(ctx, &AppRequest{PromoteToLeader: &AppPromoteToLeader{SteamId: steamID}})
+	return err
+}
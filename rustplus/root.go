@@ -0,0 +1,261 @@
+package rustplus
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Dids/rustbot/database"
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/logger"
+	"github.com/Dids/rustbot/rustmap"
+
+	"github.com/gorilla/websocket"
+)
+
+// RustPlus is an abstraction around the Rust+ companion client
+type RustPlus struct {
+	EventHandler          *eventhandler.EventHandler
+	Database              *database.Database
+	DiscordMessageHandler chan eventhandler.Message
+	PlayerID              uint64
+	PlayerToken           int32
+
+	// Private properties
+	logger                 *logger.Logger
+	url                    string
+	broadcastHandler       chan *AppBroadcast
+	isReconnecting         bool
+	reconnectMutex         *sync.Mutex
+	writeMutex             *sync.Mutex
+	conn                   *websocket.Conn
+	generation             uint64 // Incremented for every connection attempt, so that callbacks from older connections can be told apart
+	connGeneration         uint64 // Generation of the current connection
+	connMutex              *sync.Mutex
+	closeMutex             *sync.Mutex
+	sequence               uint32
+	pendingRequests        map[uint32]chan *AppResponse
+	pendingMutex           *sync.Mutex
+	broadcastListeners     []*broadcastListener
+	broadcastMutex         *sync.Mutex
+	teamChatMutex          *sync.Mutex
+	upkeepThresholds       []time.Duration
+	upkeepWarnings         map[string]time.Duration
+	upkeepMutex            *sync.Mutex
+	worldMap               *rustmap.Map
+	mapImage               []byte
+	mapBase                *mapBase
+	mapMutex               *sync.Mutex
+	markerTracker          *markerTracker
+	eventMutex             *sync.Mutex
+	credentialMutex        *sync.Mutex
+	teamTracker            *teamTracker
+	teamMutex              *sync.Mutex
+	clockTracker           *clockTracker
+	clockMutex             *sync.Mutex
+	nightWarning           time.Duration
+	scheduler              *scheduler
+	stop                   chan struct{}
+	handlerDone            chan struct{}
+	tasks                  *sync.WaitGroup
+	isEnvCredentialInvalid bool
+}
+
+// NewRustPlus creates and returns a new instance of RustPlus
+func NewRustPlus(handler *eventhandler.EventHandler, db *database.Database) (*RustPlus, error) {
+	rustplus := &RustPlus{}
+
+	// Store a reference to the Logger
+	rustplus.logger = logger.GetLogger()
+
+	// Make sure that our collections exist and have the required indexes
+	for collection, indexes := range map[string][]string{
+		stateCollection: {"Key"},
+		"entities":      {"Key"},
+		"entity_groups": {"Key"},
+
+		"storage_snapshots": {"Key"},
+		"credentials":       {"Key"},
+		"team_events":       {"Key"},
+		"time_triggers":     {"Key"},
+	} {
+		result, err := db.GetCollection(collection)
+		if err != nil {
+			return nil, err
+		}
+		for _, index := range indexes {
+			result.Index([]string{index})
+		}
+	}
+
+	// Parse the (optional) fallback player credentials, used when no Discord user has registered valid credentials
+	if len(os.Getenv("RUSTPLUS_PLAYER_ID")) > 0 {
+		playerID, err := strconv.ParseUint(os.Getenv("RUSTPLUS_PLAYER_ID"), 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid RUSTPLUS_PLAYER_ID: " + err.Error())
+		}
+		rustplus.PlayerID = playerID
+		playerToken, err := strconv.ParseInt(os.Getenv("RUSTPLUS_PLAYER_TOKEN"), 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid RUSTPLUS_PLAYER_TOKEN: " + err.Error())
+		}
+		rustplus.PlayerToken = int32(playerToken)
+	}
+
+	// Parse the upkeep warning thresholds
+	upkeepThresholds, err := parseUpkeepThresholds(os.Getenv("RUSTPLUS_UPKEEP_THRESHOLDS"))
+	if err != nil {
+		return nil, errors.New("invalid RUSTPLUS_UPKEEP_THRESHOLDS: " + err.Error())
+	}
+	rustplus.upkeepThresholds = upkeepThresholds
+
+	// Parse how long before sunset to warn about nightfall
+	nightWarning, err := parseNightWarning(os.Getenv("RUSTPLUS_NIGHT_WARNING"))
+	if err != nil {
+		return nil, errors.New("invalid RUSTPLUS_NIGHT_WARNING: " + err.Error())
+	}
+	rustplus.nightWarning = nightWarning
+
+	// Create the request scheduler, which keeps us below the rate limits of the server
+	requestScheduler, err := newSchedulerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	rustplus.scheduler = requestScheduler
+
+	// Every connection gets its own websocket client (see connect)
+	rustplus.url = "ws://" + os.Getenv("RUSTPLUS_HOST") + ":" + os.Getenv("RUSTPLUS_PORT")
+
+	// Create the mutexes and request tracking
+	rustplus.writeMutex = &sync.Mutex{}
+	rustplus.connMutex = &sync.Mutex{}
+	rustplus.closeMutex = &sync.Mutex{}
+	rustplus.tasks = &sync.WaitGroup{}
+	rustplus.reconnectMutex = &sync.Mutex{}
+	rustplus.pendingMutex = &sync.Mutex{}
+	rustplus.broadcastMutex = &sync.Mutex{}
+	rustplus.teamChatMutex = &sync.Mutex{}
+	rustplus.upkeepMutex = &sync.Mutex{}
+	rustplus.mapMutex = &sync.Mutex{}
+	rustplus.eventMutex = &sync.Mutex{}
+	rustplus.credentialMutex = &sync.Mutex{}
+	rustplus.teamMutex = &sync.Mutex{}
+	rustplus.clockMutex = &sync.Mutex{}
+	rustplus.markerTracker = &markerTracker{}
+	rustplus.teamTracker = &teamTracker{}
+	rustplus.clockTracker = &clockTracker{}
+	rustplus.upkeepWarnings = make(map[string]time.Duration)
+	rustplus.pendingRequests = make(map[uint32]chan *AppResponse)
+
+	// Setup our custom event handler and our own broadcast listener
+	rustplus.DiscordMessageHandler = make(chan eventhandler.Message)
+	rustplus.broadcastHandler = make(chan *AppBroadcast)
+	rustplus.EventHandler = handler
+	rustplus.EventHandler.AddListener("receive_discord_team_message", rustplus.DiscordMessageHandler)
+	rustplus.AddBroadcastListener(rustplus.broadcastHandler)
+	rustplus.stop = make(chan struct{})
+	rustplus.handlerDone = make(chan struct{})
+	go func() {
+		defer close(rustplus.handlerDone)
+		for {
+			select {
+			case <-rustplus.stop:
+				return
+			case message := <-rustplus.DiscordMessageHandler:
+				rustplus.handleIncomingDiscordMessage(message)
+			case broadcast := <-rustplus.broadcastHandler:
+				rustplus.handleBroadcast(broadcast)
+			}
+		}
+	}()
+
+	// Store the database reference
+	rustplus.Database = db
+
+	return rustplus, nil
+}
+
+// Open will start the Rust+ client and connect to the server
+func (rustplus *RustPlus) Open() error {
+	if rustplus.isShuttingDown() {
+		rustplus.logger.Warning("Already shutting down!")
+		return errors.New("shutdown in progress")
+	}
+
+	rustplus.logger.Info("Opening Rust+..")
+
+	// Establish the websocket connection
+	rustplus.connect()
+
+	// Start sending PING messages
+	rustplus.startTask(rustplus.startPinging)
+
+	// Start checking tool cupboard upkeep
+	rustplus.startTask(rustplus.startUpkeepChecks)
+
+	// Start checking the map markers for world events
+	rustplus.startTask(rustplus.startEventPolling)
+
+	// Start checking the in-game time for day/night changes
+	rustplus.startTask(rustplus.startClockPolling)
+
+	return nil
+}
+
+// Close will gracefully shutdown and cleanup the Rust+ connection
+func (rustplus *RustPlus) Close() error {
+	rustplus.closeMutex.Lock()
+	if rustplus.isShuttingDown() {
+		rustplus.closeMutex.Unlock()
+		rustplus.logger.Warning("Already shutting down!")
+		return errors.New("shutdown in progress")
+	}
+
+	// Stops the message and broadcast handler, along with all of our background tasks
+	close(rustplus.stop)
+	rustplus.closeMutex.Unlock()
+
+	rustplus.logger.Info("Closing Rust+..")
+
+	// Fail any requests that are still waiting for a response
+	rustplus.cancelPendingRequests()
+
+	rustplus.EventHandler.RemoveListener("receive_discord_team_message", rustplus.DiscordMessageHandler)
+	rustplus.RemoveBroadcastListener(rustplus.broadcastHandler)
+
+	// Wait for the message and broadcast handler and our background tasks to finish, so nothing touches the database after we're closed
+	<-rustplus.handlerDone
+	rustplus.tasks.Wait()
+
+	rustplus.closeConnection()
+	rustplus.logger.Trace("Successfully shut down the Rust+ client!")
+
+	return nil
+}
+
+// startTask runs a background task that Close waits for, unless we're already shutting down
+func (rustplus *RustPlus) startTask(task func()) {
+	rustplus.closeMutex.Lock()
+	defer rustplus.closeMutex.Unlock()
+	if rustplus.isShuttingDown() {
+		return
+	}
+
+	rustplus.tasks.Add(1)
+	go func() {
+		defer rustplus.tasks.Done()
+		task()
+	}()
+}
+
+// isShuttingDown reports whether Close has been called
+func (rustplus *RustPlus) isShuttingDown() bool {
+	select {
+	case <-rustplus.stop:
+		return true
+	default:
+		return false
+	}
+}
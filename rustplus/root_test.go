@@ -0,0 +1,570 @@
+package rustplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dids/rustbot/database"
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/rustmap"
+	"github.com/gorilla/websocket"
+)
+
+func TestIsError(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &Error{Message: RateLimitError})
+	if !IsError(err, RateLimitError) {
+		t.Fatal("expected a rate limit error:", err)
+	}
+	if IsError(err, NotFoundError) {
+		t.Fatal("did not expect a not found error:", err)
+	}
+	if IsError(errors.New(RateLimitError), RateLimitError) {
+		t.Fatal("plain errors should not be treated as Rust+ errors")
+	}
+}
+
+func TestDiffStorageSnapshots(t *testing.T) {
+	previous := &StorageSnapshot{Items: []StorageItem{
+		{ItemID: -1581843485, Quantity: 1000},
+		{ItemID: -1581843485, Quantity: 1000},
+		{ItemID: 69511070, Quantity: 500},
+	}}
+	current := &StorageSnapshot{Items: []StorageItem{
+		{ItemID: 69511070, Quantity: 500},
+		{ItemID: -932201673, Quantity: 50},
+		{ItemID: -932201673, Quantity: 1, Blueprint: true},
+	}}
+
+	changes := DiffStorageSnapshots(previous, current)
+	expected := []StorageChange{
+		{ItemID: -932201673, Quantity: 50},
+		{ItemID: -932201673, Quantity: 1, Blueprint: true},
+		{ItemID: -1581843485, Quantity: -2000},
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(expected) {
+		t.Fatal("expected", expected, "but got", changes)
+	}
+
+	if changes := DiffStorageSnapshots(current, current); len(changes) != 0 {
+		t.Fatal("expected no changes but got", changes)
+	}
+}
+
+func TestParseUpkeepThresholds(t *testing.T) {
+	thresholds, err := parseUpkeepThresholds("")
+	if err != nil || fmt.Sprint(thresholds) != fmt.Sprint(defaultUpkeepThresholds) {
+		t.Fatal("expected the default thresholds but got", thresholds, err)
+	}
+	thresholds, err = parseUpkeepThresholds("12h, 30m")
+	if err != nil || fmt.Sprint(thresholds) != fmt.Sprint([]time.Duration{12 * time.Hour, 30 * time.Minute}) {
+		t.Fatal("unexpected thresholds:", thresholds, err)
+	}
+	if _, err := parseUpkeepThresholds("1h,soon"); err == nil {
+		t.Fatal("expected an error for an invalid threshold")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for duration, expected := range map[time.Duration]string{
+		30 * time.Second:              "<1m",
+		45 * time.Minute:              "45m",
+		6 * time.Hour:                 "6h",
+		28*time.Hour + 30*time.Minute: "1d 4h 30m",
+		48*time.Hour + 59*time.Second: "2d",
+	} {
+		if result := FormatDuration(duration); result != expected {
+			t.Fatal("expected", expected, "for", duration, "but got", result)
+		}
+	}
+}
+
+func TestFindItems(t *testing.T) {
+	for query, expected := range map[string][]int32{
+		"sulfur":  {-1581843485},
+		" SULFUR": {-1581843485},
+		"sulf":    {-1581843485, -1157596551},
+		"12345":   {12345},
+		"nothing": {},
+	} {
+		if result := FindItems(query); fmt.Sprint(result) != fmt.Sprint(expected) {
+			t.Fatal("expected", expected, "for", query, "but got", result)
+		}
+	}
+}
+
+func TestMarkerTracker(t *testing.T) {
+	tracker := &markerTracker{}
+	world := &rustmap.Map{Size: 3000}
+	messages := func(events []eventhandler.Message) []string {
+		result := make([]string, 0)
+		for _, event := range events {
+			result = append(result, string(event.Type)+": "+event.Message)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	// Markers that are already on the map aren't announced
+	cargo := &AppMarker{Id: 1, Type: AppMarkerType_CargoShip, X: 100, Y: 100}
+	if events := tracker.update([]*AppMarker{cargo}, world); len(events) != 0 {
+		t.Fatal("expected no events but got", messages(events))
+	}
+
+	// Vehicles are announced once they've moved, stationary markers right away
+	heli := &AppMarker{Id: 2, Type: AppMarkerType_PatrolHelicopter, X: 2900, Y: 2900}
+	crate := &AppMarker{Id: 3, Type: AppMarkerType_Crate, X: 1500, Y: 1500}
+	if events := messages(tracker.update([]*AppMarker{cargo, heli, crate}, world)); fmt.Sprint(events) != fmt.Sprint([]string{"CrateSpawned: Locked crate appeared at K10"}) {
+		t.Fatal("unexpected events:", events)
+	}
+	heli = &AppMarker{Id: 2, Type: AppMarkerType_PatrolHelicopter, X: 2800, Y: 2800}
+	if events := messages(tracker.update([]*AppMarker{cargo, heli, crate}, world)); fmt.Sprint(events) != fmt.Sprint([]string{"HeliSpawned: Patrol helicopter entered the map at S1, heading south-west"}) {
+		t.Fatal("unexpected events:", events)
+	}
+
+	// The helicopter disappearing next to a new explosion means it was taken down
+	explosion := &AppMarker{Id: 4, Type: AppMarkerType_Explosion, X: 2750, Y: 2750}
+	if events := messages(tracker.update([]*AppMarker{explosion}, world)); fmt.Sprint(events) != fmt.Sprint([]string{
+		"CargoLeft: Cargo ship left the map",
+		"CrateGone: Locked crate at K10 is gone",
+		"HeliDown: Patrol helicopter was taken down at S1",
+	}) {
+		t.Fatal("unexpected events:", events)
+	}
+
+	// Other explosions are announced as is
+	other := &AppMarker{Id: 5, Type: AppMarkerType_Explosion, X: 100, Y: 2900}
+	if events := messages(tracker.update([]*AppMarker{explosion, other}, world)); fmt.Sprint(events) != fmt.Sprint([]string{"Explosion: Explosion at A0"}) {
+		t.Fatal("unexpected events:", events)
+	}
+}
+
+func TestCH47Events(t *testing.T) {
+	tracker := &markerTracker{}
+	world := &rustmap.Map{Size: 3000, Monuments: []rustmap.Monument{{Token: "large_oil_rig", X: 300, Y: 1500}}}
+	messages := func(events []eventhandler.Message) []string {
+		result := make([]string, 0)
+		for _, event := range events {
+			result = append(result, string(event.Type)+": "+event.Message)
+		}
+		sort.Strings(result)
+		return result
+	}
+	tracker.update(nil, world)
+
+	// A CH47 heading towards an oil rig is on its way to drop off scientists
+	oilRig := &AppMarker{Id: 1, Type: AppMarkerType_CH47, X: 1500, Y: 1500}
+	patrol := &AppMarker{Id: 2, Type: AppMarkerType_CH47, X: 1500, Y: 2000}
+	tracker.update([]*AppMarker{oilRig, patrol}, world)
+	oilRig = &AppMarker{Id: 1, Type: AppMarkerType_CH47, X: 1400, Y: 1500}
+	patrol = &AppMarker{Id: 2, Type: AppMarkerType_CH47, X: 1500, Y: 2100}
+	if events := messages(tracker.update([]*AppMarker{oilRig, patrol}, world)); fmt.Sprint(events) != fmt.Sprint([]string{
+		"CH47OilRig: CH47 spotted at " + world.Location(1400, 1500) + ", heading to the Large Oil Rig",
+		"CH47Spawned: CH47 spotted at " + world.Location(1500, 2100) + ", heading north",
+	}) {
+		t.Fatal("unexpected events:", events)
+	}
+
+	// A locked crate appearing next to any other CH47 was airdropped by it
+	dropped := &AppMarker{Id: 3, Type: AppMarkerType_Crate, X: 1500, Y: 2150}
+	other := &AppMarker{Id: 4, Type: AppMarkerType_Crate, X: 1350, Y: 1500}
+	if events := messages(tracker.update([]*AppMarker{oilRig, patrol, dropped, other}, world)); fmt.Sprint(events) != fmt.Sprint([]string{
+		"CH47Airdrop: CH47 dropped a locked crate at " + world.Location(1500, 2150),
+		"CrateSpawned: Locked crate appeared at " + world.Location(1350, 1500),
+	}) {
+		t.Fatal("unexpected events:", events)
+	}
+}
+
+func TestTravelDirection(t *testing.T) {
+	for _, test := range []struct {
+		dx, dy   float32
+		expected string
+	}{
+		{0, 0, ""},
+		{10, 0, "east"},
+		{10, 10, "north-east"},
+		{0, 10, "north"},
+		{-10, 0, "west"},
+		{-10, -10, "south-west"},
+		{0, -10, "south"},
+		{10, -9, "south-east"},
+	} {
+		if result := travelDirection(100, 100, 100+test.dx, 100+test.dy); result != test.expected {
+			t.Fatal("expected", test.expected, "for", test.dx, test.dy, "but got", result)
+		}
+	}
+}
+
+func TestValidCredentials(t *testing.T) {
+	db, err := database.NewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(db.Path)
+	defer os.RemoveAll(db.Path)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	t.Setenv("RUSTPLUS_PLAYER_ID", "76561197960287930")
+	t.Setenv("RUSTPLUS_PLAYER_TOKEN", "-123")
+	rustplus, err := NewRustPlus(&eventhandler.EventHandler{}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	playerIDs := func() []uint64 {
+		credentials, err := rustplus.validCredentials()
+		if err != nil {
+			return nil
+		}
+		result := make([]uint64, 0)
+		for _, credential := range credentials {
+			result = append(result, credential.PlayerID)
+		}
+		return result
+	}
+
+	// Registered credentials are used before the ones from the environment variables
+	first := &Credential{DiscordID: "1", PlayerID: 76561197960287931, PlayerToken: 1, Valid: true, Registered: time.Now().Add(-time.Hour)}
+	second := &Credential{DiscordID: "2", PlayerID: 76561197960287932, PlayerToken: 2, Valid: true, Registered: time.Now()}
+	for _, credential := range []*Credential{first, second} {
+		if err := saveCredential(db, credential); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if result := playerIDs(); fmt.Sprint(result) != fmt.Sprint([]uint64{76561197960287931, 76561197960287932, 76561197960287930}) {
+		t.Fatal("unexpected credentials:", result)
+	}
+
+	// Rejected credentials are skipped
+	rustplus.invalidateCredential(first, AccessDeniedError)
+	rustplus.invalidateCredential(&Credential{PlayerID: rustplus.PlayerID}, BannedError)
+	if result := playerIDs(); fmt.Sprint(result) != fmt.Sprint([]uint64{76561197960287932}) {
+		t.Fatal("unexpected credentials:", result)
+	}
+	if credential, err := rustplus.GetCredential("1"); err != nil || credential.Valid || credential.Error != AccessDeniedError || credential.PlayerToken != 1 {
+		t.Fatal("expected invalid credentials but got", credential, err)
+	}
+
+	rustplus.invalidateCredential(second, NoPlayerError)
+	if _, err := rustplus.validCredentials(); err != ErrNoCredentials {
+		t.Fatal("expected no valid credentials but got", err)
+	}
+}
+
+func TestTeamTracker(t *testing.T) {
+	tracker := &teamTracker{}
+	world := &rustmap.Map{Size: 3000}
+	now := time.Now()
+	messages := func(events []*TeamEvent) []string {
+		result := make([]string, 0)
+		for _, event := range events {
+			result = append(result, string(event.Type)+": "+event.Message)
+		}
+		return result
+	}
+	team := func(leader uint64, members ...*AppTeamInfo_Member) *AppTeamInfo {
+		return &AppTeamInfo{LeaderSteamId: leader, Members: members}
+	}
+
+	// The initial team isn't announced
+	alice := &AppTeamInfo_Member{SteamId: 1, Name: "Alice", IsOnline: true, IsAlive: true, SpawnTime: 100}
+	bob := &AppTeamInfo_Member{SteamId: 2, Name: "Bob", IsOnline: true, IsAlive: true, SpawnTime: 100}
+	if events := tracker.update(team(1, alice, bob), world, now); len(events) != 0 {
+		t.Fatal("expected no events but got", messages(events))
+	}
+
+	// Presence, deaths and leader changes
+	deadAlice := &AppTeamInfo_Member{SteamId: 1, Name: "Alice", IsOnline: true, IsAlive: false, SpawnTime: 100, DeathTime: 200, X: 1500, Y: 1450}
+	offlineBob := &AppTeamInfo_Member{SteamId: 2, Name: "Bob", IsOnline: false, IsAlive: true, SpawnTime: 100}
+	if events := messages(tracker.update(team(2, deadAlice, offlineBob), world, now)); fmt.Sprint(events) != fmt.Sprint([]string{
+		"TeamDeath: Alice died at K10",
+		"TeamOffline: Bob went offline",
+		"TeamLeader: Bob is now the team leader",
+	}) {
+		t.Fatal("unexpected events:", events)
+	}
+	respawnedAlice := &AppTeamInfo_Member{SteamId: 1, Name: "Alice", IsOnline: true, IsAlive: true, SpawnTime: 300, DeathTime: 200}
+	if events := messages(tracker.update(team(2, respawnedAlice, offlineBob), world, now)); fmt.Sprint(events) != fmt.Sprint([]string{"TeamRespawn: Alice respawned"}) {
+		t.Fatal("unexpected events:", events)
+	}
+
+	// Dying and respawning in between updates
+	againAlice := &AppTeamInfo_Member{SteamId: 1, Name: "Alice", IsOnline: true, IsAlive: true, SpawnTime: 500, DeathTime: 400}
+	if events := messages(tracker.update(team(2, againAlice, offlineBob), world, now)); fmt.Sprint(events) != fmt.Sprint([]string{"TeamDeath: Alice died", "TeamRespawn: Alice respawned"}) {
+		t.Fatal("unexpected events:", events)
+	}
+
+	// Members joining and leaving
+	carol := &AppTeamInfo_Member{SteamId: 3, Name: "Carol", IsOnline: true, IsAlive: true}
+	if events := messages(tracker.update(team(2, againAlice, carol), world, now)); fmt.Sprint(events) != fmt.Sprint([]string{"TeamJoin: Carol joined the team", "TeamLeave: Bob left the team"}) {
+		t.Fatal("unexpected events:", events)
+	}
+}
+
+func TestClock(t *testing.T) {
+	clock := &Clock{Time: 13.5, Sunrise: 7, Sunset: 20, DayLengthMinutes: 48, TimeScale: 1}
+	if clock.String() != "13:30" || !clock.IsDay() {
+		t.Fatal("unexpected clock:", clock.String(), clock.IsDay())
+	}
+
+	// A day lasts 48 minutes, so every in-game hour lasts 2 minutes
+	if until := clock.UntilSunset(); until != 13*time.Minute {
+		t.Fatal("expected 13m until sunset but got", until)
+	}
+	if until := clock.UntilSunrise(); until != 35*time.Minute {
+		t.Fatal("expected 35m until sunrise but got", until)
+	}
+
+	// Time passes twice as fast with a time scale of 2
+	clock.TimeScale = 2
+	if until := clock.UntilSunset(); until != 6*time.Minute+30*time.Second {
+		t.Fatal("expected 6m30s until sunset but got", until)
+	}
+
+	clock = &Clock{Time: 23.99, Sunrise: 7, Sunset: 20}
+	if clock.String() != "23:59" || clock.IsDay() {
+		t.Fatal("unexpected clock:", clock.String(), clock.IsDay())
+	}
+}
+
+func TestClockTracker(t *testing.T) {
+	tracker := &clockTracker{}
+	messages := func(events []eventhandler.Message) []string {
+		result := make([]string, 0)
+		for _, event := range events {
+			result = append(result, string(event.Type)+": "+event.Message)
+		}
+		return result
+	}
+	clock := func(hour float64) *Clock {
+		return &Clock{Time: hour, Sunrise: 7, Sunset: 20, DayLengthMinutes: 60, TimeScale: 1}
+	}
+
+	// The initial state isn't announced
+	if events := tracker.update(clock(12), 5*time.Minute); len(events) != 0 {
+		t.Fatal("expected no events but got", messages(events))
+	}
+
+	// Every in-game hour lasts 2.5 minutes, so the warning comes 2 hours before sunset
+	if events := tracker.update(clock(17.9), 5*time.Minute); len(events) != 0 {
+		t.Fatal("expected no events but got", messages(events))
+	}
+	for _, test := range []struct {
+		hour     float64
+		expected []string
+	}{
+		{18.1, []string{"NightfallSoon: Night falls in 5m"}},
+		{19, []string{}},
+		{20.1, []string{"Nightfall: Night has fallen"}},
+		{3, []string{}},
+		{7, []string{"Sunrise: The sun is rising"}},
+		{18.5, []string{"NightfallSoon: Night falls in 4m"}},
+	} {
+		if events := messages(tracker.update(clock(test.hour), 5*time.Minute)); fmt.Sprint(events) != fmt.Sprint(test.expected) {
+			t.Fatal("expected", test.expected, "at", test.hour, "but got", events)
+		}
+	}
+}
+
+func TestEncodeCameraGIF(t *testing.T) {
+	if _, err := EncodeCameraGIF(nil, time.Second); err == nil {
+		t.Fatal("expected an error without frames")
+	}
+	if _, err := EncodeCameraGIF([][]byte{[]byte("not a jpeg")}, time.Second); err == nil {
+		t.Fatal("expected an error for an invalid frame")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	scheduler := newScheduler(2, 5, map[string]float64{"getMap": 5})
+	if cost := scheduler.cost("getMap"); cost != 2 {
+		t.Fatal("expected costs to be capped at the capacity but got", cost)
+	}
+
+	// Empty the bucket, so that the next requests have to wait
+	for i := 0; i < 2; i++ {
+		if err := scheduler.acquire(context.Background(), "getInfo", PriorityNormal); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Cancelled requests leave the queue
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := scheduler.acquire(ctx, "getInfo", PriorityNormal); err != context.DeadlineExceeded {
+		t.Fatal("expected the request to time out but got", err)
+	}
+	if scheduler.next() != nil {
+		t.Fatal("expected the queue to be empty")
+	}
+
+	// Higher priority requests go first, even when they're queued later
+	queued := func(priority Priority) bool {
+		scheduler.mutex.Lock()
+		defer scheduler.mutex.Unlock()
+		return len(scheduler.queues[priority]) > 0
+	}
+	order := make(chan Priority, 2)
+	for _, priority := range []Priority{PriorityLow, PriorityHigh} {
+		go func(priority Priority) {
+			if err := scheduler.acquire(context.Background(), "getInfo", priority); err == nil {
+				order <- priority
+			}
+		}(priority)
+		for !queued(priority) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for _, expected := range []Priority{PriorityHigh, PriorityLow} {
+		select {
+		case priority := <-order:
+			if priority != expected {
+				t.Fatal("expected priority", expected, "but got", priority)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the scheduler")
+		}
+	}
+}
+
+func TestParseRequestCosts(t *testing.T) {
+	costs, err := parseRequestCosts("getMap=10, sendTeamMessage=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if costs["getMap"] != 10 || costs["sendTeamMessage"] != 0 || costs["getInfo"] != 1 {
+		t.Fatal("unexpected costs:", costs)
+	}
+	for _, value := range []string{"getMap", "getMap=-1", "getMap=x", "unknown=1"} {
+		if _, err := parseRequestCosts(value); err == nil {
+			t.Fatal("expected an error for", value)
+		}
+	}
+}
+
+func TestBroadcastListeners(t *testing.T) {
+	rustplus := &RustPlus{broadcastMutex: &sync.Mutex{}}
+	listener := make(chan *AppBroadcast)
+	removed := make(chan *AppBroadcast)
+	rustplus.AddBroadcastListener(listener)
+	rustplus.AddBroadcastListener(removed)
+
+	// Nobody reads the removed listener, which must not hold up the others or leak its delivery goroutine
+	rustplus.RemoveBroadcastListener(removed)
+	for i := uint32(1); i <= 100; i++ {
+		rustplus.emitBroadcast(&AppBroadcast{TeamMessage: &AppTeamMessage{Message: &AppChatMessage{Time: i}}})
+	}
+	for i := uint32(1); i <= 100; i++ {
+		select {
+		case broadcast := <-listener:
+			if broadcast.TeamMessage.Message.Time != i {
+				t.Fatal("expected broadcast", i, "but got", broadcast.TeamMessage.Message.Time)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for broadcast", i)
+		}
+	}
+	if len(rustplus.broadcastListeners) != 1 {
+		t.Fatal("expected a single listener but got", len(rustplus.broadcastListeners))
+	}
+	rustplus.RemoveBroadcastListener(listener)
+}
+
+func TestRelayTeamMessage(t *testing.T) {
+	db, err := database.NewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(db.Path)
+	defer os.RemoveAll(db.Path)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	handler := &eventhandler.EventHandler{}
+	messages := make(chan eventhandler.Message, 10)
+	handler.AddListener("receive_rustplus_message", messages)
+	rustplus, err := NewRustPlus(handler, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rustplus.Close()
+
+	// Backfilled messages are only relayed if they weren't relayed yet (even when sent within the same second as the last relayed one),
+	// and are delivered in the order they were sent
+	now := uint32(time.Now().Unix())
+	rustplus.relayTeamMessage(&AppChatMessage{SteamId: 1, Name: "Alice", Message: "live", Time: now}, false)
+	rustplus.relayMissedTeamMessages([]*AppChatMessage{
+		{SteamId: 1, Name: "Alice", Message: "newest", Time: now + 3},
+		{SteamId: 1, Name: "Alice", Message: "live", Time: now},
+		{SteamId: 2, Name: "Bob", Message: "first", Time: now + 1},
+		{SteamId: 1, Name: "Alice", Message: "older", Time: now - 1},
+		{SteamId: 2, Name: "Bob", Message: "same second", Time: now},
+		{SteamId: 1, Name: "Alice", Message: "second", Time: now + 1},
+		{SteamId: 2, Name: "Bob", Message: "third", Time: now + 2},
+	})
+	rustplus.relayMissedTeamMessages([]*AppChatMessage{
+		{SteamId: 2, Name: "Bob", Message: "same second", Time: now},
+		{SteamId: 1, Name: "Alice", Message: "newest", Time: now + 3},
+	})
+
+	for _, expected := range []string{"live", "same second", "first", "second", "third", "newest"} {
+		select {
+		case message := <-messages:
+			if message.Message != expected {
+				t.Fatal("expected message", expected, "but got", message.Message)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for message", expected)
+		}
+	}
+	select {
+	case message := <-messages:
+		t.Fatal("did not expect message:", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHandleDisconnect(t *testing.T) {
+	db, err := database.NewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(db.Path)
+	defer os.RemoveAll(db.Path)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rustplus, err := NewRustPlus(&eventhandler.EventHandler{}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rustplus.Close()
+
+	// A late disconnect from a previous connection leaves the current one alone
+	conn := &websocket.Conn{}
+	rustplus.generation = 2
+	rustplus.conn = conn
+	rustplus.connGeneration = 2
+	rustplus.handleDisconnect(1, errors.New("connection lost"))
+	if rustplus.connection() != conn {
+		t.Fatal("expected the current connection to be kept")
+	}
+
+	// The current connection is only torn down by its own disconnect
+	rustplus.handleDisconnect(2, nil)
+	if rustplus.connection() != nil {
+		t.Fatal("expected the current connection to be removed")
+	}
+}
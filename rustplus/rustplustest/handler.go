@@ -0,0 +1,209 @@
+package rustplustest
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Dids/rustbot/rustplus"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+func (server *Server) handleConnection(writer http.ResponseWriter, request *http.Request) {
+	connection, err := server.upgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		return
+	}
+
+	server.mutex.Lock()
+	server.connections[connection] = &sync.Mutex{}
+	server.mutex.Unlock()
+	defer func() {
+		server.mutex.Lock()
+		delete(server.connections, connection)
+		server.mutex.Unlock()
+		connection.Close()
+	}()
+
+	for {
+		messageType, data, err := connection.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		request := &rustplus.AppRequest{}
+		if err := proto.Unmarshal(data, request); err != nil {
+			continue
+		}
+
+		// Like the real server, the response is sent before any broadcast caused by the request
+		response, broadcast := server.handleRequest(request)
+		response.Seq = request.Seq
+		server.send(connection, &rustplus.AppMessage{Response: response})
+		if broadcast != nil {
+			server.Broadcast(broadcast)
+		}
+	}
+}
+
+// handleRequest answers a single request from the scripted state, returning the response and an optional broadcast
+func (server *Server) handleRequest(request *rustplus.AppRequest) (*rustplus.AppResponse, *rustplus.AppBroadcast) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.requests = append(server.requests, proto.Clone(request).(*rustplus.AppRequest))
+	requestType := rustplus.RequestType(request)
+
+	// Verify the player credentials
+	if len(server.players) > 0 {
+		playerToken, ok := server.players[request.PlayerId]
+		if !ok {
+			return errorResponse(rustplus.NoPlayerError), nil
+		}
+		if playerToken != request.PlayerToken {
+			return errorResponse(rustplus.AccessDeniedError), nil
+		}
+	}
+
+	// Enforce the rate limit
+	if server.rateLimit > 0 {
+		if time.Since(server.rateWindow) >= server.ratePeriod {
+			server.rateWindow = time.Now()
+			server.rateCount = 0
+		}
+		server.rateCount++
+		if server.rateCount > server.rateLimit {
+			return errorResponse(rustplus.RateLimitError), nil
+		}
+	}
+
+	// Return any injected errors
+	for _, key := range []string{requestType, ""} {
+		if messages := server.errors[key]; len(messages) > 0 {
+			server.errors[key] = messages[1:]
+			return errorResponse(messages[0]), nil
+		}
+	}
+
+	switch requestType {
+	case "getInfo":
+		return &rustplus.AppResponse{Info: proto.Clone(server.info).(*rustplus.AppInfo)}, nil
+	case "getTime":
+		return &rustplus.AppResponse{Time: proto.Clone(server.time).(*rustplus.AppTime)}, nil
+	case "getMap":
+		return &rustplus.AppResponse{Map: proto.Clone(server.worldMap).(*rustplus.AppMap)}, nil
+	case "getTeamInfo":
+		return &rustplus.AppResponse{TeamInfo: proto.Clone(server.teamInfo).(*rustplus.AppTeamInfo)}, nil
+	case "getTeamChat":
+		teamChat := &rustplus.AppTeamChat{}
+		for _, message := range server.teamChat {
+			teamChat.Messages = append(teamChat.Messages, proto.Clone(message).(*rustplus.AppChatMessage))
+		}
+		return &rustplus.AppResponse{TeamChat: teamChat}, nil
+	case "sendTeamMessage":
+		message := &rustplus.AppChatMessage{SteamId: request.PlayerId, Name: server.playerName(request.PlayerId), Message: request.SendTeamMessage.Message, Color: "#5af", Time: uint32(time.Now().Unix())}
+		server.teamChat = append(server.teamChat, message)
+		return successResponse(), &rustplus.AppBroadcast{TeamMessage: &rustplus.AppTeamMessage{Message: message}}
+	case "getEntityInfo":
+		info, ok := server.entities[request.EntityId]
+		if !ok {
+			return errorResponse(rustplus.NotFoundError), nil
+		}
+		return &rustplus.AppResponse{EntityInfo: proto.Clone(info).(*rustplus.AppEntityInfo)}, nil
+	case "setEntityValue":
+		info, ok := server.entities[request.EntityId]
+		if !ok {
+			return errorResponse(rustplus.NotFoundError), nil
+		}
+		if info.Type != rustplus.AppEntityType_Switch {
+			return errorResponse(rustplus.WrongTypeError), nil
+		}
+		if info.Payload == nil {
+			info.Payload = &rustplus.AppEntityPayload{}
+		}
+		info.Payload.Value = request.SetEntityValue.Value
+		payload := proto.Clone(info.Payload).(*rustplus.AppEntityPayload)
+		return successResponse(), &rustplus.AppBroadcast{EntityChanged: &rustplus.AppEntityChanged{EntityId: request.EntityId, Payload: payload}}
+	case "checkSubscription":
+		if _, ok := server.entities[request.EntityId]; !ok {
+			return errorResponse(rustplus.NotFoundError), nil
+		}
+		return &rustplus.AppResponse{Flag: &rustplus.AppFlag{Value: server.subscriptions[request.EntityId]}}, nil
+	case "setSubscription":
+		if _, ok := server.entities[request.EntityId]; !ok {
+			return errorResponse(rustplus.NotFoundError), nil
+		}
+		server.subscriptions[request.EntityId] = request.SetSubscription.Value
+		return successResponse(), nil
+	case "getMapMarkers":
+		markers := &rustplus.AppMapMarkers{}
+		for _, marker := range server.markers {
+			markers.Markers = append(markers.Markers, proto.Clone(marker).(*rustplus.AppMarker))
+		}
+		return &rustplus.AppResponse{MapMarkers: markers}, nil
+	case "getCameraFrame":
+		jpgImage, ok := server.cameras[request.GetCameraFrame.Identifier]
+		if !ok {
+			return errorResponse(rustplus.NotFoundError), nil
+		}
+		return &rustplus.AppResponse{CameraFrame: &rustplus.AppCameraFrame{Frame: request.GetCameraFrame.Frame, JpgImage: jpgImage}}, nil
+	case "promoteToLeader":
+		if server.teamInfo.LeaderSteamId != request.PlayerId {
+			return errorResponse(rustplus.AccessDeniedError), nil
+		}
+		if len(server.playerName(request.PromoteToLeader.SteamId)) <= 0 {
+			return errorResponse(rustplus.NoPlayerError), nil
+		}
+		server.teamInfo.LeaderSteamId = request.PromoteToLeader.SteamId
+		teamInfo := proto.Clone(server.teamInfo).(*rustplus.AppTeamInfo)
+		return successResponse(), &rustplus.AppBroadcast{TeamChanged: &rustplus.AppTeamChanged{PlayerId: request.PlayerId, TeamInfo: teamInfo}}
+	}
+
+	return errorResponse("unknown_request"), nil
+}
+
+// playerName returns the name of a team member, or an empty string if the player isn't in the team
+func (server *Server) playerName(steamID uint64) string {
+	for _, member := range server.teamInfo.GetMembers() {
+		if member.SteamId == steamID {
+			return member.Name
+		}
+	}
+	return ""
+}
+
+// send writes a message to a single client, or to every client if the connection is nil
+func (server *Server) send(connection *websocket.Conn, message *rustplus.AppMessage) {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	server.mutex.Lock()
+	targets := make(map[*websocket.Conn]*sync.Mutex)
+	for target, writeMutex := range server.connections {
+		if connection == nil || target == connection {
+			targets[target] = writeMutex
+		}
+	}
+	server.mutex.Unlock()
+
+	for target, writeMutex := range targets {
+		writeMutex.Lock()
+		target.WriteMessage(websocket.BinaryMessage, data)
+		writeMutex.Unlock()
+	}
+}
+
+func successResponse() *rustplus.AppResponse {
+	return &rustplus.AppResponse{Success: &rustplus.AppSuccess{}}
+}
+
+func errorResponse(message string) *rustplus.AppResponse {
+	return &rustplus.AppResponse{Error: &rustplus.AppError{Error: message}}
+}
@@ -0,0 +1,266 @@
+// Package rustplustest provides an in-process Rust+ server for testing.
+package rustplustest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/Dids/rustbot/rustplus"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// Server is a fake Rust+ server, which speaks the AppRequest/AppMessage protocol over a websocket and answers from scripted state
+type Server struct {
+	URL  string
+	Host string
+	Port string
+
+	// Private properties
+	server        *httptest.Server
+	upgrader      websocket.Upgrader
+	mutex         sync.Mutex
+	connections   map[*websocket.Conn]*sync.Mutex
+	players       map[uint64]int32
+	info          *rustplus.AppInfo
+	time          *rustplus.AppTime
+	worldMap      *rustplus.AppMap
+	teamInfo      *rustplus.AppTeamInfo
+	teamChat      []*rustplus.AppChatMessage
+	entities      map[uint32]*rustplus.AppEntityInfo
+	subscriptions map[uint32]bool
+	markers       []*rustplus.AppMarker
+	cameras       map[string][]byte
+	errors        map[string][]string
+	rateLimit     int
+	ratePeriod    time.Duration
+	rateWindow    time.Time
+	rateCount     int
+	requests      []*rustplus.AppRequest
+}
+
+// NewServer starts a new fake Rust+ server with a small default world, which accepts any player credentials
+func NewServer() *Server {
+	server := &Server{
+		connections:   make(map[*websocket.Conn]*sync.Mutex),
+		players:       make(map[uint64]int32),
+		entities:      make(map[uint32]*rustplus.AppEntityInfo),
+		subscriptions: make(map[uint32]bool),
+		cameras:       make(map[string][]byte),
+		errors:        make(map[string][]string),
+	}
+
+	// Default world
+	server.info = &rustplus.AppInfo{Name: "Mock Server", Map: "Procedural Map", MapSize: 3000, Players: 1, MaxPlayers: 100, Seed: 1337, Salt: 42}
+	server.time = &rustplus.AppTime{DayLengthMinutes: 60, TimeScale: 1, Sunrise: 7, Sunset: 20, Time: 12}
+	server.worldMap = &rustplus.AppMap{Width: 300, Height: 300, JpgImage: SolidJPEG(300, 300, color.RGBA{R: 60, G: 90, B: 120, A: 255}), OceanMargin: 0}
+	server.teamInfo = &rustplus.AppTeamInfo{}
+
+	server.server = httptest.NewServer(http.HandlerFunc(server.handleConnection))
+	server.URL = "ws" + server.server.URL[len("http"):]
+	server.Host, server.Port, _ = net.SplitHostPort(server.server.Listener.Addr().String())
+
+	return server
+}
+
+// Close disconnects every client and stops the server
+func (server *Server) Close() {
+	server.Disconnect()
+	server.server.Close()
+}
+
+// Disconnect drops the connection of every client (without a close message, like a crashing server)
+func (server *Server) Disconnect() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for connection := range server.connections {
+		connection.Close()
+		delete(server.connections, connection)
+	}
+}
+
+// Connections returns the number of connected clients
+func (server *Server) Connections() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return len(server.connections)
+}
+
+// AddPlayer registers valid player credentials (once any are registered, requests with other credentials are rejected)
+func (server *Server) AddPlayer(steamID uint64, playerToken int32) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.players[steamID] = playerToken
+}
+
+// SetInfo changes the server info
+func (server *Server) SetInfo(info *rustplus.AppInfo) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.info = info
+}
+
+// SetTime changes the in-game time
+func (server *Server) SetTime(time *rustplus.AppTime) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.time = time
+}
+
+// SetMap changes the map
+func (server *Server) SetMap(worldMap *rustplus.AppMap) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.worldMap = worldMap
+}
+
+// SetTeamInfo changes the team, without broadcasting the change
+func (server *Server) SetTeamInfo(teamInfo *rustplus.AppTeamInfo) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.teamInfo = teamInfo
+}
+
+// ChangeTeam changes the team and broadcasts the change to every client
+func (server *Server) ChangeTeam(playerID uint64, teamInfo *rustplus.AppTeamInfo) {
+	server.SetTeamInfo(teamInfo)
+	server.Broadcast(&rustplus.AppBroadcast{TeamChanged: &rustplus.AppTeamChanged{PlayerId: playerID, TeamInfo: teamInfo}})
+}
+
+// AddTeamMessage adds a message to the team chat history, without broadcasting it
+func (server *Server) AddTeamMessage(message *rustplus.AppChatMessage) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.teamChat = append(server.teamChat, message)
+}
+
+// SendTeamMessage adds a message to the team chat history and broadcasts it to every client
+func (server *Server) SendTeamMessage(message *rustplus.AppChatMessage) {
+	server.AddTeamMessage(message)
+	server.Broadcast(&rustplus.AppBroadcast{TeamMessage: &rustplus.AppTeamMessage{Message: message}})
+}
+
+// TeamChat returns the team chat history, including messages sent by clients
+func (server *Server) TeamChat() []*rustplus.AppChatMessage {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return append([]*rustplus.AppChatMessage{}, server.teamChat...)
+}
+
+// SetEntity adds or replaces a paired entity, without broadcasting the change
+func (server *Server) SetEntity(entityID uint32, info *rustplus.AppEntityInfo) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.entities[entityID] = info
+}
+
+// ChangeEntity changes the payload of an entity and broadcasts the change to every client
+func (server *Server) ChangeEntity(entityID uint32, payload *rustplus.AppEntityPayload) {
+	server.mutex.Lock()
+	if info, ok := server.entities[entityID]; ok {
+		info.Payload = payload
+	}
+	server.mutex.Unlock()
+
+	server.Broadcast(&rustplus.AppBroadcast{EntityChanged: &rustplus.AppEntityChanged{EntityId: entityID, Payload: payload}})
+}
+
+// Entity returns the current state of an entity (or nil if it doesn't exist)
+func (server *Server) Entity(entityID uint32) *rustplus.AppEntityInfo {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if info, ok := server.entities[entityID]; ok {
+		return proto.Clone(info).(*rustplus.AppEntityInfo)
+	}
+	return nil
+}
+
+// SetMarkers changes the map markers
+func (server *Server) SetMarkers(markers ...*rustplus.AppMarker) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.markers = markers
+}
+
+// SetCamera adds a CCTV camera, which returns the given JPEG image as every frame
+func (server *Server) SetCamera(identifier string, jpgImage []byte) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.cameras[identifier] = jpgImage
+}
+
+// InjectError makes the next request of the given type (eg. "getInfo", or "" for any type) fail with an AppError
+func (server *Server) InjectError(requestType string, message string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.errors[requestType] = append(server.errors[requestType], message)
+}
+
+// SetRateLimit makes the server respond with rate_limit errors once more than the given number of requests are sent within a period
+// (a limit of zero disables rate limiting)
+func (server *Server) SetRateLimit(requests int, period time.Duration) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.rateLimit = requests
+	server.ratePeriod = period
+	server.rateWindow = time.Time{}
+	server.rateCount = 0
+}
+
+// Requests returns every request received so far
+func (server *Server) Requests() []*rustplus.AppRequest {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return append([]*rustplus.AppRequest{}, server.requests...)
+}
+
+// RequestCount returns the number of received requests of the given type (eg. "getTeamChat")
+func (server *Server) RequestCount(requestType string) int {
+	count := 0
+	for _, request := range server.Requests() {
+		if rustplus.RequestType(request) == requestType {
+			count++
+		}
+	}
+	return count
+}
+
+// Broadcast sends a broadcast to every client
+func (server *Server) Broadcast(broadcast *rustplus.AppBroadcast) {
+	server.send(nil, &rustplus.AppMessage{Broadcast: broadcast})
+}
+
+// SolidJPEG returns a JPEG image of the given size, filled with a single color
+func SolidJPEG(width int, height int, fill color.RGBA) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: fill}, image.Point{}, draw.Src)
+
+	var buffer bytes.Buffer
+	jpeg.Encode(&buffer, img, nil)
+	return buffer.Bytes()
+}
@@ -0,0 +1,283 @@
+package rustplus
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRateLimit is how many tokens the bucket holds, unless configured otherwise (the per-player limit of the server)
+	defaultRateLimit = 25
+
+	// defaultRateRefill is how many tokens are added to the bucket every second, unless configured otherwise
+	defaultRateRefill = 3
+
+	// maxRateLimitRetries is how many times a request is retried after being rate limited by the server
+	maxRateLimitRetries = 3
+)
+
+// Priority decides the order in which queued requests are sent
+type Priority int
+
+// Request priorities, from the first to be sent to the last
+const (
+	// PriorityHigh is used for team chat, so that it's relayed without delay
+	PriorityHigh Priority = iota
+	// PriorityNormal is used for everything that's not team chat, such as commands
+	PriorityNormal
+	// PriorityLow is used for background polling
+	PriorityLow
+)
+
+// defaultRequestCosts is how many tokens each request type costs, unless configured otherwise
+var defaultRequestCosts = map[string]float64{
+	"getInfo":           1,
+	"getTime":           1,
+	"getMap":            5,
+	"getTeamInfo":       1,
+	"getTeamChat":       1,
+	"sendTeamMessage":   2,
+	"getEntityInfo":     1,
+	"setEntityValue":    1,
+	"checkSubscription": 1,
+	"setSubscription":   1,
+	"getMapMarkers":     1,
+	"getCameraFrame":    1,
+	"promoteToLeader":   1,
+}
+
+// priorityKey is the context key for overriding the priority of a request
+type priorityKey struct{}
+
+// WithPriority returns a context that sends requests with the given priority, instead of the default one for the request type
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// requestPriority returns the priority of a request, preferring the one set on the context
+func requestPriority(ctx context.Context, requestType string) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok && priority >= PriorityHigh && priority <= PriorityLow {
+		return priority
+	}
+	if requestType == "getTeamChat" || requestType == "sendTeamMessage" {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// RequestType returns the name of the request type, as used in the protocol (eg. "getInfo")
+func RequestType(request *AppRequest) string {
+	switch {
+	case request.GetInfo != nil:
+		return "getInfo"
+	case request.GetTime != nil:
+		return "getTime"
+	case request.GetMap != nil:
+		return "getMap"
+	case request.GetTeamInfo != nil:
+		return "getTeamInfo"
+	case request.GetTeamChat != nil:
+		return "getTeamChat"
+	case request.SendTeamMessage != nil:
+		return "sendTeamMessage"
+	case request.GetEntityInfo != nil:
+		return "getEntityInfo"
+	case request.SetEntityValue != nil:
+		return "setEntityValue"
+	case request.CheckSubscription != nil:
+		return "checkSubscription"
+	case request.SetSubscription != nil:
+		return "setSubscription"
+	case request.GetMapMarkers != nil:
+		return "getMapMarkers"
+	case request.GetCameraFrame != nil:
+		return "getCameraFrame"
+	case request.PromoteToLeader != nil:
+		return "promoteToLeader"
+	}
+	return ""
+}
+
+// scheduler is a token bucket that queues requests by priority until there are enough tokens to send them
+type scheduler struct {
+	capacity float64
+	refill   float64
+	costs    map[string]float64
+	tokens   float64
+	updated  time.Time
+	queues   [PriorityLow + 1][]*schedulerTicket
+	changed  chan struct{}
+	mutex    sync.Mutex
+}
+
+// schedulerTicket is a queued request
+type schedulerTicket struct {
+	cost float64
+}
+
+// newScheduler creates a full token bucket
+func newScheduler(capacity float64, refill float64, costs map[string]float64) *scheduler {
+	return &scheduler{
+		capacity: capacity,
+		refill:   refill,
+		costs:    costs,
+		tokens:   capacity,
+		updated:  time.Now(),
+		changed:  make(chan struct{}),
+	}
+}
+
+// newSchedulerFromEnv creates a token bucket configured by RUSTPLUS_RATE_LIMIT, RUSTPLUS_RATE_REFILL and RUSTPLUS_REQUEST_COSTS
+func newSchedulerFromEnv() (*scheduler, error) {
+	capacity := float64(defaultRateLimit)
+	if value := strings.TrimSpace(os.Getenv("RUSTPLUS_RATE_LIMIT")); len(value) > 0 {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 {
+			return nil, errors.New("invalid RUSTPLUS_RATE_LIMIT: must be a positive number")
+		}
+		capacity = parsed
+	}
+	refill := float64(defaultRateRefill)
+	if value := strings.TrimSpace(os.Getenv("RUSTPLUS_RATE_REFILL")); len(value) > 0 {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 {
+			return nil, errors.New("invalid RUSTPLUS_RATE_REFILL: must be a positive number")
+		}
+		refill = parsed
+	}
+	costs, err := parseRequestCosts(os.Getenv("RUSTPLUS_REQUEST_COSTS"))
+	if err != nil {
+		return nil, errors.New("invalid RUSTPLUS_REQUEST_COSTS: " + err.Error())
+	}
+	return newScheduler(capacity, refill, costs), nil
+}
+
+// cost returns how many tokens a request type costs (never more than the bucket holds, so that every request can be sent eventually)
+func (scheduler *scheduler) cost(requestType string) float64 {
+	cost, ok := scheduler.costs[requestType]
+	if !ok {
+		cost = 1
+	}
+	return math.Min(cost, scheduler.capacity)
+}
+
+// acquire waits until the request is first in line and there are enough tokens to send it, and takes the tokens
+func (scheduler *scheduler) acquire(ctx context.Context, requestType string, priority Priority) error {
+	ticket := &schedulerTicket{cost: scheduler.cost(requestType)}
+
+	scheduler.mutex.Lock()
+	scheduler.queues[priority] = append(scheduler.queues[priority], ticket)
+	scheduler.mutex.Unlock()
+
+	for {
+		scheduler.mutex.Lock()
+		scheduler.fill(time.Now())
+		var wait time.Duration
+		if scheduler.next() == ticket {
+			if scheduler.tokens >= ticket.cost {
+				scheduler.tokens -= ticket.cost
+				scheduler.remove(ticket, priority)
+				scheduler.mutex.Unlock()
+				return nil
+			}
+			wait = time.Duration((ticket.cost - scheduler.tokens) / scheduler.refill * float64(time.Second))
+		}
+		changed := scheduler.changed
+		scheduler.mutex.Unlock()
+
+		// Wait until there are enough tokens (if we're first in line) or until the line moves
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-expired:
+		case <-changed:
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			scheduler.mutex.Lock()
+			scheduler.remove(ticket, priority)
+			scheduler.mutex.Unlock()
+			return ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// drain empties the bucket, so that requests wait for it to refill after the server rate limited us
+func (scheduler *scheduler) drain() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	scheduler.fill(time.Now())
+	scheduler.tokens = 0
+}
+
+// fill adds the tokens gained since the last update
+func (scheduler *scheduler) fill(now time.Time) {
+	scheduler.tokens = math.Min(scheduler.capacity, scheduler.tokens+now.Sub(scheduler.updated).Seconds()*scheduler.refill)
+	scheduler.updated = now
+}
+
+// next returns the first ticket of the highest priority queue
+func (scheduler *scheduler) next() *schedulerTicket {
+	for _, queue := range scheduler.queues {
+		if len(queue) > 0 {
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+// remove takes a ticket out of its queue and wakes up every waiting request, as the line has moved
+func (scheduler *scheduler) remove(ticket *schedulerTicket, priority Priority) {
+	queue := scheduler.queues[priority]
+	for i := range queue {
+		if queue[i] == ticket {
+			scheduler.queues[priority] = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	close(scheduler.changed)
+	scheduler.changed = make(chan struct{})
+}
+
+// parseRequestCosts parses a comma separated list of request costs (eg. "getMap=5,sendTeamMessage=2"), on top of the default costs
+func parseRequestCosts(value string) (map[string]float64, error) {
+	costs := make(map[string]float64)
+	for requestType, cost := range defaultRequestCosts {
+		costs[requestType] = cost
+	}
+	if len(strings.TrimSpace(value)) <= 0 {
+		return costs, nil
+	}
+
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("expected type=cost: " + field)
+		}
+		requestType := strings.TrimSpace(parts[0])
+		if _, ok := defaultRequestCosts[requestType]; !ok {
+			return nil, errors.New("unknown request type: " + requestType)
+		}
+		cost, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || cost < 0 {
+			return nil, errors.New("request cost must be a non-negative number: " + field)
+		}
+		costs[requestType] = cost
+	}
+	return costs, nil
+}
@@ -0,0 +1,113 @@
+package rustplus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ShopListing is a single sell order of a vending machine
+type ShopListing struct {
+	MarkerID            uint32
+	Shop                string
+	Location            string
+	ItemID              int32
+	Quantity            int32
+	CurrencyID          int32
+	CostPerItem         int32
+	AmountInStock       int32
+	ItemIsBlueprint     bool
+	CurrencyIsBlueprint bool
+}
+
+// PricePerUnit returns the price of a single item, as sell orders may sell items in stacks
+func (listing *ShopListing) PricePerUnit() float64 {
+	if listing.Quantity <= 0 {
+		return float64(listing.CostPerItem)
+	}
+	return float64(listing.CostPerItem) / float64(listing.Quantity)
+}
+
+// SearchShops returns the in stock vending machine listings for every item matching the query,
+// sorted by currency and price per unit (blueprint is optional and filters items or blueprints)
+func (rustplus *RustPlus) SearchShops(ctx context.Context, query string, blueprint *bool) ([]*ShopListing, error) {
+	itemIDs := FindItems(query)
+	if len(itemIDs) <= 0 {
+		return nil, errors.New("no items matching " + strings.TrimSpace(query) + " (" + UnknownItemHint + ")")
+	}
+
+	listings, err := rustplus.GetShopListings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ShopListing, 0)
+	for _, listing := range listings {
+		if listing.AmountInStock <= 0 || (blueprint != nil && listing.ItemIsBlueprint != *blueprint) {
+			continue
+		}
+		for _, itemID := range itemIDs {
+			if listing.ItemID == itemID {
+				results = append(results, listing)
+				break
+			}
+		}
+	}
+	SortShopListings(results)
+
+	return results, nil
+}
+
+// GetShopListings returns every sell order of every vending machine on the map
+func (rustplus *RustPlus) GetShopListings(ctx context.Context) ([]*ShopListing, error) {
+	world, err := rustplus.GetWorldMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	markers, err := rustplus.GetMapMarkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	listings := make([]*ShopListing, 0)
+	for _, marker := range markers.GetMarkers() {
+		if marker.GetType() != AppMarkerType_VendingMachine {
+			continue
+		}
+		location := world.Location(float64(marker.GetX()), float64(marker.GetY()))
+		for _, order := range marker.GetSellOrders() {
+			listings = append(listings, &ShopListing{
+				MarkerID:            marker.GetId(),
+				Shop:                marker.GetName(),
+				Location:            location,
+				ItemID:              order.GetItemId(),
+				Quantity:            order.GetQuantity(),
+				CurrencyID:          order.GetCurrencyId(),
+				CostPerItem:         order.GetCostPerItem(),
+				AmountInStock:       order.GetAmountInStock(),
+				ItemIsBlueprint:     order.GetItemIsBlueprint(),
+				CurrencyIsBlueprint: order.GetCurrencyIsBlueprint(),
+			})
+		}
+	}
+
+	return listings, nil
+}
+
+// SortShopListings sorts listings by item, currency and price per unit
+func SortShopListings(listings []*ShopListing) {
+	sort.SliceStable(listings, func(i, j int) bool {
+		a, b := listings[i], listings[j]
+		if a.ItemID != b.ItemID {
+			return ItemName(a.ItemID) < ItemName(b.ItemID)
+		}
+		if a.ItemIsBlueprint != b.ItemIsBlueprint {
+			return !a.ItemIsBlueprint
+		}
+		if a.CurrencyID != b.CurrencyID {
+			return ItemName(a.CurrencyID) < ItemName(b.CurrencyID)
+		}
+		return a.PricePerUnit() < b.PricePerUnit()
+	})
+}
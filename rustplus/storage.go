@@ -0,0 +1,322 @@
+package rustplus
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+const (
+	// maxStorageSnapshots is the number of snapshots to keep for each storage monitor
+	maxStorageSnapshots = 500
+
+	// upkeepCheckPeriod is how often to check the remaining upkeep of tool cupboards
+	upkeepCheckPeriod = time.Minute
+
+	// storageCheckKey is the state key prefix for the time a storage monitor was last checked
+	storageCheckKey = "StorageCheck:"
+)
+
+// defaultUpkeepThresholds are the remaining upkeep times at which to send a warning
+var defaultUpkeepThresholds = []time.Duration{24 * time.Hour, 6 * time.Hour, time.Hour}
+
+// StorageItem is a single stack of items (or blueprints) in a storage container
+type StorageItem struct {
+	ItemID    int32
+	Quantity  int32
+	Blueprint bool
+}
+
+// StorageSnapshot is the contents of a storage monitor at a specific point in time
+type StorageSnapshot struct {
+	ObjectID int
+	Name     string
+	Time     time.Time
+	Items    []StorageItem
+	Capacity int32
+}
+
+// StorageChange is the change in the quantity of a single item between two snapshots
+type StorageChange struct {
+	ItemID    int32
+	Blueprint bool
+	Quantity  int
+}
+
+// Totals returns the total quantity of each item (and blueprint) in the snapshot
+func (snapshot *StorageSnapshot) Totals() []StorageItem {
+	type itemKey struct {
+		itemID    int32
+		blueprint bool
+	}
+	totals := make(map[itemKey]int32)
+	for _, item := range snapshot.Items {
+		totals[itemKey{item.ItemID, item.Blueprint}] += item.Quantity
+	}
+
+	items := make([]StorageItem, 0, len(totals))
+	for key, quantity := range totals {
+		items = append(items, StorageItem{ItemID: key.itemID, Quantity: quantity, Blueprint: key.blueprint})
+	}
+	sortStorageItems(items)
+	return items
+}
+
+// DiffStorageSnapshots returns the changes in item quantities from one snapshot to another (previous may be nil)
+func DiffStorageSnapshots(previous *StorageSnapshot, current *StorageSnapshot) []StorageChange {
+	type itemKey struct {
+		itemID    int32
+		blueprint bool
+	}
+	quantities := make(map[itemKey]int)
+	if previous != nil {
+		for _, item := range previous.Items {
+			quantities[itemKey{item.ItemID, item.Blueprint}] -= int(item.Quantity)
+		}
+	}
+	if current != nil {
+		for _, item := range current.Items {
+			quantities[itemKey{item.ItemID, item.Blueprint}] += int(item.Quantity)
+		}
+	}
+
+	changes := make([]StorageChange, 0)
+	for key, quantity := range quantities {
+		if quantity != 0 {
+			changes = append(changes, StorageChange{ItemID: key.itemID, Blueprint: key.blueprint, Quantity: quantity})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if ItemName(changes[i].ItemID) != ItemName(changes[j].ItemID) {
+			return ItemName(changes[i].ItemID) < ItemName(changes[j].ItemID)
+		}
+		return !changes[i].Blueprint && changes[j].Blueprint
+	})
+	return changes
+}
+
+// CheckStorage refreshes a storage monitor and returns its current contents, along with the contents when it was last checked
+func (rustplus *RustPlus) CheckStorage(ctx context.Context, name string) (*StorageSnapshot, *StorageSnapshot, error) {
+	entity, err := rustplus.RefreshEntity(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entity.Type != AppEntityType_StorageMonitor {
+		return nil, nil, errors.New(entity.Name + " is not a storage monitor")
+	}
+
+	snapshots, err := loadStorageSnapshots(rustplus.Database, entity.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(snapshots) <= 0 {
+		return nil, nil, errors.New("no snapshots available for " + entity.Name)
+	}
+	current := snapshots[len(snapshots)-1]
+
+	// Compare against the snapshot that was current when we last checked (or the one before the current snapshot)
+	var previous *StorageSnapshot
+	lastCheck, err := rustplus.Database.GetState(stateCollection, storageCheckKey+strings.ToLower(entity.Name))
+	if err != nil {
+		return nil, nil, err
+	}
+	if lastCheck != nil {
+		for _, snapshot := range snapshots {
+			if snapshot.Time.UnixMilli() <= int64(toFloat(lastCheck)) {
+				previous = snapshot
+			}
+		}
+	} else if len(snapshots) > 1 {
+		previous = snapshots[len(snapshots)-2]
+	}
+
+	if err := rustplus.Database.SetState(stateCollection, storageCheckKey+strings.ToLower(entity.Name), time.Now().UnixMilli()); err != nil {
+		return nil, nil, err
+	}
+
+	return current, previous, nil
+}
+
+// GetStorageSnapshots returns the stored snapshots of a storage monitor, newest first
+func (rustplus *RustPlus) GetStorageSnapshots(name string) ([]*StorageSnapshot, error) {
+	entity, err := rustplus.GetEntity(name)
+	if err != nil {
+		return nil, err
+	}
+	if entity.Type != AppEntityType_StorageMonitor {
+		return nil, errors.New(entity.Name + " is not a storage monitor")
+	}
+
+	snapshots, err := loadStorageSnapshots(rustplus.Database, entity.Name)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(snapshots)-1; i < j; i, j = i+1, j-1 {
+		snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+	}
+	return snapshots, nil
+}
+
+// updateStorage stores a new snapshot if the contents of a storage monitor changed, and checks its upkeep
+func (rustplus *RustPlus) updateStorage(entity *Entity, payload *AppEntityPayload, hadProtection bool) {
+	snapshot := &StorageSnapshot{Name: entity.Name, Time: time.Now(), Capacity: payload.GetCapacity()}
+	for _, item := range payload.GetItems() {
+		snapshot.Items = append(snapshot.Items, StorageItem{ItemID: item.ItemId, Quantity: item.Quantity, Blueprint: item.ItemIsBlueprint})
+	}
+	if err := rustplus.recordStorageSnapshot(snapshot); err != nil {
+		rustplus.logger.Error("Failed to store snapshot of", entity.Name+":", err)
+	}
+
+	// Warn as soon as a tool cupboard stops protecting the base
+	if hadProtection && !entity.HasProtection {
+		rustplus.upkeepMutex.Lock()
+		delete(rustplus.upkeepWarnings, strings.ToLower(entity.Name))
+		rustplus.upkeepMutex.Unlock()
+
+		rustplus.logger.Info("Tool cupboard lost protection:", entity.Name)
+		rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: entity.Name, Message: entity.Name + " is out of upkeep, the base is decaying!", Type: eventhandler.UpkeepType})
+		return
+	}
+
+	rustplus.checkUpkeep(entity)
+}
+
+// recordStorageSnapshot stores a snapshot unless the contents are unchanged from the latest one, pruning the oldest snapshots
+func (rustplus *RustPlus) recordStorageSnapshot(snapshot *StorageSnapshot) error {
+	snapshots, err := loadStorageSnapshots(rustplus.Database, snapshot.Name)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) > 0 {
+		latest := snapshots[len(snapshots)-1]
+		if latest.Capacity == snapshot.Capacity && len(DiffStorageSnapshots(latest, snapshot)) <= 0 {
+			return nil
+		}
+	}
+
+	if err := saveStorageSnapshot(rustplus.Database, snapshot); err != nil {
+		return err
+	}
+	for len(snapshots) >= maxStorageSnapshots {
+		if err := rustplus.Database.Delete("storage_snapshots", snapshots[0].ObjectID); err != nil {
+			return err
+		}
+		snapshots = snapshots[1:]
+	}
+
+	return nil
+}
+
+// checkUpkeep sends a warning when the remaining upkeep of a tool cupboard falls below the next threshold
+func (rustplus *RustPlus) checkUpkeep(entity *Entity) {
+	if !entity.HasProtection || entity.ProtectionExpiry <= 0 {
+		return
+	}
+	remaining := time.Until(time.Unix(int64(entity.ProtectionExpiry), 0))
+
+	// Find the lowest threshold we've fallen below
+	threshold := time.Duration(0)
+	for _, t := range rustplus.upkeepThresholds {
+		if remaining <= t && (threshold <= 0 || t < threshold) {
+			threshold = t
+		}
+	}
+
+	rustplus.upkeepMutex.Lock()
+	defer rustplus.upkeepMutex.Unlock()
+
+	key := strings.ToLower(entity.Name)
+	if threshold <= 0 {
+		// Upkeep has been topped up, so start warning again from the highest threshold
+		delete(rustplus.upkeepWarnings, key)
+		return
+	}
+	if warned, ok := rustplus.upkeepWarnings[key]; ok && warned <= threshold {
+		return
+	}
+	rustplus.upkeepWarnings[key] = threshold
+
+	rustplus.logger.Info("Tool cupboard upkeep running low:", entity.Name, remaining)
+	rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: entity.Name, Message: entity.Name + " has less than " + FormatDuration(threshold) + " of upkeep left (" + FormatDuration(remaining) + ")", Type: eventhandler.UpkeepType})
+}
+
+func (rustplus *RustPlus) startUpkeepChecks() {
+	rustplus.logger.Trace("Creating Rust+ upkeep ticker..")
+	ticker := time.NewTicker(upkeepCheckPeriod)
+	defer func() {
+		rustplus.logger.Trace("Stopping Rust+ upkeep ticker..")
+		ticker.Stop()
+	}()
+
+	for {
+		select {
+		case <-rustplus.stop:
+			return
+		case <-ticker.C:
+		}
+
+		entities, err := rustplus.GetEntities()
+		if err != nil {
+			rustplus.logger.Error("Failed to load paired entities:", err)
+			continue
+		}
+		for _, entity := range entities {
+			if entity.Type == AppEntityType_StorageMonitor {
+				rustplus.checkUpkeep(entity)
+			}
+		}
+	}
+}
+
+// parseUpkeepThresholds parses a comma separated list of durations (eg. "24h,6h,1h")
+func parseUpkeepThresholds(value string) ([]time.Duration, error) {
+	if len(strings.TrimSpace(value)) <= 0 {
+		return defaultUpkeepThresholds, nil
+	}
+
+	thresholds := make([]time.Duration, 0)
+	for _, field := range strings.Split(value, ",") {
+		threshold, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if threshold <= 0 {
+			return nil, errors.New("upkeep threshold must be positive: " + field)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
+
+// FormatDuration formats a duration as days, hours and minutes (eg. "1d 4h 30m")
+func FormatDuration(duration time.Duration) string {
+	if duration < time.Minute {
+		return "<1m"
+	}
+	minutes := int(duration / time.Minute)
+	parts := make([]string, 0)
+	if days := minutes / (24 * 60); days > 0 {
+		parts = append(parts, strconv.Itoa(days)+"d")
+	}
+	if hours := minutes / 60 % 24; hours > 0 {
+		parts = append(parts, strconv.Itoa(hours)+"h")
+	}
+	if minutes%60 > 0 {
+		parts = append(parts, strconv.Itoa(minutes%60)+"m")
+	}
+	return strings.Join(parts, " ")
+}
+
+func sortStorageItems(items []StorageItem) {
+	sort.Slice(items, func(i, j int) bool {
+		if ItemName(items[i].ItemID) != ItemName(items[j].ItemID) {
+			return ItemName(items[i].ItemID) < ItemName(items[j].ItemID)
+		}
+		return !items[i].Blueprint && items[j].Blueprint
+	})
+}
@@ -0,0 +1,183 @@
+package rustplus
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/Dids/rustbot/rustmap"
+)
+
+// maxTeamEvents is how many team events are kept in the activity history
+const maxTeamEvents = 1000
+
+// TeamEvent is a change in the team, such as a member going offline or dying
+type TeamEvent struct {
+	ObjectID int
+	SteamID  uint64
+	Name     string
+	Type     eventhandler.MessageType
+	Message  string
+	Time     time.Time
+}
+
+// teamTracker detects team events by comparing consecutive team infos
+type teamTracker struct {
+	members map[uint64]*AppTeamInfo_Member
+	leader  uint64
+	seeded  bool
+}
+
+// GetTeamActivity returns the most recent team events (optionally only those of a single player), newest first
+func (rustplus *RustPlus) GetTeamActivity(steamID uint64, limit int) ([]*TeamEvent, error) {
+	events, err := loadTeamEvents(rustplus.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*TeamEvent, 0)
+	for i := len(events) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		if steamID <= 0 || events[i].SteamID == steamID {
+			result = append(result, events[i])
+		}
+	}
+	return result, nil
+}
+
+// refreshTeam fetches the current team, which is used as the baseline for detecting team events
+func (rustplus *RustPlus) refreshTeam() {
+	teamInfo, err := rustplus.GetTeamInfo(context.Background())
+	if err != nil {
+		rustplus.logger.Warning("Failed to get team info:", err)
+		return
+	}
+	rustplus.handleTeamChanged(teamInfo)
+}
+
+// resetTeam forgets the tracked team, so that changes made while we were disconnected aren't announced
+func (rustplus *RustPlus) resetTeam() {
+	rustplus.teamMutex.Lock()
+	defer rustplus.teamMutex.Unlock()
+
+	rustplus.teamTracker = &teamTracker{}
+}
+
+// handleTeamChanged records and emits the team events caused by a team change
+func (rustplus *RustPlus) handleTeamChanged(teamInfo *AppTeamInfo) {
+	// Locations are optional, so we don't wait for the map if it isn't cached yet (as that would hold up every other broadcast)
+	world := rustplus.cachedWorldMap()
+
+	rustplus.teamMutex.Lock()
+	events := rustplus.teamTracker.update(teamInfo, world, time.Now())
+	rustplus.teamMutex.Unlock()
+
+	for _, event := range events {
+		rustplus.logger.Info("Team event:", event.Message)
+		if err := rustplus.recordTeamEvent(event); err != nil {
+			rustplus.logger.Error("Failed to store team event:", err)
+		}
+		rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: event.Name, Message: event.Message, Type: event.Type})
+	}
+}
+
+// recordTeamEvent stores a team event, pruning the oldest events
+func (rustplus *RustPlus) recordTeamEvent(event *TeamEvent) error {
+	if err := saveTeamEvent(rustplus.Database, event); err != nil {
+		return err
+	}
+
+	events, err := loadTeamEvents(rustplus.Database)
+	if err != nil {
+		return err
+	}
+	for len(events) > maxTeamEvents {
+		if err := rustplus.Database.Delete("team_events", events[0].ObjectID); err != nil {
+			return err
+		}
+		events = events[1:]
+	}
+
+	return nil
+}
+
+// update compares the team with the previous one and returns the resulting events
+// (the first update only records the team, as we don't know what changed before it)
+func (tracker *teamTracker) update(teamInfo *AppTeamInfo, world *rustmap.Map, now time.Time) []*TeamEvent {
+	events := make([]*TeamEvent, 0)
+	event := func(member *AppTeamInfo_Member, eventType eventhandler.MessageType, message string) {
+		events = append(events, &TeamEvent{SteamID: member.GetSteamId(), Name: member.GetName(), Type: eventType, Message: member.GetName() + " " + message, Time: now})
+	}
+
+	current := make(map[uint64]*AppTeamInfo_Member)
+	for _, member := range teamInfo.GetMembers() {
+		current[member.GetSteamId()] = member
+	}
+
+	if !tracker.seeded {
+		tracker.seeded = true
+		tracker.members = current
+		tracker.leader = teamInfo.GetLeaderSteamId()
+		return events
+	}
+
+	// Compare every member in a stable order, so the events are too
+	steamIDs := make([]uint64, 0, len(current))
+	for steamID := range current {
+		steamIDs = append(steamIDs, steamID)
+	}
+	sort.Slice(steamIDs, func(i, j int) bool {
+		return steamIDs[i] < steamIDs[j]
+	})
+
+	for _, steamID := range steamIDs {
+		member := current[steamID]
+		previous, ok := tracker.members[steamID]
+		if !ok {
+			event(member, eventhandler.TeamJoinType, "joined the team")
+			continue
+		}
+
+		// Online status
+		if member.GetIsOnline() && !previous.GetIsOnline() {
+			event(member, eventhandler.TeamOnlineType, "came online")
+		} else if !member.GetIsOnline() && previous.GetIsOnline() {
+			event(member, eventhandler.TeamOfflineType, "went offline")
+		}
+
+		// Deaths and respawns (a member may have died and respawned in between updates, in which case we don't know where they died)
+		died := member.GetDeathTime() > previous.GetDeathTime() || (!member.GetIsAlive() && previous.GetIsAlive())
+		if died && !member.GetIsAlive() && world != nil {
+			event(member, eventhandler.TeamDeathType, "died at "+world.Grid(float64(member.GetX()), float64(member.GetY())))
+		} else if died {
+			event(member, eventhandler.TeamDeathType, "died")
+		}
+		if member.GetIsAlive() && (died || !previous.GetIsAlive()) {
+			event(member, eventhandler.TeamRespawnType, "respawned")
+		}
+	}
+
+	// Members that left
+	leftIDs := make([]uint64, 0)
+	for steamID := range tracker.members {
+		if _, ok := current[steamID]; !ok {
+			leftIDs = append(leftIDs, steamID)
+		}
+	}
+	sort.Slice(leftIDs, func(i, j int) bool {
+		return leftIDs[i] < leftIDs[j]
+	})
+	for _, steamID := range leftIDs {
+		event(tracker.members[steamID], eventhandler.TeamLeaveType, "left the team")
+	}
+
+	// Leader changes
+	if member, ok := current[teamInfo.GetLeaderSteamId()]; ok && member.GetSteamId() != tracker.leader {
+		event(member, eventhandler.TeamLeaderType, "is now the team leader")
+	}
+
+	tracker.members = current
+	tracker.leader = teamInfo.GetLeaderSteamId()
+
+	return events
+}
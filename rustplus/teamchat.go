@@ -0,0 +1,143 @@
+package rustplus
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Dids/rustbot/chat"
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+// Prefix for team messages that were relayed from Discord (used for filtering out our own echoes)
+const discordMessagePrefix = "[DISCORD] "
+
+// Longest team message that the game shows in full (in characters, just like the other chat channels)
+const maxTeamMessageLength = chat.MaxMessageLength
+
+// Keys of the persisted high-water mark for relayed team messages: the time of the newest message,
+// along with the messages sent within that same second (as the time only has a precision of one second)
+const (
+	teamChatTimeKey     = "TeamChatTime"
+	teamChatMessagesKey = "TeamChatMessages"
+)
+
+// backfillTeamChat relays any team messages that were sent while we were disconnected
+func (rustplus *RustPlus) backfillTeamChat() {
+	teamChat, err := rustplus.GetTeamChat(context.Background())
+	if err != nil {
+		rustplus.logger.Warning("Failed to backfill team chat:", err)
+		return
+	}
+
+	rustplus.relayMissedTeamMessages(teamChat.GetMessages())
+}
+
+// relayMissedTeamMessages relays the team messages that weren't relayed yet, in chronological order
+// (the event handler delivers them to Discord in the same order)
+func (rustplus *RustPlus) relayMissedTeamMessages(messages []*AppChatMessage) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Time < messages[j].Time
+	})
+
+	for _, message := range messages {
+		rustplus.relayTeamMessage(message, true)
+	}
+}
+
+// relayTeamMessage sends a team message to Discord, optionally skipping it if it was already relayed (or is older than the last relayed message)
+func (rustplus *RustPlus) relayTeamMessage(message *AppChatMessage, onlyIfNew bool) {
+	// Update the high-water mark, so backfilling won't relay the same message again
+	// (while still relaying the messages that were sent within the same second as the last relayed one)
+	key := teamMessageKey(message)
+	rustplus.teamChatMutex.Lock()
+	lastTime, lastMessages := rustplus.getTeamChatMark()
+	relayed := message.Time < lastTime || (message.Time == lastTime && lastMessages[key])
+	if onlyIfNew && relayed {
+		rustplus.teamChatMutex.Unlock()
+		return
+	}
+	if message.Time > lastTime {
+		if err := rustplus.Database.SetState(stateCollection, teamChatTimeKey, message.Time); err != nil {
+			rustplus.logger.Error("Failed to store team chat time:", err)
+		}
+		lastMessages = make(map[string]bool)
+	}
+	if message.Time >= lastTime && !lastMessages[key] {
+		keys := []string{key}
+		for lastKey := range lastMessages {
+			keys = append(keys, lastKey)
+		}
+		if err := rustplus.Database.SetState(stateCollection, teamChatMessagesKey, keys); err != nil {
+			rustplus.logger.Error("Failed to store team chat messages:", err)
+		}
+	}
+	rustplus.teamChatMutex.Unlock()
+
+	// Ignore messages that we relayed from Discord ourselves, no matter which credentials they were sent with
+	if strings.HasPrefix(message.Message, discordMessagePrefix) && rustplus.isBotPlayer(message.SteamId) {
+		return
+	}
+
+	// Skip empty messages
+	if len(message.Message) <= 0 {
+		return
+	}
+
+	// Send team chat message to Discord
+	rustplus.EventHandler.Emit(eventhandler.Message{Event: "receive_rustplus_message", User: message.Name, Message: message.Message, Type: eventhandler.TeamChatType})
+}
+
+// getTeamChatMark returns the time of the newest relayed team message, along with the messages relayed within that second (by key)
+func (rustplus *RustPlus) getTeamChatMark() (uint32, map[string]bool) {
+	messages := make(map[string]bool)
+	value, err := rustplus.Database.GetState(stateCollection, teamChatTimeKey)
+	if err != nil {
+		rustplus.logger.Error("Failed to load team chat time:", err)
+		return 0, messages
+	}
+
+	keys, err := rustplus.Database.GetState(stateCollection, teamChatMessagesKey)
+	if err != nil {
+		rustplus.logger.Error("Failed to load team chat messages:", err)
+	}
+	if keys, ok := keys.([]interface{}); ok {
+		for _, key := range keys {
+			if key, ok := key.(string); ok {
+				messages[key] = true
+			}
+		}
+	}
+
+	return uint32(toFloat(value)), messages
+}
+
+// teamMessageKey identifies a team message within a second (by who sent it and what they said)
+func teamMessageKey(message *AppChatMessage) string {
+	return strconv.FormatUint(message.SteamId, 10) + ":" + message.Message
+}
+
+func (rustplus *RustPlus) handleIncomingDiscordMessage(message eventhandler.Message) {
+	if rustplus.isShuttingDown() {
+		rustplus.logger.Warning("Already shutting down!")
+		return
+	}
+
+	rustplus.logger.Trace("handleIncomingDiscordMessage:", message)
+
+	// Strip any rich text and control characters, skipping messages with nothing left to say
+	text := chat.Sanitize(message.Message)
+	if len(text) <= 0 {
+		rustplus.logger.Trace("Ignoring empty message:", message)
+		return
+	}
+
+	// Relay message to the team chat, splitting long messages into multiple lines
+	for _, line := range chat.Split(discordMessagePrefix+chat.Sanitize(message.User)+": ", text, maxTeamMessageLength) {
+		if err := rustplus.SendTeamMessage(context.Background(), line); err != nil {
+			rustplus.logger.Error("Failed to send team message:", err)
+			return
+		}
+	}
+}
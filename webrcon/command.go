@@ -0,0 +1,132 @@
+package webrcon
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Time allowed for the server to reply to a command, unless the context already has a deadline
+const commandTimeout = 10 * time.Second
+
+// ErrNotConnected is returned when executing a command without an active connection
+var ErrNotConnected = errors.New("not connected to the server")
+
+// errShuttingDown is returned when executing a command after (or while) the client is closed
+var errShuttingDown = errors.New("shutdown in progress")
+
+// ErrConnectionLost is returned when the connection is lost before a reply is received
+var ErrConnectionLost = errors.New("connection lost before receiving a reply")
+
+// Execute runs a console command on the server and waits for its output
+func (webrcon *Webrcon) Execute(ctx context.Context, command string) (string, error) {
+	if webrcon.isShuttingDown() {
+		return "", errShuttingDown
+	}
+	if !webrcon.isConnected() {
+		return "", ErrNotConnected
+	}
+
+	// Apply the default timeout if the caller didn't set one
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+	}
+
+	// Assign the next free identifier and start waiting for the reply
+	replyChannel := make(chan Packet, 1)
+	webrcon.pendingMutex.Lock()
+	identifier := webrcon.nextIdentifier()
+	webrcon.pendingCommands[identifier] = replyChannel
+	webrcon.pendingMutex.Unlock()
+	defer func() {
+		webrcon.pendingMutex.Lock()
+		delete(webrcon.pendingCommands, identifier)
+		webrcon.pendingMutex.Unlock()
+	}()
+
+	// Convert the command to a packet and send it
+	if err := webrcon.Client.Send(Packet{Message: command, Identifier: identifier, Name: "WebRcon"}); err != nil {
+		return "", err
+	}
+
+	// Wait for the reply (closing the client cancels pending commands, but this one may have been sent right after that)
+	select {
+	case reply, ok := <-replyChannel:
+		if !ok {
+			return "", ErrConnectionLost
+		}
+		return reply.Message, nil
+	case <-webrcon.stop:
+		return "", errShuttingDown
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Send runs a console command on the server without waiting for its output (which is ignored once it arrives)
+func (webrcon *Webrcon) Send(ctx context.Context, command string) error {
+	if webrcon.isShuttingDown() {
+		return errShuttingDown
+	}
+	if !webrcon.isConnected() {
+		return ErrNotConnected
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The command still gets its own identifier, so that its output isn't mistaken for console output
+	webrcon.pendingMutex.Lock()
+	identifier := webrcon.nextIdentifier()
+	webrcon.pendingMutex.Unlock()
+
+	return webrcon.Client.Send(Packet{Message: command, Identifier: identifier, Name: "WebRcon"})
+}
+
+// nextIdentifier returns a positive identifier that isn't used by any pending command (the pending mutex must be held)
+func (webrcon *Webrcon) nextIdentifier() PacketIdentifier {
+	for {
+		if webrcon.identifier <= 0 || webrcon.identifier >= math.MaxInt32 {
+			webrcon.identifier = 0
+		}
+		webrcon.identifier++
+		if _, ok := webrcon.pendingCommands[webrcon.identifier]; !ok {
+			return webrcon.identifier
+		}
+	}
+}
+
+// handleReply passes a reply to the command waiting for it, and reports whether the packet was a reply
+func (webrcon *Webrcon) handleReply(packet Packet) bool {
+	if packet.Identifier <= 0 {
+		return false
+	}
+
+	webrcon.pendingMutex.Lock()
+	replyChannel, ok := webrcon.pendingCommands[packet.Identifier]
+	if ok {
+		delete(webrcon.pendingCommands, packet.Identifier)
+	}
+	webrcon.pendingMutex.Unlock()
+
+	if !ok {
+		webrcon.logger.Trace("Ignoring reply to an unknown (or timed out) command:", packet.Identifier)
+		return true
+	}
+	replyChannel <- packet
+	return true
+}
+
+// cancelPendingCommands fails every command that's still waiting for a reply
+func (webrcon *Webrcon) cancelPendingCommands() {
+	webrcon.pendingMutex.Lock()
+	defer webrcon.pendingMutex.Unlock()
+
+	for identifier, replyChannel := range webrcon.pendingCommands {
+		close(replyChannel)
+		delete(webrcon.pendingCommands, identifier)
+	}
+}
@@ -0,0 +1,74 @@
+package webrcon
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Server IDs are used in environment variable names, so they're limited to letters, numbers and underscores
+var serverIDRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ServerIDs returns the IDs of the servers listed in WEBRCON_SERVERS (eg. "main,monthly"),
+// or a single server with an empty ID when only the shared WEBRCON_* variables are used
+func ServerIDs() ([]string, error) {
+	value := strings.TrimSpace(os.Getenv("WEBRCON_SERVERS"))
+	if len(value) <= 0 {
+		return []string{""}, nil
+	}
+
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(value, ",") {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if len(id) <= 0 {
+			continue
+		}
+		if !serverIDRegex.MatchString(id) {
+			return nil, errors.New("invalid WEBRCON_SERVERS: server ID " + id + " may only contain letters, numbers and underscores")
+		}
+		if seen[id] {
+			return nil, errors.New("invalid WEBRCON_SERVERS: server ID " + id + " is listed more than once")
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) <= 0 {
+		return nil, errors.New("invalid WEBRCON_SERVERS: no server IDs in " + value)
+	}
+	return ids, nil
+}
+
+// ServerEnvName returns the name of the server specific variant of an environment variable,
+// inserting the server ID after the first word (eg. WEBRCON_MAIN_HOST or DISCORD_MAIN_CHAT_CHANNEL_ID)
+func ServerEnvName(serverID string, name string) string {
+	if len(serverID) <= 0 {
+		return name
+	}
+	parts := strings.SplitN(name, "_", 2)
+	if len(parts) < 2 {
+		return name + "_" + serverID
+	}
+	return parts[0] + "_" + serverID + "_" + parts[1]
+}
+
+// ServerEnv returns the server specific value of an environment variable, falling back to the shared one when it's not set
+// (so that eg. every server can use the same WEBRCON_PASSWORD or DISCORD_NOTIFICATIONS_CHANNEL_ID)
+func ServerEnv(serverID string, name string) string {
+	if value, ok := os.LookupEnv(ServerEnvName(serverID, name)); ok && len(serverID) > 0 {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// ServerName returns the display name of a server (WEBRCON_<ID>_NAME), defaulting to its ID
+func ServerName(serverID string) string {
+	if len(serverID) <= 0 {
+		return os.Getenv("WEBRCON_NAME")
+	}
+	if name := os.Getenv(ServerEnvName(serverID, "WEBRCON_NAME")); len(name) > 0 {
+		return name
+	}
+	return serverID
+}
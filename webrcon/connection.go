@@ -1,28 +1,58 @@
 package webrcon
 
 import (
-	"os"
+	"math/rand"
+	"strconv"
 	"time"
 
 	"github.com/Dids/rustbot/eventhandler"
-	"github.com/sacOO7/gowebsocket"
 )
 
-func (webrcon *Webrcon) handleConnect(socket gowebsocket.Socket) {
-	if webrcon.isShuttingDown {
+const (
+	// Time to wait before the first attempt to reconnect to the server
+	minReconnectDelay = 2 * time.Second
+
+	// Longest time to wait between attempts to reconnect to the server
+	maxReconnectDelay = 2 * time.Minute
+)
+
+func (webrcon *Webrcon) handleConnect() {
+	// We may have been closed while (re)connecting, in which case the new connection isn't needed anymore
+	if webrcon.isShuttingDown() {
 		webrcon.logger.Warning("Already shutting down!")
+		webrcon.Client.Close()
 		return
 	}
 
 	webrcon.logger.Info("Connected to server")
 
-	// Send server connected message to Discord
-	webrcon.EventHandler.Emit(eventhandler.Message{Event: "receive_webrcon_message", User: "", Message: "I'm back, baby!", Type: eventhandler.ServerConnectedType})
+	webrcon.reconnectMutex.Lock()
+	offlineSince := webrcon.offlineSince
+	webrcon.offlineSince = time.Time{}
+	webrcon.reconnectAttempt = 0
+	webrcon.reconnectMutex.Unlock()
+
+	// Snapshot the high-water marks before any new lines arrive, so that we know what was missed in the meantime
+	chatMark, consoleMark := webrcon.history.marks(time.Now().Unix())
+
+	// Send server connected message to Discord (only announcing how long the server was gone if we announced it going offline)
+	message := "I'm back, baby!"
+	if !offlineSince.IsZero() {
+		message = "Server is back after " + formatDowntime(time.Since(offlineSince))
+	}
+	webrcon.emit(eventhandler.Message{User: "", Message: message, Type: eventhandler.ServerConnectedType})
+
+	// Recover any chat and console lines sent while we were disconnected
+	if !offlineSince.IsZero() {
+		go webrcon.recoverMissedLines(chatMark, consoleMark)
+	}
 }
 
-func (webrcon *Webrcon) handleDisconnect(err error, socket gowebsocket.Socket) {
-	if webrcon.isShuttingDown {
-		webrcon.logger.Warning("Already shutting down!")
+func (webrcon *Webrcon) handleDisconnect(err error) {
+	// Any commands still waiting for a reply will never get one
+	webrcon.cancelPendingCommands()
+
+	if webrcon.isShuttingDown() {
 		return
 	}
 
@@ -30,39 +60,97 @@ func (webrcon *Webrcon) handleDisconnect(err error, socket gowebsocket.Socket) {
 
 	// When a disconnect error occurs, this means that we didn't gracefully shutdown, but the connection was lost etc.
 	if err != nil {
-		// Send server disconnected message to Discord
-		webrcon.EventHandler.Emit(eventhandler.Message{Event: "receive_webrcon_message", User: "", Message: "Disconnected from server!", Type: eventhandler.ServerDisconnectedType})
+		webrcon.markOffline()
+		webrcon.scheduleReconnect()
+	}
+}
+
+func (webrcon *Webrcon) handleConnectError(err error) {
+	if webrcon.isShuttingDown() {
+		webrcon.logger.Warning("Already shutting down!")
+		return
+	}
 
-		// Sleep for a bit before shutting down
-		time.Sleep(1 * time.Second)
+	webrcon.logger.Warning("Could not connect to server", err)
 
-		// Notify the primary process to shut down
-		webrcon.logger.Error("Disconnected from server, shutting down..")
-		process, _ := os.FindProcess(os.Getpid())
-		process.Signal(os.Interrupt)
+	webrcon.markOffline()
+	webrcon.scheduleReconnect()
+}
+
+// isConnected reports whether there's an active connection to the server
+func (webrcon *Webrcon) isConnected() bool {
+	return webrcon.Client.IsConnected()
+}
+
+// markOffline sends a single server offline message to Discord, no matter how many attempts to reconnect fail
+func (webrcon *Webrcon) markOffline() {
+	webrcon.reconnectMutex.Lock()
+	defer webrcon.reconnectMutex.Unlock()
+	if !webrcon.offlineSince.IsZero() {
 		return
 	}
+	webrcon.offlineSince = time.Now()
+
+	webrcon.emit(eventhandler.Message{User: "", Message: "Server is offline, reconnecting..", Type: eventhandler.ServerDisconnectedType})
 }
 
-func (webrcon *Webrcon) handleConnectError(err error, socket gowebsocket.Socket) {
-	if webrcon.isShuttingDown {
-		webrcon.logger.Warning("Already shutting down!")
+// scheduleReconnect attempts to connect again after an increasing delay, ignoring any duplicate calls in the meantime
+func (webrcon *Webrcon) scheduleReconnect() {
+	webrcon.reconnectMutex.Lock()
+	defer webrcon.reconnectMutex.Unlock()
+	if webrcon.reconnectTimer != nil {
 		return
 	}
 
-	webrcon.logger.Info("Could not connect to server", err)
+	delay := reconnectDelay(webrcon.reconnectAttempt, rand.Float64())
+	webrcon.reconnectAttempt++
 
-	if err != nil {
-		// Send server disconnected message to Discord
-		webrcon.EventHandler.Emit(eventhandler.Message{Event: "receive_webrcon_message", User: "", Message: "Cannot connect to server!", Type: eventhandler.ServerDisconnectedType})
+	webrcon.logger.Info("Reconnecting to server in", delay.Round(time.Millisecond))
+	webrcon.reconnectTimer = time.AfterFunc(delay, func() {
+		webrcon.reconnectMutex.Lock()
+		webrcon.reconnectTimer = nil
+		webrcon.reconnectMutex.Unlock()
+
+		if webrcon.isShuttingDown() {
+			return
+		}
+		webrcon.Client.Connect()
+	})
+}
 
-		// Sleep for a bit before shutting down
-		time.Sleep(1 * time.Second)
+// stopReconnecting cancels any scheduled attempt to reconnect
+func (webrcon *Webrcon) stopReconnecting() {
+	webrcon.reconnectMutex.Lock()
+	defer webrcon.reconnectMutex.Unlock()
+	if webrcon.reconnectTimer != nil {
+		webrcon.reconnectTimer.Stop()
+		webrcon.reconnectTimer = nil
+	}
+}
 
-		// Notify the primary process to shut down
-		webrcon.logger.Error("Could not connect to server, shutting down..")
-		process, _ := os.FindProcess(os.Getpid())
-		process.Signal(os.Interrupt)
-		return
+// reconnectDelay returns how long to wait before the given attempt to reconnect (starting from zero),
+// doubling the delay after every attempt and randomizing the second half of it, so that we don't hammer a server that's starting up
+func reconnectDelay(attempt int, random float64) time.Duration {
+	delay := maxReconnectDelay
+	if attempt < 16 {
+		delay = minReconnectDelay << uint(attempt)
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+	return delay/2 + time.Duration(random*float64(delay/2))
+}
+
+// formatDowntime formats how long the server was offline, rounded to a sensible precision (eg. "4m" or "1h12m")
+func formatDowntime(duration time.Duration) string {
+	if duration < time.Minute {
+		return duration.Round(time.Second).String()
+	}
+	duration = duration.Round(time.Minute)
+	hours := int(duration / time.Hour)
+	minutes := int((duration % time.Hour) / time.Minute)
+	if hours <= 0 {
+		return strconv.Itoa(minutes) + "m"
 	}
+	return strconv.Itoa(hours) + "h" + strconv.Itoa(minutes) + "m"
 }
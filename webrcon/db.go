@@ -6,15 +6,17 @@ import (
 	"github.com/Dids/rustbot/database"
 )
 
-func incrementKillCount(database *database.Database, killerID string) error {
-	return incrementFieldForSteamID(database, "Kills", killerID)
+func incrementKillCount(database *database.Database, serverID string, killerID string) error {
+	return incrementFieldForSteamID(database, serverID, "Kills", killerID)
 }
 
-func incrementDeathCount(database *database.Database, victimID string) error {
-	return incrementFieldForSteamID(database, "Deaths", victimID)
+func incrementDeathCount(database *database.Database, serverID string, victimID string) error {
+	return incrementFieldForSteamID(database, serverID, "Deaths", victimID)
 }
 
-func incrementFieldForSteamID(database *database.Database, field string, steamID string) error {
+// incrementFieldForSteamID increments a field of a user, keeping separate stats for each server
+// (users stored before there were multiple servers have no "Server" field, so they belong to the server with an empty ID)
+func incrementFieldForSteamID(database *database.Database, serverID string, field string, steamID string) error {
 	if database == nil || database.Client == nil {
 		return errors.New("Database is nil")
 	}
@@ -32,19 +34,21 @@ func incrementFieldForSteamID(database *database.Database, field string, steamID
 		return err
 	}
 
-	// Create the object id (or use the existing one, if available)
+	// Create the object id (or use the existing one of this server, if available)
 	objectID := 0
-	for id := range matches {
-		objectID = id
-		break
+	for id, match := range matches {
+		if server, _ := match["Server"].(string); server == serverID {
+			objectID = id
+			user = match
+			break
+		}
 	}
 
-	// Get the existing user object or create a new one
-	if len(matches) > 0 {
-		user = matches[objectID]
-	} else {
+	// Create a new user object if one doesn't exist
+	if objectID == 0 {
 		user = map[string]interface{}{
 			"SteamID": steamID,
+			"Server":  serverID,
 			field:     0,
 		}
 	}
@@ -55,10 +59,13 @@ func incrementFieldForSteamID(database *database.Database, field string, steamID
 	}
 
 	// Increment the field (with a hack that accounts for JSON unmarshaling converting ints to floats)
-	floatValue, ok := user[field].(float64)
-	if ok {
-		intValue := int(floatValue)
-		user[field] = intValue
+	switch value := user[field].(type) {
+	case float64:
+		user[field] = int(value) + 1
+	case int:
+		user[field] = value + 1
+	default:
+		user[field] = 1
 	}
 
 	// Update the user in the database
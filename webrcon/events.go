@@ -0,0 +1,73 @@
+package webrcon
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+// consoleEvent turns the console lines matching a regex into a typed event
+type consoleEvent struct {
+	messageType eventhandler.MessageType
+	regex       *regexp.Regexp
+
+	// format returns the user and the message of the event, using the named captures of the regex
+	format func(captures map[string]string) (string, string)
+}
+
+// consoleEvents are checked in order, before joins, disconnects and kills (so that eg. kicks aren't reported as regular disconnects)
+var consoleEvents = []consoleEvent{
+	{eventhandler.EACKickType, eacKickRegex, func(captures map[string]string) (string, string) {
+		return captures["user"], withReason(captures["user"]+" was kicked by EAC", captures["reason"])
+	}},
+	{eventhandler.KickType, kickRegex, func(captures map[string]string) (string, string) {
+		return captures["user"], withReason(captures["user"]+" was kicked", captures["reason"])
+	}},
+	{eventhandler.BanType, banRegex, func(captures map[string]string) (string, string) {
+		return captures["user"], withReason(captures["user"]+" was banned", captures["reason"])
+	}},
+	{eventhandler.ReportType, reportRegex, func(captures map[string]string) (string, string) {
+		return captures["reporter"], withReason(captures["reporter"]+" reported "+captures["target"], captures["subject"])
+	}},
+	{eventhandler.SaveType, saveRegex, func(captures map[string]string) (string, string) {
+		return "", "Saved " + captures["entities"] + " entities"
+	}},
+	{eventhandler.StartupType, startupRegex, func(captures map[string]string) (string, string) {
+		return "", "Server startup complete"
+	}},
+	{eventhandler.AirdropType, airdropRegex, func(captures map[string]string) (string, string) {
+		return "", "An airdrop is on its way"
+	}},
+}
+
+// parseConsoleEvent matches a console line against the console events, returning the first matching one
+func parseConsoleEvent(line string) (eventhandler.Message, bool) {
+	line = strings.TrimSpace(line)
+	for _, event := range consoleEvents {
+		matches := event.regex.FindStringSubmatch(line)
+		if len(matches) <= 0 {
+			continue
+		}
+
+		// Construct a simple "dictionary" using the named capture groups
+		captures := make(map[string]string)
+		for i, name := range event.regex.SubexpNames() {
+			if i != 0 && name != "" {
+				captures[name] = strings.TrimSpace(matches[i])
+			}
+		}
+
+		user, message := event.format(captures)
+		return eventhandler.Message{User: user, Message: message, Type: event.messageType}, true
+	}
+	return eventhandler.Message{}, false
+}
+
+// withReason appends a reason in parentheses to a message, if there is one
+func withReason(message string, reason string) string {
+	if len(reason) <= 0 {
+		return message
+	}
+	return message + " (" + reason + ")"
+}
@@ -1,10 +1,6 @@
 package webrcon
 
-// Status represents the current status of the server
-var Status StatusPacket
-
-// Players represents the current players on the server
-var Players PlayerPacket
+import "strconv"
 
 // PacketType represents the type of a webrcon packet
 type PacketType string
@@ -36,15 +32,62 @@ type Packet struct {
 	Identifier PacketIdentifier `json:"Identifier,omitempty"`
 	Type       PacketType       `json:"Type,omitempty"`
 	Stacktrace string           `json:"Stacktrace,omitempty"`
+	Name       string           `json:"Name,omitempty"`
+}
+
+// ChatChannel represents the in-game chat channel of a chat packet
+type ChatChannel int
+
+const (
+	// GlobalChatChannel is the public chat, seen by everyone on the server
+	GlobalChatChannel ChatChannel = 0
+	// TeamChatChannel is the private chat of a team
+	TeamChatChannel ChatChannel = 1
+	// ServerChatChannel is used for messages sent by the server itself (eg. with the "say" command)
+	ServerChatChannel ChatChannel = 2
+	// CardsChatChannel is the chat of the card tables
+	CardsChatChannel ChatChannel = 3
+	// LocalChatChannel is the proximity chat
+	LocalChatChannel ChatChannel = 4
+	// ClanChatChannel is the private chat of a clan
+	ClanChatChannel ChatChannel = 5
+)
+
+// chatChannelNames are the names of the chat channels, as used in the configuration
+var chatChannelNames = map[ChatChannel]string{
+	GlobalChatChannel: "global",
+	TeamChatChannel:   "team",
+	ServerChatChannel: "server",
+	CardsChatChannel:  "cards",
+	LocalChatChannel:  "local",
+	ClanChatChannel:   "clan",
+}
+
+// String returns the name of a chat channel (or its number when it's unknown)
+func (channel ChatChannel) String() string {
+	if name, ok := chatChannelNames[channel]; ok {
+		return name
+	}
+	return strconv.Itoa(int(channel))
+}
+
+// ChatChannelNames returns the names of every known chat channel
+func ChatChannelNames() []string {
+	names := make([]string, 0, len(chatChannelNames))
+	for channel := GlobalChatChannel; channel <= ClanChatChannel; channel++ {
+		names = append(names, channel.String())
+	}
+	return names
 }
 
 // ChatPacket represents a single webrcon chat packet
 type ChatPacket struct {
-	Message  string `json:"Message,omitempty"`
-	UserID   uint64 `json:"UserId,omitempty"`
-	Username string `json:"Username,omitempty"`
-	Color    string `json:"Color,omitempty"`
-	Time     uint64 `json:"Time,omitempty"`
+	Channel  ChatChannel `json:"Channel,omitempty"`
+	Message  string      `json:"Message,omitempty"`
+	UserID   uint64      `json:"UserId,omitempty"`
+	Username string      `json:"Username,omitempty"`
+	Color    string      `json:"Color,omitempty"`
+	Time     uint64      `json:"Time,omitempty"`
 }
 
 // JoinPacket represents a single webrcon join packet
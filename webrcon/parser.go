@@ -0,0 +1,174 @@
+package webrcon
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidStatus is returned when the output doesn't look like the output of the status command
+var ErrInvalidStatus = errors.New("not a valid status output")
+
+// playerListEntry is a single player in the output of the playerlist command
+type playerListEntry struct {
+	SteamID          string  `json:"SteamID"`
+	OwnerSteamID     string  `json:"OwnerSteamID"`
+	DisplayName      string  `json:"DisplayName"`
+	Ping             int     `json:"Ping"`
+	Address          string  `json:"Address"`
+	ConnectedSeconds float64 `json:"ConnectedSeconds"`
+	ViolationLevel   float32 `json:"VoiationLevel"` // Sic, the server misspells it
+}
+
+// ParsePlayerList parses the JSON output of the playerlist command
+func ParsePlayerList(output string) ([]*PlayerPacket, error) {
+	entries := make([]*playerListEntry, 0)
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entries); err != nil {
+		return nil, err
+	}
+
+	players := make([]*PlayerPacket, 0, len(entries))
+	for _, entry := range entries {
+		if entry == nil || len(entry.SteamID) <= 0 {
+			continue
+		}
+		ip, port := splitAddress(entry.Address)
+		players = append(players, &PlayerPacket{
+			SteamID:    entry.SteamID,
+			Username:   entry.DisplayName,
+			Ping:       entry.Ping,
+			Connected:  strconv.FormatFloat(entry.ConnectedSeconds, 'f', -1, 64) + "s",
+			IP:         ip,
+			Port:       port,
+			Violations: entry.ViolationLevel,
+		})
+	}
+	return players, nil
+}
+
+// ParseStatus parses the output of the status command, including the player list
+// (unknown header lines and malformed player rows are skipped instead of failing the whole status)
+func ParseStatus(output string) (*StatusPacket, error) {
+	status := &StatusPacket{Players: make([]*PlayerPacket, 0)}
+	hasHostname, hasPlayers := false, false
+
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	index := 0
+
+	// The header is a list of "key : value" lines, followed by an empty line
+	for ; index < len(lines); index++ {
+		line := strings.TrimSpace(lines[index])
+		if len(line) <= 0 {
+			if hasHostname {
+				break
+			}
+			continue
+		}
+		separator := strings.Index(line, ":")
+		if separator < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:separator]))
+		value := strings.TrimSpace(line[separator+1:])
+
+		switch key {
+		case "hostname":
+			status.Hostname = value
+			hasHostname = true
+		case "version":
+			fields := strings.Fields(value)
+			if len(fields) > 0 {
+				status.Version, _ = strconv.Atoi(fields[0])
+			}
+			if start, end := strings.Index(value, "("), strings.LastIndex(value, ")"); start >= 0 && end > start {
+				status.Secure = value[start+1 : end]
+			}
+		case "map":
+			status.Map = value
+		case "players":
+			matches := playerCountRegex.FindStringSubmatch(value)
+			if len(matches) <= 0 {
+				return nil, errors.New("invalid player count: " + value)
+			}
+			status.CurrentPlayers, _ = strconv.Atoi(matches[1])
+			status.MaxPlayers, _ = strconv.Atoi(matches[2])
+			status.QueuedPlayers, _ = strconv.Atoi(matches[3])
+			status.JoiningPlayers, _ = strconv.Atoi(matches[4])
+			hasPlayers = true
+		}
+	}
+	if !hasHostname || !hasPlayers {
+		return nil, ErrInvalidStatus
+	}
+
+	// The player list is a table with a header row, one player per row
+	for ; index < len(lines); index++ {
+		line := strings.TrimSpace(lines[index])
+		if len(line) <= 0 || strings.HasPrefix(line, "id ") {
+			continue
+		}
+		if player := parseStatusPlayer(line); player != nil {
+			status.Players = append(status.Players, player)
+		}
+	}
+
+	return status, nil
+}
+
+// parseStatusPlayer parses a single row of the status player list, returning nil if the row is malformed:
+//
+//	id                name          ping connected addr               owner violation kicks
+//	76561198026306491 "NinjaMaster" 26   58847.23s 85.76.8.230:64178        0.0       0
+func parseStatusPlayer(line string) *PlayerPacket {
+	// The name is quoted, but may contain quotes itself, so take everything between the first and the last quote
+	start, end := strings.Index(line, `"`), strings.LastIndex(line, `"`)
+	if start < 0 || end <= start {
+		return nil
+	}
+	steamID := strings.TrimSpace(line[:start])
+	if _, err := strconv.ParseUint(steamID, 10, 64); err != nil {
+		return nil
+	}
+
+	// The owner column is empty for most players, so count the columns from both ends
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 5 {
+		return nil
+	}
+	ping, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil
+	}
+	violations, err := strconv.ParseFloat(fields[len(fields)-2], 32)
+	if err != nil {
+		return nil
+	}
+	kicks, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return nil
+	}
+	ip, port := splitAddress(fields[2])
+
+	return &PlayerPacket{
+		SteamID:    steamID,
+		Username:   line[start+1 : end],
+		Ping:       ping,
+		Connected:  fields[1],
+		IP:         ip,
+		Port:       port,
+		Violations: float32(violations),
+		Kicks:      kicks,
+	}
+}
+
+// splitAddress splits an "ip:port" address, returning the whole address as the IP if it has no port
+func splitAddress(address string) (string, int) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, 0
+	}
+	port, _ := strconv.Atoi(portString)
+	return host, port
+}
@@ -0,0 +1,331 @@
+package webrcon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source RCON packet types (https://developer.valvesoftware.com/wiki/Source_RCON_Protocol)
+const (
+	rconResponseValue int32 = 0 // SERVERDATA_RESPONSE_VALUE
+	rconExecCommand   int32 = 2 // SERVERDATA_EXECCOMMAND
+	rconAuthResponse  int32 = 2 // SERVERDATA_AUTH_RESPONSE
+	rconAuth          int32 = 3 // SERVERDATA_AUTH
+)
+
+const (
+	// Identifier of the authentication request (the server replies with -1 if the password is wrong)
+	rconAuthID int32 = 1
+
+	// Identifier of the empty commands sent to keep the connection alive
+	rconKeepaliveID int32 = -100
+
+	// Identifiers of the empty commands marking the end of a (multi-packet) response count down from here
+	rconFirstTerminatorID int32 = -1000
+
+	// Time allowed to connect and authenticate
+	rconDialTimeout = 10 * time.Second
+
+	// Largest packet we're willing to read, anything bigger means that the stream is broken
+	maxRconPacketSize = 1 << 20
+)
+
+// ErrAuthenticationFailed is returned when the server doesn't accept the RCON password
+var ErrAuthenticationFailed = errors.New("authentication failed, check the password")
+
+// rconPacket is a single Source RCON packet
+type rconPacket struct {
+	ID   int32
+	Type int32
+	Body string
+}
+
+// rconTransport connects to the server over classic Source RCON (TCP), where the server splits long responses into multiple packets
+// and broadcasts its console output to every authenticated client
+type rconTransport struct {
+	address    string
+	password   string
+	handlers   TransportHandlers
+	conn       net.Conn
+	connMutex  *sync.Mutex
+	writeMutex *sync.Mutex
+
+	// Responses that are still being received (by command identifier), and the commands that their terminators belong to
+	responses    map[int32]*strings.Builder
+	terminators  map[int32]int32
+	terminatorID int32
+}
+
+func newRconTransport(address string, password string, handlers TransportHandlers) *rconTransport {
+	return &rconTransport{
+		address:    address,
+		password:   password,
+		handlers:   handlers,
+		connMutex:  &sync.Mutex{},
+		writeMutex: &sync.Mutex{},
+	}
+}
+
+func (transport *rconTransport) Connect() {
+	conn, err := net.DialTimeout("tcp", transport.address, rconDialTimeout)
+	if err != nil {
+		transport.handlers.OnConnectError(err)
+		return
+	}
+	reader := bufio.NewReader(conn)
+	if err := transport.authenticate(conn, reader); err != nil {
+		conn.Close()
+		transport.handlers.OnConnectError(err)
+		return
+	}
+
+	transport.connMutex.Lock()
+	transport.conn = conn
+	transport.responses = make(map[int32]*strings.Builder)
+	transport.terminators = make(map[int32]int32)
+	transport.connMutex.Unlock()
+
+	// Like the websocket client, let the handler know before we start reading
+	transport.handlers.OnConnected()
+	go transport.read(conn, reader)
+}
+
+// authenticate sends the password and waits for the server to accept it
+func (transport *rconTransport) authenticate(conn net.Conn, reader *bufio.Reader) error {
+	conn.SetDeadline(time.Now().Add(rconDialTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if err := writeRconPacket(conn, rconPacket{ID: rconAuthID, Type: rconAuth, Body: transport.password}); err != nil {
+		return err
+	}
+
+	// The server sends an empty response before the actual authentication response
+	for {
+		packet, err := readRconPacket(reader)
+		if err != nil {
+			return err
+		}
+		if packet.Type != rconAuthResponse {
+			continue
+		}
+		if packet.ID == -1 {
+			return ErrAuthenticationFailed
+		}
+		return nil
+	}
+}
+
+func (transport *rconTransport) Close() {
+	transport.connMutex.Lock()
+	conn := transport.conn
+	transport.connMutex.Unlock()
+
+	// Only close the connection if we actually managed to connect
+	if conn == nil || !transport.detach(conn) {
+		return
+	}
+	transport.handlers.OnDisconnected(nil)
+}
+
+func (transport *rconTransport) IsConnected() bool {
+	transport.connMutex.Lock()
+	defer transport.connMutex.Unlock()
+	return transport.conn != nil
+}
+
+func (transport *rconTransport) Send(packet Packet) error {
+	transport.connMutex.Lock()
+	conn := transport.conn
+	if conn == nil {
+		transport.connMutex.Unlock()
+		return ErrNotConnected
+	}
+
+	// Long responses are split into multiple packets, so an empty command is sent right after the actual one:
+	// as the server replies in order, the reply to the empty command marks the end of the response
+	commandID := int32(packet.Identifier)
+	terminatorID := transport.nextTerminatorID()
+	transport.responses[commandID] = &strings.Builder{}
+	transport.terminators[terminatorID] = commandID
+	transport.connMutex.Unlock()
+
+	transport.writeMutex.Lock()
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	err := writeRconPacket(conn, rconPacket{ID: commandID, Type: rconExecCommand, Body: packet.Message})
+	if err == nil {
+		err = writeRconPacket(conn, rconPacket{ID: terminatorID, Type: rconExecCommand, Body: ""})
+	}
+	transport.writeMutex.Unlock()
+
+	if err != nil {
+		transport.connMutex.Lock()
+		delete(transport.responses, commandID)
+		delete(transport.terminators, terminatorID)
+		transport.connMutex.Unlock()
+
+		// Closing the broken connection makes the reader fail, which triggers the reconnect logic
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+func (transport *rconTransport) Ping() error {
+	transport.connMutex.Lock()
+	conn := transport.conn
+	transport.connMutex.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	// The reply to the empty keepalive command extends the read deadline, so a silent server is eventually detected
+	transport.writeMutex.Lock()
+	defer transport.writeMutex.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := writeRconPacket(conn, rconPacket{ID: rconKeepaliveID, Type: rconExecCommand, Body: ""}); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// nextTerminatorID returns the identifier for the next terminator (the connection mutex must be held)
+func (transport *rconTransport) nextTerminatorID() int32 {
+	if transport.terminatorID > rconFirstTerminatorID || transport.terminatorID < -(1<<30) {
+		transport.terminatorID = rconFirstTerminatorID + 1
+	}
+	transport.terminatorID--
+	return transport.terminatorID
+}
+
+// read passes every packet received on a connection to handlePacket, until the connection fails
+func (transport *rconTransport) read(conn net.Conn, reader *bufio.Reader) {
+	for {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		packet, err := readRconPacket(reader)
+		if err != nil {
+			// Only report the disconnect if the connection wasn't closed on purpose
+			if transport.detach(conn) {
+				transport.handlers.OnDisconnected(err)
+			}
+			return
+		}
+		transport.handlePacket(packet)
+	}
+}
+
+// detach closes a connection and forgets about it, reporting whether it was still the active connection
+func (transport *rconTransport) detach(conn net.Conn) bool {
+	transport.connMutex.Lock()
+	active := transport.conn == conn
+	if active {
+		transport.conn = nil
+		transport.responses = nil
+		transport.terminators = nil
+	}
+	transport.connMutex.Unlock()
+
+	conn.Close()
+	return active
+}
+
+// handlePacket collects the packets of command responses, passing everything else on as console output
+func (transport *rconTransport) handlePacket(packet rconPacket) {
+	if packet.Type != rconResponseValue || packet.ID == rconKeepaliveID {
+		return
+	}
+
+	transport.connMutex.Lock()
+	if commandID, ok := transport.terminators[packet.ID]; ok {
+		response := transport.responses[commandID]
+		delete(transport.terminators, packet.ID)
+		delete(transport.responses, commandID)
+		transport.connMutex.Unlock()
+
+		if response != nil {
+			transport.handlers.OnPacket(Packet{Message: response.String(), Identifier: PacketIdentifier(commandID), Type: GenericType})
+		}
+		return
+	}
+	if response, ok := transport.responses[packet.ID]; ok {
+		response.WriteString(packet.Body)
+		transport.connMutex.Unlock()
+		return
+	}
+	transport.connMutex.Unlock()
+
+	// Anything else is console output, one packet per line
+	for _, line := range strings.Split(packet.Body, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			transport.handlers.OnPacket(rconConsolePacket(line, time.Now()))
+		}
+	}
+}
+
+// rconConsolePacket converts a line of console output to a packet, turning chat lines into chat packets like Webrcon sends them
+func rconConsolePacket(line string, now time.Time) Packet {
+	matches := chatRegex.FindStringSubmatch(line)
+	if len(matches) <= 0 {
+		return Packet{Message: line, Identifier: GenericIdentifier, Type: GenericType}
+	}
+
+	userID, _ := strconv.ParseUint(matches[3], 10, 64)
+	chatPacket := ChatPacket{Channel: rconChatChannel(matches[1]), Message: matches[4], UserID: userID, Username: matches[2], Time: uint64(now.Unix())}
+	data, err := json.Marshal(chatPacket)
+	if err != nil {
+		return Packet{Message: line, Identifier: GenericIdentifier, Type: GenericType}
+	}
+	return Packet{Message: string(data), Identifier: ChatIdentifier, Type: ChatType}
+}
+
+// rconChatChannel returns the chat channel for the prefix of a chat line (eg. "TEAM" for "[TEAM CHAT]"), lines without a prefix being global chat
+func rconChatChannel(prefix string) ChatChannel {
+	for channel, name := range chatChannelNames {
+		if strings.EqualFold(name, prefix) {
+			return channel
+		}
+	}
+	return GlobalChatChannel
+}
+
+// writeRconPacket writes a packet: its size, identifier and type as little endian integers, followed by the null terminated body and an empty string
+func writeRconPacket(writer io.Writer, packet rconPacket) error {
+	buffer := &bytes.Buffer{}
+	binary.Write(buffer, binary.LittleEndian, int32(4+4+len(packet.Body)+2))
+	binary.Write(buffer, binary.LittleEndian, packet.ID)
+	binary.Write(buffer, binary.LittleEndian, packet.Type)
+	buffer.WriteString(packet.Body)
+	buffer.Write([]byte{0, 0})
+	_, err := writer.Write(buffer.Bytes())
+	return err
+}
+
+// readRconPacket reads a single packet
+func readRconPacket(reader io.Reader) (rconPacket, error) {
+	var size int32
+	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+		return rconPacket{}, err
+	}
+	if size < 10 || size > maxRconPacketSize {
+		return rconPacket{}, errors.New("invalid RCON packet size: " + strconv.Itoa(int(size)))
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return rconPacket{}, err
+	}
+	return rconPacket{
+		ID:   int32(binary.LittleEndian.Uint32(data[0:4])),
+		Type: int32(binary.LittleEndian.Uint32(data[4:8])),
+		Body: strings.TrimRight(string(data[8:]), "\x00"),
+	}, nil
+}
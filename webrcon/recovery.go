@@ -0,0 +1,244 @@
+package webrcon
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Number of lines requested from chat.tail and console.tail after reconnecting
+	recoveryTailLines = 200
+
+	// Number of relayed lines to remember, so that lines received right after reconnecting aren't replayed again
+	maxRecentLines = 500
+
+	// Number of console lines that must match to find the last seen console line in the output of console.tail
+	consoleAnchorLines = 3
+)
+
+// lineHistory keeps track of the chat and console lines that were already relayed, using the time of the newest chat line as a high-water mark
+// (live console lines have no timestamp, so they're found in the output of console.tail by their order instead)
+type lineHistory struct {
+	mutex        *sync.Mutex
+	chatTime     int64 // Time of the newest chat line (as sent by the server)
+	recent       map[string]bool
+	order        []string
+	console      []string // Recently seen console lines, oldest first
+	consoleCount int64    // Number of console lines seen so far
+}
+
+func newLineHistory() *lineHistory {
+	return &lineHistory{mutex: &sync.Mutex{}, recent: make(map[string]bool)}
+}
+
+// remember stores a relayed chat line, moving the high-water mark forward
+func (history *lineHistory) remember(key string, timestamp int64) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	if timestamp > history.chatTime {
+		history.chatTime = timestamp
+	}
+
+	if history.recent[key] {
+		return
+	}
+	history.recent[key] = true
+	history.order = append(history.order, key)
+	if len(history.order) > maxRecentLines {
+		delete(history.recent, history.order[0])
+		history.order = history.order[1:]
+	}
+}
+
+// rememberConsole stores a console line that was seen live
+func (history *lineHistory) rememberConsole(key string) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	history.consoleCount++
+	history.console = append(history.console, key)
+	if len(history.console) > maxRecentLines {
+		history.console = history.console[1:]
+	}
+}
+
+// marks returns the current high-water marks: the time of the newest chat line (starting from now if nothing has been relayed yet),
+// and the number of console lines seen so far
+func (history *lineHistory) marks(now int64) (int64, int64) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	if history.chatTime <= 0 {
+		history.chatTime = now
+	}
+	return history.chatTime, history.consoleCount
+}
+
+// missed reports whether a chat line wasn't relayed yet, ie. it's not older than the mark and we haven't seen it since
+// (the mark itself is included, as several lines may share the same second)
+func (history *lineHistory) missed(key string, timestamp int64, mark int64) bool {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+	return timestamp >= mark && !history.recent[key]
+}
+
+// missedConsole returns the console lines that were sent after the last console line seen before the mark (a number of seen lines),
+// leaving out the lines seen since then, and whether the missed lines could be determined at all
+func (history *lineHistory) missedConsole(entries []consoleTailEntry, mark int64) ([]consoleTailEntry, bool) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	// Split the seen lines into the ones from before the mark and the ones seen since
+	seenSince := int(history.consoleCount - mark)
+	if seenSince > len(history.console) {
+		seenSince = len(history.console)
+	}
+	before := history.console[:len(history.console)-seenSince]
+	since := make(map[string]bool)
+	for _, key := range history.console[len(history.console)-seenSince:] {
+		since[key] = true
+	}
+	if len(before) <= 0 {
+		return nil, false
+	}
+
+	// Find the newest line that matches the last lines seen before the mark
+	// (if there's none, every line in the output was missed)
+	anchor := -1
+	for i := len(entries) - 1; i >= 0 && anchor < 0; i-- {
+		matched := true
+		for j := 0; j < consoleAnchorLines && j <= i && j < len(before); j++ {
+			if consoleKey(entries[i-j].Message) != before[len(before)-1-j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			anchor = i
+		}
+	}
+
+	missed := make([]consoleTailEntry, 0)
+	for _, entry := range entries[anchor+1:] {
+		if !since[consoleKey(entry.Message)] {
+			missed = append(missed, entry)
+		}
+	}
+	return missed, true
+}
+
+// chatKey identifies a chat line, including the time when it's set by the server
+// (with Source RCON it's our own clock instead, which doesn't match the output of chat.tail)
+func (webrcon *Webrcon) chatKey(chatPacket ChatPacket) string {
+	if webrcon.serverChatTimes {
+		return "chat\x00" + strconv.FormatUint(chatPacket.Time, 10) + "\x00" + chatPacket.Username + "\x00" + chatPacket.Message
+	}
+	return "chat\x00" + chatPacket.Username + "\x00" + chatPacket.Message
+}
+
+// consoleKey identifies a console line
+func consoleKey(message string) string {
+	return "console\x00" + message
+}
+
+// consoleTailEntry is a single line in the output of the console.tail command
+type consoleTailEntry struct {
+	Message string `json:"Message"`
+	Type    string `json:"Type"`
+	Time    int64  `json:"Time"`
+}
+
+// missedLine is a line that was sent while we were disconnected
+type missedLine struct {
+	packet Packet
+	time   int64
+}
+
+// recoverMissedLines replays the chat and console lines that were sent while we were disconnected, which Discord marks as delayed
+// (giving up as soon as the client is closed)
+func (webrcon *Webrcon) recoverMissedLines(chatMark int64, consoleMark int64) {
+	lines := make([]missedLine, 0)
+	ctx := webrcon.ctx
+
+	if output, err := webrcon.Execute(ctx, "chat.tail "+strconv.Itoa(recoveryTailLines)); err != nil {
+		webrcon.logger.Warning("Failed to get missed chat lines:", err)
+	} else if missed, err := webrcon.missedChatLines(output, chatMark); err != nil {
+		webrcon.logger.Warning("Failed to parse missed chat lines:", err)
+	} else {
+		lines = append(lines, missed...)
+	}
+
+	if output, err := webrcon.Execute(ctx, "console.tail "+strconv.Itoa(recoveryTailLines)); err != nil {
+		webrcon.logger.Warning("Failed to get missed console lines:", err)
+	} else if missed, err := webrcon.missedConsoleLines(output, consoleMark); err != nil {
+		webrcon.logger.Warning("Failed to parse missed console lines:", err)
+	} else {
+		lines = append(lines, missed...)
+	}
+
+	if len(lines) <= 0 || ctx.Err() != nil {
+		return
+	}
+
+	// Replay the lines in the order they were sent, through the same pipeline as live lines
+	webrcon.logger.Info("Replaying", len(lines), "missed lines")
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].time < lines[j].time
+	})
+	for _, line := range lines {
+		webrcon.handleTextMessage(line.packet, true)
+	}
+}
+
+// missedChatLines returns the lines in the output of chat.tail that weren't relayed yet, as chat packets
+func (webrcon *Webrcon) missedChatLines(output string, mark int64) ([]missedLine, error) {
+	entries := make([]json.RawMessage, 0)
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entries); err != nil {
+		return nil, err
+	}
+
+	lines := make([]missedLine, 0)
+	for _, entry := range entries {
+		// Newer servers send the user ID as a string, which we can safely ignore here (like the live chat messages do)
+		chatPacket := ChatPacket{}
+		json.Unmarshal(entry, &chatPacket)
+		if !webrcon.history.missed(webrcon.chatKey(chatPacket), int64(chatPacket.Time), mark) {
+			continue
+		}
+		lines = append(lines, missedLine{packet: Packet{Message: string(entry), Identifier: ChatIdentifier, Type: ChatType}, time: int64(chatPacket.Time)})
+	}
+	return lines, nil
+}
+
+// missedConsoleLines returns the lines in the output of console.tail that weren't relayed yet (the mark being the number of console lines seen), as generic packets
+func (webrcon *Webrcon) missedConsoleLines(output string, mark int64) ([]missedLine, error) {
+	entries := make([]consoleTailEntry, 0)
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &entries); err != nil {
+		return nil, err
+	}
+
+	missed, ok := webrcon.history.missedConsole(entries, mark)
+	if !ok {
+		webrcon.logger.Warning("Skipping missed console lines, as no console lines were seen before disconnecting")
+	}
+
+	lines := make([]missedLine, 0)
+	for _, entry := range missed {
+		lines = append(lines, missedLine{packet: Packet{Message: entry.Message, Identifier: GenericIdentifier, Type: GenericType}, time: entry.Time})
+	}
+	return lines, nil
+}
+
+// rememberLine stores a relayed line in the history (replayed console lines are left out, as they're older than the live ones)
+func (webrcon *Webrcon) rememberLine(packet Packet, chatPacket *ChatPacket, delayed bool) {
+	if chatPacket != nil {
+		webrcon.history.remember(webrcon.chatKey(*chatPacket), int64(chatPacket.Time))
+		return
+	}
+	if !delayed {
+		webrcon.history.rememberConsole(consoleKey(packet.Message))
+	}
+}
@@ -2,11 +2,23 @@ package webrcon
 
 import "regexp"
 
-var chatRegex = regexp.MustCompile(`\[CHAT\] (.+?)\[[0-9]+\/([0-9]+)\] : (.*)`)
+// chatRegex matches chat lines of the console output (eg. "[CHAT] Dids[76561198026306491] : Hello" or "[TEAM CHAT] Dids[76561198026306491] : Hello",
+// older servers also include the network ID)
+var chatRegex = regexp.MustCompile(`^\[(?:(TEAM|CARDS|CLAN|LOCAL) )?CHAT\] (.+?)\[(?:[0-9]+\/)?([0-9]+)\] : (.*)`)
 var joinRegex = regexp.MustCompile(`(.*):([0-9]+)+\/([0-9]+)+\/(.+?) joined \[(.*)\/([0-9]+)]`)
 var disconnectRegex = regexp.MustCompile(`(.*):([0-9]+)+\/([0-9]+)+\/(.+?) disconnecting: (.*)`)
 var killRegex = regexp.MustCompile(`(?P<victim>.+?)(?:\[(?:[0-9]+?)\/(?P<victimid>[0-9]+?)\])(?: (?P<how>was killed by|died) )(?P<killer>(?:(?:[^\/\[\]]+)\[[0-9]+/(?P<killerid>[0-9]+)\]$)|(?P<reason>[^\/]*$))`)
-var statusRegex = regexp.MustCompile(`(?:.*?hostname:\s*(?P<hostname>.*?)\\n)(?:.*?version\s*:\s*(?P<version>\d+) )(?:.*?secure\s*\((?P<secure>.*?)\)\\n)(?:.*?map\s*:\s*(?P<map>.*?)\\n)(?:.*?players\s*:\s*(?P<players_current>\d+) \((?P<players_max>\d+) max\) \((?P<players_queued>\d+) queued\) \((?P<players_joining>\d+) joining\)\\n)`)
-var playerListRegex = regexp.MustCompile(`(?:\\n)(?P<SteamID>[0-9]+?)(?:[ ]+?)(?:\\\")(?P<Username>.+?)(?:\\\")(?:[ ]+?)(?P<Ping>[0-9]+?)(?:[ ]+?)(?P<Connected>[0-9.s]+?)(?:[ ]+?)(?P<IP>[0-9.]+?)(?::)(?P<Port>[0-9]+?)(?:[ ]+?)(?P<OwnerSteamID>[0-9]*?)(?:[ ]+?)(?P<Violations>[0-9.]+?)(?:[ ]+?)(?P<Kicks>[0-9]+?)(?:[ ]+?)`)
 var removeIDsRegex = regexp.MustCompile(`\[.+?\/.+?\]`)
 var removeBracesRegex = regexp.MustCompile(`(?:.+)( \(.+\))`)
+
+// playerCountRegex matches the player counts of the status output (eg. "5 (64 max) (0 queued) (0 joining)")
+var playerCountRegex = regexp.MustCompile(`^(\d+)\s*\((\d+) max\)(?:\s*\((\d+) queued\))?(?:\s*\((\d+) joining\))?`)
+
+// Console events (see consoleEvents), most servers print these exactly like this
+var eacKickRegex = regexp.MustCompile(`^(?:.*):[0-9]+\/(?P<steamid>[0-9]+)\/(?P<user>.+?) disconnecting: (?:Kicked: )?(?:Kicked by )?(?:EAC|EasyAntiCheat)(?::| -)? ?(?P<reason>.*)$`)
+var kickRegex = regexp.MustCompile(`^(?:.*):[0-9]+\/(?P<steamid>[0-9]+)\/(?P<user>.+?) disconnecting: Kicked:? ?(?P<reason>.*)$`)
+var banRegex = regexp.MustCompile(`^(?:Kick)?[Bb]anned [Uu]ser: (?P<steamid>[0-9]+) - (?P<user>.+?)(?: - (?P<reason>.*))?$`)
+var reportRegex = regexp.MustCompile(`^\[PlayerReport\] (?P<reporter>.+?)\[(?P<reporterid>[0-9]+)\] reported (?P<target>.+?)\[(?P<targetid>[0-9]+)\] - "(?P<subject>.*)"`)
+var saveRegex = regexp.MustCompile(`^Saved (?P<entities>[0-9,]+) ents`)
+var startupRegex = regexp.MustCompile(`^Server startup complete`)
+var airdropRegex = regexp.MustCompile(`^\[event\] assets\/prefabs\/npc\/cargo plane\/cargo_plane\.prefab`)
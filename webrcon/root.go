@@ -1,8 +1,8 @@
 package webrcon
 
 import (
+	"context"
 	"errors"
-	"os"
 	"sync"
 	"time"
 
@@ -10,27 +10,43 @@ import (
 	"github.com/Dids/rustbot/eventhandler"
 	"github.com/Dids/rustbot/logger"
 	"github.com/HouzuoGuo/tiedot/db"
-
-	"github.com/sacOO7/gowebsocket"
 )
 
 // Webrcon is an abstraction around the Webrcon client
 type Webrcon struct {
-	Client                gowebsocket.Socket
+	ID                    string
+	Name                  string
+	Client                Transport
 	EventHandler          *eventhandler.EventHandler
 	Database              *database.Database
 	DiscordMessageHandler chan eventhandler.Message
 
 	// Private properties
-	logger          *logger.Logger
-	usersCollection *db.Col
-	isShuttingDown  bool
-	writeMutex      *sync.Mutex
+	logger           *logger.Logger
+	usersCollection  *db.Col
+	stop             chan struct{}
+	handlerDone      chan struct{}
+	closeMutex       *sync.Mutex
+	ctx              context.Context // Cancelled on Close, for the work that runs in the background (like recovering missed lines)
+	cancel           context.CancelFunc
+	reconnectMutex   *sync.Mutex
+	reconnectTimer   *time.Timer
+	reconnectAttempt int
+	offlineSince     time.Time
+	identifier       PacketIdentifier
+	pendingCommands  map[PacketIdentifier]chan Packet
+	pendingMutex     *sync.Mutex
+	status           *StatusPacket
+	statusMutex      *sync.Mutex
+	history          *lineHistory
+	serverChatTimes  bool // Whether chat lines are timestamped by the server (Webrcon), or by us (Source RCON)
+	rules            []*Rule
+	rulesMutex       *sync.Mutex
 }
 
-// NewWebrcon creates and returns a new instance of Webrcon
-func NewWebrcon(handler *eventhandler.EventHandler, db *database.Database) (*Webrcon, error) {
-	webrcon := &Webrcon{}
+// NewWebrcon creates and returns a new instance of Webrcon for the server with the given ID (see ServerIDs)
+func NewWebrcon(handler *eventhandler.EventHandler, db *database.Database, id string) (*Webrcon, error) {
+	webrcon := &Webrcon{ID: id, Name: ServerName(id), stop: make(chan struct{}), handlerDone: make(chan struct{}), closeMutex: &sync.Mutex{}}
 
 	// Store a reference to the Logger
 	webrcon.logger = logger.GetLogger()
@@ -45,39 +61,58 @@ func NewWebrcon(handler *eventhandler.EventHandler, db *database.Database) (*Web
 	// Make sure that required indexes are set on the users collection
 	webrcon.usersCollection.Index([]string{"SteamID"})
 
-	// Initialize the websocket client
-	webrcon.Client = gowebsocket.New("ws://" + os.Getenv("WEBRCON_HOST") + ":" + os.Getenv("WEBRCON_PORT") + "/" + os.Getenv("WEBRCON_PASSWORD"))
-
-	// Setup websocket client event handlers
-	webrcon.Client.OnConnected = webrcon.handleConnect
-	webrcon.Client.OnDisconnected = webrcon.handleDisconnect
-	webrcon.Client.OnConnectError = webrcon.handleConnectError
-	webrcon.Client.OnPingReceived = webrcon.handlePingReceived
-	webrcon.Client.OnPongReceived = webrcon.handlePongReceived
-	webrcon.Client.OnTextMessage = webrcon.handleTextMessage
+	// Initialize the client for the configured transport (Webrcon or Source RCON), along with its event handlers
+	client, err := newTransport(id, TransportHandlers{
+		OnConnected:    webrcon.handleConnect,
+		OnDisconnected: webrcon.handleDisconnect,
+		OnConnectError: webrcon.handleConnectError,
+		OnPacket:       webrcon.handlePacket,
+	})
+	if err != nil {
+		return nil, err
+	}
+	webrcon.Client = client
+	_, webrcon.serverChatTimes = client.(*websocketTransport)
 
 	// Setup our custom event handler
 	webrcon.DiscordMessageHandler = make(chan eventhandler.Message)
 	webrcon.EventHandler = handler
 	webrcon.EventHandler.AddListener("receive_discord_message", webrcon.DiscordMessageHandler)
 	go func() {
+		defer close(webrcon.handlerDone)
 		for {
-			webrcon.handleIncomingDiscordMessage(<-webrcon.DiscordMessageHandler)
+			select {
+			case <-webrcon.stop:
+				return
+			case message := <-webrcon.DiscordMessageHandler:
+				webrcon.handleIncomingDiscordMessage(message)
+			}
 		}
 	}()
 
 	// Store the database reference
 	webrcon.Database = db
 
-	// Create the write mutex
-	webrcon.writeMutex = &sync.Mutex{}
+	// Create the mutexes and command tracking
+	webrcon.reconnectMutex = &sync.Mutex{}
+	webrcon.pendingCommands = make(map[PacketIdentifier]chan Packet)
+	webrcon.pendingMutex = &sync.Mutex{}
+	webrcon.statusMutex = &sync.Mutex{}
+	webrcon.history = newLineHistory()
+	webrcon.rulesMutex = &sync.Mutex{}
+	webrcon.ctx, webrcon.cancel = context.WithCancel(context.Background())
+
+	// Load the console rules for modded servers (optional)
+	if err := webrcon.ReloadRules(); err != nil {
+		return nil, err
+	}
 
 	return webrcon, nil
 }
 
 // Open will start the Webrcon client and connect to the server
 func (webrcon *Webrcon) Open() error {
-	if webrcon.isShuttingDown {
+	if webrcon.isShuttingDown() {
 		webrcon.logger.Warning("Already shutting down!")
 		return errors.New("shutdown in progress")
 	}
@@ -98,23 +133,52 @@ func (webrcon *Webrcon) Open() error {
 
 // Close will gracefully shutdown and cleanup the Webrcon connection
 func (webrcon *Webrcon) Close() error {
-	if webrcon.isShuttingDown {
+	webrcon.closeMutex.Lock()
+	if webrcon.isShuttingDown() {
+		webrcon.closeMutex.Unlock()
 		webrcon.logger.Warning("Already shutting down!")
 		return errors.New("shutdown in progress")
 	}
-	webrcon.isShuttingDown = true
+	close(webrcon.stop)
+	webrcon.closeMutex.Unlock()
+	webrcon.cancel()
 
 	webrcon.logger.Info("Closing Webrcon..")
 
+	// Fail any commands that are still waiting for a reply
+	webrcon.cancelPendingCommands()
+
 	// Send shutdown message to Discord
-	webrcon.EventHandler.Emit(eventhandler.Message{Event: "receive_webrcon_message", User: "", Message: "Going away, see you in a bit..", Type: eventhandler.ServerDisconnectedType})
+	webrcon.emit(eventhandler.Message{User: "", Message: "Going away, see you in a bit..", Type: eventhandler.ServerDisconnectedType})
 
 	// Sleep for a bit before shutting down
 	time.Sleep(1 * time.Second)
 
 	webrcon.EventHandler.RemoveListener("receive_discord_message", webrcon.DiscordMessageHandler)
+	webrcon.stopReconnecting()
+
+	// Wait for the Discord message handler to finish, so that nothing is sent to the server after we're closed
+	<-webrcon.handlerDone
+
 	webrcon.Client.Close()
 	webrcon.logger.Trace("Successfully shut down the Webrcon client!")
 
 	return nil
 }
+
+// isShuttingDown reports whether the client has been closed
+func (webrcon *Webrcon) isShuttingDown() bool {
+	select {
+	case <-webrcon.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// emit sends a message to Discord, tagged with the ID of this server so that it ends up in the right channels
+func (webrcon *Webrcon) emit(message eventhandler.Message) {
+	message.Event = "receive_webrcon_message"
+	message.Server = webrcon.ID
+	webrcon.EventHandler.Emit(message)
+}
@@ -1,7 +1,1180 @@
 package webrcon
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dids/rustbot/database"
+	"github.com/Dids/rustbot/eventhandler"
+	"github.com/gorilla/websocket"
+)
+
+// Run "go test ./webrcon -update" to regenerate the golden files in testdata
+var update = flag.Bool("update", false, "update the golden files")
 
 func TestDummy(t *testing.T) {
 
 }
+
+func TestReconnectDelay(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		random   float64
+		expected time.Duration
+	}{
+		{0, 0, time.Second},
+		{0, 1, 2 * time.Second},
+		{1, 0.5, 3 * time.Second},
+		{3, 1, 16 * time.Second},
+		{10, 0, time.Minute},
+		{100, 1, 2 * time.Minute},
+	}
+	for _, test := range tests {
+		if delay := reconnectDelay(test.attempt, test.random); delay != test.expected {
+			t.Error("expected attempt", test.attempt, "with", test.random, "to wait", test.expected, "but got", delay)
+		}
+	}
+}
+
+func TestFormatDowntime(t *testing.T) {
+	tests := map[time.Duration]string{
+		12 * time.Second:                 "12s",
+		4*time.Minute + 10*time.Second:   "4m",
+		72*time.Minute + 40*time.Second:  "1h13m",
+		3 * time.Hour:                    "3h0m",
+		59*time.Second + time.Nanosecond: "59s",
+	}
+	for duration, expected := range tests {
+		if result := formatDowntime(duration); result != expected {
+			t.Error("expected", duration, "to be formatted as", expected, "but got", result)
+		}
+	}
+}
+
+// testStatus is the output of the status command
+const testStatus = "hostname: [FIN] Test Server\nversion : 2151 secure (secure mode enabled, connected to Steam3)\nmap     : Procedural Map\nplayers : 2 (64 max) (0 queued) (1 joining)\n\nid                name                   ping connected addr                 owner violation kicks \n76561198026306491 \"NinjaMaster\"          26   58847.23s 85.76.8.230:64178          0.0       0     \n76561198162745820 \"seavaniasa\"           12   10660.97s 82.131.23.78:55801         0.0       0     \n"
+
+// testServer is a websocket server that accepts Webrcon connections and can drop them
+type testServer struct {
+	*httptest.Server
+	connections []*websocket.Conn
+	commands    []string
+	tails       map[string]string
+	hangs       map[string]bool
+	console     []consoleTailEntry
+	mutex       sync.Mutex
+}
+
+func newTestServer() *testServer {
+	server := &testServer{}
+	upgrader := websocket.Upgrader{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		server.mutex.Lock()
+		server.connections = append(server.connections, conn)
+		server.mutex.Unlock()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			packet := Packet{}
+			if err := json.Unmarshal(data, &packet); err != nil {
+				continue
+			}
+			server.mutex.Lock()
+			server.commands = append(server.commands, packet.Message)
+			server.mutex.Unlock()
+			go server.reply(conn, packet)
+		}
+	}))
+	return server
+}
+
+// reply answers a command like the server would, replying to "echo" commands after a random delay (so that replies arrive out of order)
+// and never replying to "hang" (or to the commands set with setHang)
+func (server *testServer) reply(conn *websocket.Conn, packet Packet) {
+	server.mutex.Lock()
+	hangs := server.hangs[strings.SplitN(packet.Message, " ", 2)[0]]
+	server.mutex.Unlock()
+
+	output := ""
+	switch {
+	case packet.Message == "hang", hangs:
+		return
+	case packet.Message == "status":
+		output = testStatus
+	case strings.HasPrefix(packet.Message, "echo "):
+		time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
+		output = strings.TrimPrefix(packet.Message, "echo ")
+	case strings.HasPrefix(packet.Message, "chat.tail "), strings.HasPrefix(packet.Message, "console.tail "):
+		server.mutex.Lock()
+		tail, ok := server.tails[strings.Fields(packet.Message)[0]]
+		if !ok && strings.HasPrefix(packet.Message, "console.tail ") {
+			data, _ := json.Marshal(server.console)
+			tail = string(data)
+		}
+		output = tail
+		server.mutex.Unlock()
+	}
+
+	// Console output that isn't a reply is sent with an identifier of zero
+	server.logConsole("Some console output", time.Now().Unix())
+	server.send(conn, Packet{Message: "Some console output", Identifier: GenericIdentifier, Type: GenericType})
+	server.send(conn, Packet{Message: output, Identifier: packet.Identifier, Type: GenericType})
+}
+
+// send writes a packet to a connection
+func (server *testServer) send(conn *websocket.Conn, packet Packet) {
+	data, _ := json.Marshal(packet)
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// broadcast sends a packet to every connection, adding console output to the output of console.tail
+func (server *testServer) broadcast(packet Packet) {
+	if packet.Identifier == GenericIdentifier && packet.Type == GenericType {
+		server.logConsole(packet.Message, time.Now().Unix())
+	}
+	server.mutex.Lock()
+	connections := append([]*websocket.Conn{}, server.connections...)
+	server.mutex.Unlock()
+	for _, conn := range connections {
+		server.send(conn, packet)
+	}
+}
+
+// setTail sets the output of the chat.tail or console.tail command
+func (server *testServer) setTail(command string, output string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.tails == nil {
+		server.tails = make(map[string]string)
+	}
+	server.tails[command] = output
+}
+
+// logConsole adds a line to the output of console.tail (unless it was set with setTail), without sending it to anyone
+func (server *testServer) logConsole(message string, timestamp int64) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.console = append(server.console, consoleTailEntry{Message: message, Type: "Log", Time: timestamp})
+}
+
+// setHang makes the server never reply to a command (like chat.tail)
+func (server *testServer) setHang(command string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.hangs == nil {
+		server.hangs = make(map[string]bool)
+	}
+	server.hangs[command] = true
+}
+
+// disconnect drops every connection
+func (server *testServer) disconnect() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, conn := range server.connections {
+		conn.Close()
+	}
+	server.connections = nil
+}
+
+// receivedCommands returns a copy of every command received so far
+func (server *testServer) receivedCommands() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string{}, server.commands...)
+}
+
+// newTestDatabase opens an empty database that is removed once the test is done
+func newTestDatabase(t *testing.T) *database.Database {
+	db, err := database.NewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(db.Path)
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(db.Path)
+	})
+	return db
+}
+
+// newTestWebrcon creates a Webrcon client for the given address, along with a channel receiving every message it sends to Discord
+func newTestWebrcon(t *testing.T, address string) (*Webrcon, chan eventhandler.Message) {
+	db := newTestDatabase(t)
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WEBRCON_HOST", host)
+	t.Setenv("WEBRCON_PORT", port)
+	t.Setenv("WEBRCON_PASSWORD", "password")
+
+	handler := &eventhandler.EventHandler{}
+	messages := make(chan eventhandler.Message, 100)
+	handler.AddListener("receive_webrcon_message", messages)
+	webrcon, err := NewWebrcon(handler, db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return webrcon, messages
+}
+
+// waitForConnectionMessage waits for a server (dis)connected message, ignoring any other messages
+func waitForConnectionMessage(t *testing.T, messages chan eventhandler.Message, messageType eventhandler.MessageType) eventhandler.Message {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case message := <-messages:
+			if message.Type == eventhandler.ServerConnectedType || message.Type == eventhandler.ServerDisconnectedType {
+				if message.Type != messageType {
+					t.Fatal("expected a message of type", messageType, "but got", message)
+				}
+				return message
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for a message of type", messageType)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	webrcon, messages := newTestWebrcon(t, server.Listener.Addr().String())
+	if err := webrcon.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if message := waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType); message.Message != "I'm back, baby!" {
+		t.Fatal("unexpected connected message:", message)
+	}
+
+	// Losing the connection is announced once, followed by a single message once we're back
+	server.disconnect()
+	if message := waitForConnectionMessage(t, messages, eventhandler.ServerDisconnectedType); message.Message != "Server is offline, reconnecting.." {
+		t.Fatal("unexpected disconnected message:", message)
+	}
+	webrcon.handleConnectError(os.ErrDeadlineExceeded)
+	if message := waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType); message.Message[:len("Server is back after ")] != "Server is back after " {
+		t.Fatal("unexpected connected message:", message)
+	}
+
+	if err := webrcon.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseWithoutConnecting(t *testing.T) {
+	// Find a port that nothing is listening on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	webrcon, messages := newTestWebrcon(t, address)
+	if err := webrcon.Open(); err != nil {
+		t.Fatal(err)
+	}
+	waitForConnectionMessage(t, messages, eventhandler.ServerDisconnectedType)
+
+	// Closing used to panic when there was no connection to close
+	if err := webrcon.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Discord message handler stops along with the client
+	select {
+	case <-webrcon.handlerDone:
+	case <-time.After(time.Second):
+		t.Fatal("the Discord message handler is still running")
+	}
+}
+
+func TestExecute(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	webrcon, messages := newTestWebrcon(t, server.Listener.Addr().String())
+	if err := webrcon.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer webrcon.Close()
+	waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType)
+
+	// Every caller gets its own reply, no matter the order they arrive in
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			output, err := webrcon.Execute(context.Background(), "echo "+strconv.Itoa(i))
+			if err != nil {
+				t.Error(err)
+			} else if output != strconv.Itoa(i) {
+				t.Error("expected the reply to echo", i, "but got", output)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Commands time out when there's no reply
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := webrcon.Execute(ctx, "hang"); err != context.DeadlineExceeded {
+		t.Fatal("expected the command to time out but got", err)
+	}
+
+	// The status is parsed from the reply to the status command
+	status, err := webrcon.GetStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	webrcon.handleStatus(status)
+	for message := range messages {
+		if message.Type == eventhandler.StatusType {
+			if message.Message != "2/64 (1 joining)" || message.User != "[FIN] Test Server" {
+				t.Fatal("unexpected status:", message)
+			}
+			break
+		}
+	}
+
+	// Losing the connection fails any commands waiting for a reply
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		server.disconnect()
+	}()
+	if _, err := webrcon.Execute(context.Background(), "hang"); err != ErrConnectionLost {
+		t.Fatal("expected the connection to be lost but got", err)
+	}
+	if _, err := webrcon.Execute(context.Background(), "echo offline"); err != ErrNotConnected {
+		t.Fatal("expected to not be connected but got", err)
+	}
+
+	// Nothing can be executed once the client is closed
+	if err := webrcon.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := webrcon.Execute(context.Background(), "echo closed"); err != errShuttingDown {
+		t.Fatal("expected the client to be shutting down but got", err)
+	}
+}
+
+// checkGolden compares a result (as JSON) with the golden file matching an input file in testdata
+func checkGolden(t *testing.T, input string, result interface{}) {
+	t.Helper()
+	actual, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual = append(actual, '\n')
+
+	golden := strings.TrimSuffix(input, filepath.Ext(input)) + ".golden"
+	if *update {
+		if err := os.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("%s does not match %s:\n%s", input, golden, actual)
+	}
+}
+
+func TestRelayDiscordMessage(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	webrcon, messages := newTestWebrcon(t, server.Listener.Addr().String())
+	if err := webrcon.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer webrcon.Close()
+	waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType)
+
+	// Long messages are relayed line by line, without waiting for the server to reply to each line
+	server.setHang("say")
+	start := time.Now()
+	webrcon.handleIncomingDiscordMessage(eventhandler.Message{User: "Dids", Message: strings.Repeat("word ", 100)})
+	if elapsed := time.Since(start); elapsed >= commandTimeout/2 {
+		t.Fatal("expected the message to be relayed without waiting for replies, but it took", elapsed)
+	}
+
+	lines := 0
+	timeout := time.After(10 * time.Second)
+	for lines < 4 {
+		lines = 0
+		for _, command := range server.receivedCommands() {
+			if strings.HasPrefix(command, "say [DISCORD] Dids: ") {
+				lines++
+			}
+		}
+		select {
+		case <-timeout:
+			t.Fatal("expected every line to be relayed but got", lines)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "status_*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) <= 0 {
+		t.Fatal("no status outputs in testdata")
+	}
+	for _, input := range inputs {
+		output, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, err := ParseStatus(string(output))
+		if err != nil {
+			t.Fatal(input, err)
+		}
+		checkGolden(t, input, status)
+	}
+
+	for _, output := range []string{"", "Unknown command: status", "hostname: Missing the player count\n"} {
+		if _, err := ParseStatus(output); err == nil {
+			t.Error("expected an error for", output)
+		}
+	}
+}
+
+func TestParsePlayerList(t *testing.T) {
+	input := filepath.Join("testdata", "playerlist.json")
+	output, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	players, err := ParsePlayerList(string(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, input, players)
+
+	if _, err := ParsePlayerList("Unknown command: playerlist"); err == nil {
+		t.Error("expected an error for an unknown command")
+	}
+}
+
+func TestServerIDs(t *testing.T) {
+	t.Setenv("WEBRCON_SERVERS", "")
+	if ids, err := ServerIDs(); err != nil || len(ids) != 1 || ids[0] != "" {
+		t.Fatal("expected a single server without an ID but got", ids, err)
+	}
+
+	t.Setenv("WEBRCON_SERVERS", " main, Monthly ,")
+	if ids, err := ServerIDs(); err != nil || strings.Join(ids, ",") != "MAIN,MONTHLY" {
+		t.Fatal("expected the servers MAIN and MONTHLY but got", ids, err)
+	}
+
+	for _, value := range []string{"main,main", "main,mon-thly", " , "} {
+		t.Setenv("WEBRCON_SERVERS", value)
+		if _, err := ServerIDs(); err == nil {
+			t.Error("expected an error for", value)
+		}
+	}
+}
+
+func TestServerEnv(t *testing.T) {
+	t.Setenv("WEBRCON_PASSWORD", "shared")
+	t.Setenv("WEBRCON_MAIN_PASSWORD", "main")
+	t.Setenv("DISCORD_MAIN_CHAT_CHANNEL_ID", "123")
+	t.Setenv("DISCORD_CHAT_CHANNEL_ID", "456")
+
+	tests := []struct {
+		serverID string
+		name     string
+		expected string
+	}{
+		{"", "WEBRCON_PASSWORD", "shared"},
+		{"MAIN", "WEBRCON_PASSWORD", "main"},
+		{"MONTHLY", "WEBRCON_PASSWORD", "shared"},
+		{"MAIN", "DISCORD_CHAT_CHANNEL_ID", "123"},
+		{"MONTHLY", "DISCORD_CHAT_CHANNEL_ID", "456"},
+	}
+	for _, test := range tests {
+		if value := ServerEnv(test.serverID, test.name); value != test.expected {
+			t.Error("expected", test.name, "of", test.serverID, "to be", test.expected, "but got", value)
+		}
+	}
+
+	t.Setenv("WEBRCON_MONTHLY_NAME", "Monthly Wipe")
+	if name := ServerName("MONTHLY"); name != "Monthly Wipe" {
+		t.Error("expected the configured name but got", name)
+	}
+	if name := ServerName("MAIN"); name != "MAIN" {
+		t.Error("expected the name to default to the ID but got", name)
+	}
+}
+
+func TestIncrementFieldForSteamID(t *testing.T) {
+	db := newTestDatabase(t)
+	if _, err := db.GetCollection("users"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Users stored before there were multiple servers belong to the server without an ID
+	if _, err := db.Set("users", 0, map[string]interface{}{"SteamID": "76561198026306491", "Kills": 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, serverID := range []string{"", "MAIN", "MAIN", "MONTHLY"} {
+		if err := incrementKillCount(db, serverID, "76561198026306491"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users, err := db.Query("users", `[{"eq": "76561198026306491", "in": ["SteamID"]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kills := make(map[string]float64)
+	for _, user := range users {
+		server, _ := user["Server"].(string)
+		kills[server], _ = user["Kills"].(float64)
+	}
+	expected := map[string]float64{"": 4, "MAIN": 2, "MONTHLY": 1}
+	if len(kills) != len(expected) {
+		t.Fatal("expected a user for each server but got", users)
+	}
+	for server, count := range expected {
+		if kills[server] != count {
+			t.Error("expected", count, "kills on server", server, "but got", kills[server])
+		}
+	}
+}
+
+func TestMultipleServers(t *testing.T) {
+	db := newTestDatabase(t)
+	handler := &eventhandler.EventHandler{}
+	messages := make(chan eventhandler.Message, 100)
+	handler.AddListener("receive_webrcon_message", messages)
+
+	t.Setenv("WEBRCON_SERVERS", "main,monthly")
+	t.Setenv("WEBRCON_PASSWORD", "password")
+	servers := make(map[string]*testServer)
+	for _, serverID := range []string{"MAIN", "MONTHLY"} {
+		server := newTestServer()
+		defer server.Close()
+		host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("WEBRCON_"+serverID+"_HOST", host)
+		t.Setenv("WEBRCON_"+serverID+"_PORT", port)
+		servers[serverID] = server
+	}
+
+	ids, err := ServerIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		webrcon, err := NewWebrcon(handler, db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := webrcon.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer webrcon.Close()
+	}
+
+	// Every message is tagged with the server it came from
+	connected := make(map[string]bool)
+	for len(connected) < len(ids) {
+		message := waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType)
+		connected[message.Server] = true
+	}
+	if !connected["MAIN"] || !connected["MONTHLY"] {
+		t.Fatal("expected both servers to connect but got", connected)
+	}
+
+	// Discord messages are only relayed to the server they're meant for
+	handler.Emit(eventhandler.Message{Event: "receive_discord_message", User: "Dids", Message: "Hello", Server: "MONTHLY"})
+	relayed := func(server *testServer) bool {
+		for _, command := range server.receivedCommands() {
+			if command == "say [DISCORD] Dids: Hello" {
+				return true
+			}
+		}
+		return false
+	}
+	timeout := time.After(10 * time.Second)
+	for !relayed(servers["MONTHLY"]) {
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for the message to be relayed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if relayed(servers["MAIN"]) {
+		t.Fatal("expected the message to not be relayed to the other server")
+	}
+}
+
+// testRconServer is a Source RCON server that replies to commands like testServer does, and can broadcast console output
+type testRconServer struct {
+	net.Listener
+	password    string
+	connections []net.Conn
+	mutex       sync.Mutex
+}
+
+func newTestRconServer(t *testing.T, password string) *testRconServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &testRconServer{Listener: listener, password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		server.Close()
+		server.disconnect()
+	})
+	return server
+}
+
+// serve authenticates a connection and replies to its commands, splitting long replies into multiple packets like the server does
+func (server *testRconServer) serve(conn net.Conn) {
+	for {
+		packet, err := readRconPacket(conn)
+		if err != nil {
+			return
+		}
+		switch packet.Type {
+		case rconAuth:
+			if packet.Body != server.password {
+				server.send(conn, rconPacket{ID: -1, Type: rconAuthResponse})
+				conn.Close()
+				return
+			}
+			server.mutex.Lock()
+			server.connections = append(server.connections, conn)
+			server.mutex.Unlock()
+			server.send(conn, rconPacket{ID: packet.ID, Type: rconResponseValue})
+			server.send(conn, rconPacket{ID: packet.ID, Type: rconAuthResponse})
+		case rconExecCommand:
+			output := ""
+			switch {
+			case packet.Body == "status":
+				output = testStatus
+			case packet.Body == "long":
+				output = strings.Repeat("0123456789", 1000)
+			case strings.HasPrefix(packet.Body, "echo "):
+				output = strings.TrimPrefix(packet.Body, "echo ")
+			}
+			for len(output) > 4096 {
+				server.send(conn, rconPacket{ID: packet.ID, Type: rconResponseValue, Body: output[:4096]})
+				output = output[4096:]
+			}
+			server.send(conn, rconPacket{ID: packet.ID, Type: rconResponseValue, Body: output})
+		}
+	}
+}
+
+// send writes a packet to a connection
+func (server *testRconServer) send(conn net.Conn, packet rconPacket) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	writeRconPacket(conn, packet)
+}
+
+// broadcast sends a line of console output to every authenticated connection
+func (server *testRconServer) broadcast(line string) {
+	server.mutex.Lock()
+	connections := append([]net.Conn{}, server.connections...)
+	server.mutex.Unlock()
+	for _, conn := range connections {
+		server.send(conn, rconPacket{ID: 0, Type: rconResponseValue, Body: line})
+	}
+}
+
+// disconnect drops every connection
+func (server *testRconServer) disconnect() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	for _, conn := range server.connections {
+		conn.Close()
+	}
+	server.connections = nil
+}
+
+// waitForMessage waits for a message matching a condition, ignoring any other messages
+func waitForMessage(t *testing.T, messages chan eventhandler.Message, description string, matches func(eventhandler.Message) bool) eventhandler.Message {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case message := <-messages:
+			if matches(message) {
+				return message
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for", description)
+		}
+	}
+}
+
+func TestRconPacket(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	if err := writeRconPacket(buffer, rconPacket{ID: 42, Type: rconExecCommand, Body: "status"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{16, 0, 0, 0, 42, 0, 0, 0, 2, 0, 0, 0, 's', 't', 'a', 't', 'u', 's', 0, 0}
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Fatal("unexpected packet:", buffer.Bytes())
+	}
+	packet, err := readRconPacket(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packet.ID != 42 || packet.Type != rconExecCommand || packet.Body != "status" {
+		t.Fatal("unexpected packet:", packet)
+	}
+
+	if _, err := readRconPacket(bytes.NewReader([]byte{4, 0, 0, 0, 0, 0, 0, 0})); err == nil {
+		t.Fatal("expected an error for an invalid packet size")
+	}
+}
+
+func TestRconConsolePacket(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	for _, line := range []string{"[CHAT] Dids[76561198026306491] : Hello there", "[CHAT] Dids[1234/76561198026306491] : Hello there"} {
+		packet := rconConsolePacket(line, now)
+		if packet.Identifier != ChatIdentifier || packet.Type != ChatType {
+			t.Fatal("expected a chat packet for", line, "but got", packet)
+		}
+		chatPacket := ChatPacket{}
+		if err := json.Unmarshal([]byte(packet.Message), &chatPacket); err != nil {
+			t.Fatal(err)
+		}
+		if chatPacket.Username != "Dids" || chatPacket.UserID != 76561198026306491 || chatPacket.Message != "Hello there" || chatPacket.Time != 1600000000 {
+			t.Error("unexpected chat packet for", line, ":", chatPacket)
+		}
+	}
+
+	channels := map[string]ChatChannel{
+		"[CHAT] Dids[76561198026306491] : Hello":       GlobalChatChannel,
+		"[TEAM CHAT] Dids[76561198026306491] : Hello":  TeamChatChannel,
+		"[CARDS CHAT] Dids[76561198026306491] : Hello": CardsChatChannel,
+	}
+	for line, channel := range channels {
+		chatPacket := ChatPacket{}
+		if err := json.Unmarshal([]byte(rconConsolePacket(line, now).Message), &chatPacket); err != nil {
+			t.Fatal(err)
+		}
+		if chatPacket.Channel != channel || chatPacket.Username != "Dids" || chatPacket.Message != "Hello" {
+			t.Error("expected", channel, "chat for", line, "but got", chatPacket)
+		}
+	}
+
+	if packet := rconConsolePacket("Saved 35,513 ents", now); packet.Identifier != GenericIdentifier || packet.Message != "Saved 35,513 ents" {
+		t.Error("expected a generic packet but got", packet)
+	}
+}
+
+func TestChatChannels(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	webrcon, messages := newTestWebrcon(t, server.Listener.Addr().String())
+
+	for _, chatPacket := range []ChatPacket{
+		{Channel: GlobalChatChannel, Username: "Dids", Message: "Hello everyone"},
+		{Channel: TeamChatChannel, Username: "Dids", Message: "Hello team"},
+		{Username: "SERVER", Message: "Restarting soon"},
+		{Channel: ChatChannel(42), Username: "Dids", Message: "Hello future"},
+	} {
+		data, _ := json.Marshal(chatPacket)
+		webrcon.handleTextMessage(Packet{Message: string(data), Identifier: ChatIdentifier, Type: ChatType}, false)
+	}
+
+	// Messages are emitted concurrently, so they may arrive in any order
+	expected := map[string]string{"Hello everyone": "global", "Hello team": "team", "Restarting soon": "server", "Hello future": "42"}
+	for range expected {
+		message := waitForMessage(t, messages, "chat messages", func(message eventhandler.Message) bool {
+			return len(message.User) > 0
+		})
+		if channel, ok := expected[message.Message]; !ok || message.ChatChannel != channel {
+			t.Error("expected", message.Message, "to be from", channel, "chat but got", message.ChatChannel)
+		}
+	}
+}
+
+func TestRconTransport(t *testing.T) {
+	server := newTestRconServer(t, "password")
+	t.Setenv("WEBRCON_TRANSPORT", "rcon")
+	webrcon, messages := newTestWebrcon(t, server.Addr().String())
+	if err := webrcon.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer webrcon.Close()
+	waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType)
+
+	// Commands work just like over Webrcon, including replies split into multiple packets
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if output, err := webrcon.Execute(context.Background(), "echo "+strconv.Itoa(i)); err != nil || output != strconv.Itoa(i) {
+				t.Error("expected the reply to echo", i, "but got", output, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if output, err := webrcon.Execute(context.Background(), "long"); err != nil || output != strings.Repeat("0123456789", 1000) {
+		t.Fatal("expected the whole long reply but got", len(output), "characters", err)
+	}
+	status, err := webrcon.GetStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Hostname != "[FIN] Test Server" || len(status.Players) != 2 {
+		t.Fatal("unexpected status:", status)
+	}
+	if err := webrcon.Client.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Console output is relayed, including chat
+	server.broadcast("[CHAT] Dids[76561198026306491] : Hello over RCON")
+	waitForMessage(t, messages, "the chat message", func(message eventhandler.Message) bool {
+		return message.User == "Dids" && message.Message == "Hello over RCON"
+	})
+	server.broadcast("85.76.8.230:64178/76561198026306491/NinjaMaster joined [windows/76561198026306491]")
+	waitForMessage(t, messages, "the join message", func(message eventhandler.Message) bool {
+		return message.Type == eventhandler.JoinType && message.User == "NinjaMaster"
+	})
+
+	// Losing the connection is noticed
+	server.disconnect()
+	waitForConnectionMessage(t, messages, eventhandler.ServerDisconnectedType)
+}
+
+func TestRconTransportWrongPassword(t *testing.T) {
+	server := newTestRconServer(t, "password")
+	t.Setenv("WEBRCON_TRANSPORT", "rcon")
+	webrcon, messages := newTestWebrcon(t, server.Addr().String())
+	webrcon.Client = newRconTransport(server.Addr().String(), "wrong", TransportHandlers{
+		OnConnected:    webrcon.handleConnect,
+		OnDisconnected: webrcon.handleDisconnect,
+		OnConnectError: func(err error) {
+			if err != ErrAuthenticationFailed {
+				t.Error("expected the authentication to fail but got", err)
+			}
+			webrcon.handleConnectError(err)
+		},
+		OnPacket: webrcon.handlePacket,
+	})
+	if err := webrcon.Open(); err != nil {
+		t.Fatal(err)
+	}
+	waitForConnectionMessage(t, messages, eventhandler.ServerDisconnectedType)
+	if err := webrcon.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidTransport(t *testing.T) {
+	t.Setenv("WEBRCON_TRANSPORT", "telnet")
+	if _, err := NewWebrcon(&eventhandler.EventHandler{}, newTestDatabase(t), ""); err == nil {
+		t.Fatal("expected an error for an invalid transport")
+	}
+}
+
+func TestRecoverMissedLines(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	server.logConsole("1.2.3.4:5678/76561198162745820/OldJoin joined [windows/76561198162745820]", time.Now().Unix()-3600)
+	webrcon, messages := newTestWebrcon(t, server.Listener.Addr().String())
+	if err := webrcon.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer webrcon.Close()
+	waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType)
+
+	// Relay a chat message and a console line before losing the connection
+	now := time.Now().Unix()
+	before, _ := json.Marshal(ChatPacket{Username: "Dids", Message: "gg", Time: uint64(now)})
+	server.broadcast(Packet{Message: string(before), Identifier: ChatIdentifier, Type: ChatType})
+	waitForMessage(t, messages, "the chat message", func(message eventhandler.Message) bool {
+		return message.Message == "gg"
+	})
+	joined := "1.2.3.4:5678/76561198162745821/BeforeJoin joined [windows/76561198162745821]"
+	server.broadcast(Packet{Message: joined, Identifier: GenericIdentifier, Type: GenericType})
+	waitForMessage(t, messages, "the join message", func(message eventhandler.Message) bool {
+		return message.User == "BeforeJoin"
+	})
+
+	// The tails contain lines we already relayed, lines from before we connected and the lines we missed
+	// (including the same chat message sent again, and a console line logged while we're disconnected)
+	old, _ := json.Marshal(ChatPacket{Username: "Dids", Message: "Old", Time: uint64(now - 3600)})
+	repeated, _ := json.Marshal(ChatPacket{Username: "Dids", Message: "gg", Time: uint64(now + 5)})
+	missed, _ := json.Marshal(ChatPacket{Username: "Dids", Message: "Missed", Time: uint64(now + 6)})
+	server.setTail("chat.tail", "["+string(old)+","+string(before)+","+string(repeated)+","+string(missed)+"]")
+	server.disconnect()
+	waitForConnectionMessage(t, messages, eventhandler.ServerDisconnectedType)
+	server.logConsole("85.76.8.230:64178/76561198026306491/NinjaMaster joined [windows/76561198026306491]", now+10)
+	waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType)
+
+	// Only the missed lines are replayed, marked as delayed and in the order they were sent
+	for _, expected := range []string{"Dids gg", "Dids Missed", "NinjaMaster joined"} {
+		message := waitForMessage(t, messages, "the missed lines", func(message eventhandler.Message) bool {
+			return message.Type == eventhandler.DefaultType || message.Type == "" || message.Type == eventhandler.JoinType
+		})
+		if !message.Delayed {
+			t.Fatal("expected the missed lines to be marked as delayed but got", message)
+		}
+		if message.User+" "+message.Message != expected {
+			t.Fatal("expected", expected, "to be replayed but got", message)
+		}
+	}
+	select {
+	case message := <-messages:
+		t.Fatal("did not expect message:", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMissedConsole(t *testing.T) {
+	history := newLineHistory()
+	entries := func(messages ...string) []consoleTailEntry {
+		result := make([]consoleTailEntry, 0)
+		for i, message := range messages {
+			// The server timestamps don't matter, as live console lines have none to compare them with
+			result = append(result, consoleTailEntry{Message: message, Time: int64(i)})
+		}
+		return result
+	}
+	missed := func(entries []consoleTailEntry, mark int64) []string {
+		lines, ok := history.missedConsole(entries, mark)
+		if !ok {
+			return nil
+		}
+		result := make([]string, 0)
+		for _, line := range lines {
+			result = append(result, line.Message)
+		}
+		return result
+	}
+
+	// Nothing can be recovered before any console lines have been seen
+	if result := missed(entries("A", "B"), 0); result != nil {
+		t.Fatal("expected no missed lines but got", result)
+	}
+
+	// Lines after the last seen ones were missed, including repeats of lines seen before
+	for _, line := range []string{"Saving", "A", "Saving"} {
+		history.rememberConsole(consoleKey(line))
+	}
+	_, mark := history.marks(0)
+	history.rememberConsole(consoleKey("C"))
+	if result := missed(entries("Old", "Saving", "A", "Saving", "B", "Saving", "C"), mark); fmt.Sprint(result) != fmt.Sprint([]string{"B", "Saving"}) {
+		t.Fatal("unexpected missed lines:", result)
+	}
+
+	// When none of the seen lines are left, every line was missed
+	if result := missed(entries("D", "E"), mark); fmt.Sprint(result) != fmt.Sprint([]string{"D", "E"}) {
+		t.Fatal("unexpected missed lines:", result)
+	}
+}
+
+func TestRecoverMissedLinesOnClose(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	webrcon, messages := newTestWebrcon(t, server.Listener.Addr().String())
+	if err := webrcon.Open(); err != nil {
+		t.Fatal(err)
+	}
+	waitForConnectionMessage(t, messages, eventhandler.ServerConnectedType)
+
+	// Recovering the missed lines gives up once the client is closed, instead of waiting for the server to reply
+	server.setHang("chat.tail")
+	done := make(chan struct{})
+	go func() {
+		webrcon.recoverMissedLines(0, 0)
+		close(done)
+	}()
+	for len(server.receivedCommands()) <= 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := webrcon.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(commandTimeout / 2):
+		t.Fatal("expected recovering the missed lines to stop when closing")
+	}
+	for _, command := range server.receivedCommands() {
+		if strings.HasPrefix(command, "console.tail") {
+			t.Fatal("expected recovering the missed lines to stop before requesting the console lines")
+		}
+	}
+}
+
+func TestParseConsoleEvents(t *testing.T) {
+	input := filepath.Join("testdata", "console_events.txt")
+	output, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lines that aren't typed events (like joins, disconnects and kills) have no event
+	type result struct {
+		Line  string
+		Event *eventhandler.Message `json:",omitempty"`
+	}
+	results := make([]result, 0)
+	types := make(map[eventhandler.MessageType]bool)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if message, ok := parseConsoleEvent(line); ok {
+			results = append(results, result{Line: line, Event: &message})
+			types[message.Type] = true
+		} else {
+			results = append(results, result{Line: line})
+		}
+	}
+	checkGolden(t, input, results)
+
+	// Every type of console event needs at least one fixture
+	for _, event := range consoleEvents {
+		if !types[event.messageType] {
+			t.Error("no fixture for console events of type", event.messageType)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	rules, err := LoadRules(filepath.Join("testdata", "rules.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rules are sorted by priority, keeping the order of the file otherwise
+	events := make([]string, 0)
+	for _, rule := range rules {
+		events = append(events, rule.Event)
+	}
+	if strings.Join(events, ",") != "startup,custom_event,raidable_base,any_plugin" {
+		t.Fatal("unexpected order of rules:", events)
+	}
+
+	tests := map[string]string{
+		"[RaidableBases] Nightmare raidable base spawned at G12": "A Nightmare raidable base spawned at G12",
+		"[CustomEvents] Zombie horde incoming":                   "[CustomEvents] Zombie horde incoming",
+		"[Backpacks] Saved 12 backpacks":                         "Backpacks: [Backpacks] ...",
+		"Server startup complete":                                "We're up!",
+	}
+	for line, expected := range tests {
+		matched := false
+		for _, rule := range rules {
+			if message, ok := rule.match(line); ok {
+				if message != expected {
+					t.Error("expected", line, "to become", expected, "but got", message)
+				}
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			t.Error("expected a rule to match", line)
+		}
+	}
+
+	// Invalid rules are rejected
+	directory := t.TempDir()
+	for _, rules := range []string{`{}`, `[{"regex": "^foo"}]`, `[{"event": "foo", "regex": "(unclosed"}]`, `[{"event": "foo"}]`} {
+		path := filepath.Join(directory, "rules.json")
+		if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRules(path); err == nil {
+			t.Error("expected an error for", rules)
+		}
+	}
+}
+
+func TestReloadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`[{"event": "zombies", "regex": "^\\[Zombies\\] (?P<count>\\d+) zombies", "template": "$count zombies!"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WEBRCON_RULES_FILE", path)
+	webrcon, messages := newTestWebrcon(t, "127.0.0.1:0")
+
+	// Rules are checked before the built-in console events
+	webrcon.handleTextMessage(Packet{Message: "[Zombies] 12 zombies spawned", Identifier: GenericIdentifier, Type: GenericType}, false)
+	message := waitForMessage(t, messages, "the rule message", func(message eventhandler.Message) bool {
+		return message.Type == eventhandler.RuleType
+	})
+	if message.Message != "12 zombies!" || message.Rule != "zombies" {
+		t.Fatal("unexpected rule message:", message)
+	}
+
+	// Rules can be changed at runtime, keeping the current ones if the new ones are invalid
+	if err := os.WriteFile(path, []byte(`[{"event": "startup", "regex": "^Server startup complete", "template": "Up!"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := webrcon.ReloadRules(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := webrcon.matchRule("[Zombies] 12 zombies spawned"); ok {
+		t.Fatal("expected the old rule to be gone")
+	}
+	if message, ok := webrcon.matchRule("Server startup complete"); !ok || message.Message != "Up!" {
+		t.Fatal("expected the new rule to override the built-in event but got", message)
+	}
+	if err := os.WriteFile(path, []byte(`[{"event": "broken"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := webrcon.ReloadRules(); err == nil {
+		t.Fatal("expected an error for an invalid rule")
+	}
+	if _, ok := webrcon.matchRule("Server startup complete"); !ok {
+		t.Fatal("expected the rules to be kept after failing to reload them")
+	}
+}
@@ -0,0 +1,110 @@
+package webrcon
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Dids/rustbot/eventhandler"
+)
+
+// Rule turns the console lines matching a regex into messages for Discord, for lines printed by plugins of modded servers.
+// Rules are loaded from the JSON file set in WEBRCON_RULES_FILE, for example:
+//
+//	[{
+//	  "event": "raidable_base",
+//	  "regex": "^\\[RaidableBases\\] (?P<difficulty>\\w+) raidable base spawned at (?P<grid>[A-Z]+[0-9]+)",
+//	  "channel": "123456789012345678",
+//	  "template": "A $difficulty raidable base spawned at $grid",
+//	  "priority": 10
+//	}]
+//
+// The template uses the named captures of the regex ($name or ${name}, $0 being the matched text) and defaults to the whole line,
+// while the channel defaults to the notifications channel. Rules with a higher priority are checked first, and before any of the built-in console events.
+type Rule struct {
+	Event    string `json:"event"`
+	Regex    string `json:"regex"`
+	Channel  string `json:"channel,omitempty"`
+	Template string `json:"template,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+
+	// Private properties
+	regex *regexp.Regexp
+}
+
+// LoadRules loads and validates the rules in a file, sorting them by priority (keeping the order of the file for equal priorities)
+func LoadRules(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*Rule, 0)
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, errors.New("invalid rules file " + path + ": " + err.Error())
+	}
+
+	for i, rule := range rules {
+		if rule == nil || len(strings.TrimSpace(rule.Event)) <= 0 {
+			return nil, errors.New("invalid rule " + strconv.Itoa(i+1) + ": missing event name")
+		}
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil || len(rule.Regex) <= 0 {
+			return nil, errors.New("invalid rule " + strconv.Itoa(i+1) + " (" + rule.Event + "): invalid regex " + rule.Regex)
+		}
+		rule.regex = regex
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+	return rules, nil
+}
+
+// match returns the message for a line if it matches the rule
+func (rule *Rule) match(line string) (string, bool) {
+	matches := rule.regex.FindStringSubmatchIndex(line)
+	if matches == nil {
+		return "", false
+	}
+	if len(rule.Template) <= 0 {
+		return line, true
+	}
+	return string(rule.regex.ExpandString(nil, rule.Template, line, matches)), true
+}
+
+// ReloadRules loads the rules from WEBRCON_RULES_FILE again, keeping the current rules if the file is invalid
+func (webrcon *Webrcon) ReloadRules() error {
+	rules := make([]*Rule, 0)
+	if path := ServerEnv(webrcon.ID, "WEBRCON_RULES_FILE"); len(path) > 0 {
+		var err error
+		if rules, err = LoadRules(path); err != nil {
+			return err
+		}
+	}
+
+	webrcon.rulesMutex.Lock()
+	webrcon.rules = rules
+	webrcon.rulesMutex.Unlock()
+
+	webrcon.logger.Info("Loaded", len(rules), "console rules")
+	return nil
+}
+
+// matchRule matches a console line against the rules, returning the message of the first matching rule
+func (webrcon *Webrcon) matchRule(line string) (eventhandler.Message, bool) {
+	webrcon.rulesMutex.Lock()
+	rules := webrcon.rules
+	webrcon.rulesMutex.Unlock()
+
+	line = strings.TrimSpace(line)
+	for _, rule := range rules {
+		if message, ok := rule.match(line); ok {
+			return eventhandler.Message{Message: message, Type: eventhandler.RuleType, Rule: rule.Event, Channel: rule.Channel}, true
+		}
+	}
+	return eventhandler.Message{}, false
+}
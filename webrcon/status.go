@@ -1,6 +1,13 @@
 package webrcon
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Dids/rustbot/eventhandler"
+)
 
 // TODO: Rewrite this at some point so we can stop it if we want to,
 //       for example if we start updating after the "ready" websocket
@@ -11,28 +18,82 @@ func (webrcon *Webrcon) startUpdatingStatus() {
 	}()
 
 	for {
-		if webrcon.isShuttingDown {
+		if webrcon.isShuttingDown() {
+			return
+		}
+
+		// Skip while we're not connected, the reconnect logic will take care of it
+		if webrcon.isConnected() {
+			webrcon.logger.Trace("Requesting status..")
+			if status, err := webrcon.GetStatus(webrcon.ctx); err != nil {
+				webrcon.logger.Warning("Failed to get status:", err)
+			} else {
+				webrcon.handleStatus(status)
+			}
+		}
+
+		// Sleep for a bit before requesting the status (Discord API only allows the presence to be updated every 15 seconds)
+		select {
+		case <-webrcon.stop:
 			return
+		case <-time.After(15 * time.Second):
 		}
+	}
+}
 
-		webrcon.logger.Trace("Requesting status..")
+// GetStatus returns the current status of the server, including the players
+func (webrcon *Webrcon) GetStatus(ctx context.Context) (*StatusPacket, error) {
+	output, err := webrcon.Execute(ctx, "status")
+	if err != nil {
+		return nil, err
+	}
+	status, err := ParseStatus(output)
+	if err != nil {
+		return nil, err
+	}
 
-		// Lock the write mutex
-		webrcon.writeMutex.Lock()
+	// Prefer the players from the playerlist command, as its JSON output can't be broken by odd usernames
+	if output, err := webrcon.Execute(ctx, "playerlist"); err != nil {
+		webrcon.logger.Warning("Failed to get player list, using the status instead:", err)
+	} else if players, err := ParsePlayerList(output); err != nil {
+		webrcon.logger.Trace("Failed to parse player list, using the status instead:", err)
+	} else {
+		status.Players = players
+	}
 
-		// Set the deadline (timeout) for reading the next incoming message
-		//webrcon.Client.Conn.SetReadDeadline(time.Now().Add(pongWait)) // TODO: Since this is pong specific, we shouldn't use it here, but create a const time for statusWait instead?
+	return status, nil
+}
 
-		// Set the deadline (timeout) for the next sent message
-		webrcon.Client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+// LastStatus returns the latest status of the server, or nil if it hasn't been received yet
+func (webrcon *Webrcon) LastStatus() *StatusPacket {
+	webrcon.statusMutex.Lock()
+	defer webrcon.statusMutex.Unlock()
+	return webrcon.status
+}
 
-		// Send the status request message
-		webrcon.Client.SendText(`{ "Message": "status", "Identifier": 0, "Type": "Generic" }`)
+// handleStatus stores the server status and emits it along with the player list
+func (webrcon *Webrcon) handleStatus(status *StatusPacket) {
+	webrcon.statusMutex.Lock()
+	webrcon.status = status
+	webrcon.statusMutex.Unlock()
 
-		// Unlock the write mutex
-		webrcon.writeMutex.Unlock()
+	playersString, err := json.Marshal(status.Players)
+	if err != nil {
+		webrcon.logger.Error("Failed to convert player list to JSON:", err)
+	} else {
+		// Emit the player list change to the event handler
+		webrcon.emit(eventhandler.Message{User: status.Hostname, Message: string(playersString), Type: eventhandler.PlayersType})
+	}
 
-		// Sleep for a bit before requesting the status (Discord API only allows the presence to be updated every 15 seconds)
-		time.Sleep(15 * time.Second)
+	// Handle message formatting depending on how many players there are
+	suffix := ""
+	if status.JoiningPlayers > 0 && status.QueuedPlayers > 0 {
+		suffix = " (" + strconv.Itoa(status.JoiningPlayers) + " joining, " + strconv.Itoa(status.QueuedPlayers) + " queued)"
+	} else if status.JoiningPlayers > 0 {
+		suffix = " (" + strconv.Itoa(status.JoiningPlayers) + " joining)"
+	} else if status.QueuedPlayers > 0 {
+		suffix = " (" + strconv.Itoa(status.QueuedPlayers) + " queued)"
 	}
+	message := strconv.Itoa(status.CurrentPlayers) + "/" + strconv.Itoa(status.MaxPlayers) + suffix
+	webrcon.emit(eventhandler.Message{User: status.Hostname, Message: message, Type: eventhandler.StatusType})
 }
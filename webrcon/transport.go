@@ -0,0 +1,52 @@
+package webrcon
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// Transport is a connection to the server that commands are sent over and console output is received from,
+// either over Webrcon (websockets) or classic Source RCON (TCP)
+type Transport interface {
+	// Connect connects to the server, calling OnConnected or OnConnectError before returning
+	Connect()
+	// Close closes the connection (if any), calling OnDisconnected
+	Close()
+	// IsConnected reports whether there's an active connection
+	IsConnected() bool
+	// Send sends a command to the server, its output being passed to OnPacket with the same identifier
+	Send(packet Packet) error
+	// Ping checks that the connection is still alive, closing it (and triggering OnDisconnected) if it isn't
+	Ping() error
+}
+
+// TransportHandlers are called by a Transport when the connection changes or a packet is received
+type TransportHandlers struct {
+	OnConnected    func()
+	OnDisconnected func(err error)
+	OnConnectError func(err error)
+	OnPacket       func(packet Packet)
+}
+
+const (
+	// WebsocketTransport connects to the server over Webrcon (rcon.web 1)
+	WebsocketTransport = "websocket"
+	// RconTransport connects to the server over classic Source RCON (rcon.web 0)
+	RconTransport = "rcon"
+)
+
+// newTransport creates the transport selected by WEBRCON_TRANSPORT for a server, defaulting to Webrcon
+func newTransport(serverID string, handlers TransportHandlers) (Transport, error) {
+	address := net.JoinHostPort(ServerEnv(serverID, "WEBRCON_HOST"), ServerEnv(serverID, "WEBRCON_PORT"))
+	password := ServerEnv(serverID, "WEBRCON_PASSWORD")
+
+	switch transport := strings.ToLower(strings.TrimSpace(ServerEnv(serverID, "WEBRCON_TRANSPORT"))); transport {
+	case "", WebsocketTransport:
+		return newWebsocketTransport(address, password, handlers), nil
+	case RconTransport:
+		return newRconTransport(address, password, handlers), nil
+	default:
+		return nil, errors.New("invalid WEBRCON_TRANSPORT: " + transport + " (expected " + WebsocketTransport + " or " + RconTransport + ")")
+	}
+}
@@ -0,0 +1,147 @@
+package webrcon
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Dids/rustbot/logger"
+	"github.com/gorilla/websocket"
+	"github.com/sacOO7/gowebsocket"
+)
+
+// websocketTransport connects to the server over Webrcon, where every message is a JSON encoded packet
+type websocketTransport struct {
+	url        string
+	handlers   TransportHandlers
+	logger     *logger.Logger
+	conn       *websocket.Conn
+	connMutex  *sync.Mutex
+	writeMutex *sync.Mutex
+}
+
+func newWebsocketTransport(address string, password string, handlers TransportHandlers) *websocketTransport {
+	return &websocketTransport{
+		url:        "ws://" + address + "/" + password,
+		handlers:   handlers,
+		logger:     logger.GetLogger(),
+		connMutex:  &sync.Mutex{},
+		writeMutex: &sync.Mutex{},
+	}
+}
+
+func (transport *websocketTransport) Connect() {
+	// Every connection gets its own websocket client, as the client updates its state from the reader without any locking
+	// (which would race with the reader of the previous connection and with us)
+	socket := gowebsocket.New(transport.url)
+
+	// Setup websocket client event handlers
+	socket.OnConnected = func(socket gowebsocket.Socket) {
+		transport.connMutex.Lock()
+		transport.conn = socket.Conn
+		transport.connMutex.Unlock()
+		transport.handlers.OnConnected()
+	}
+	socket.OnDisconnected = func(err error, socket gowebsocket.Socket) {
+		// The client may report the same disconnect twice (on a close message and on the read error that follows it)
+		if transport.detach(socket.Conn) {
+			transport.handlers.OnDisconnected(err)
+		}
+	}
+	socket.OnConnectError = func(err error, socket gowebsocket.Socket) {
+		transport.handlers.OnConnectError(err)
+	}
+	socket.OnTextMessage = transport.handleTextMessage
+
+	socket.Connect()
+}
+
+func (transport *websocketTransport) Close() {
+	// Only close the connection if we actually managed to connect
+	conn := transport.connection()
+	if conn == nil {
+		return
+	}
+
+	// Let the server know that we're going away before closing the connection
+	transport.writeMutex.Lock()
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+	transport.writeMutex.Unlock()
+
+	if !transport.detach(conn) {
+		return
+	}
+	transport.handlers.OnDisconnected(nil)
+}
+
+func (transport *websocketTransport) IsConnected() bool {
+	return transport.connection() != nil
+}
+
+func (transport *websocketTransport) Send(packet Packet) error {
+	data, err := json.Marshal(packet)
+	if err != nil {
+		return err
+	}
+
+	conn := transport.connection()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	transport.writeMutex.Lock()
+	defer transport.writeMutex.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (transport *websocketTransport) Ping() error {
+	conn := transport.connection()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	transport.writeMutex.Lock()
+	defer transport.writeMutex.Unlock()
+	conn.SetReadDeadline(time.Now().Add(pongWait)) // TODO: This might break things, or simply be unnecessary
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+		// Closing the broken connection makes the reader fail, which triggers the reconnect logic
+		conn.Close()
+		return err
+	}
+	return nil
+}
+
+// connection returns the active connection, or nil if there isn't one
+func (transport *websocketTransport) connection() *websocket.Conn {
+	transport.connMutex.Lock()
+	defer transport.connMutex.Unlock()
+	return transport.conn
+}
+
+// detach closes a connection, and reports whether it was still the active one (in which case the disconnect should be handled)
+func (transport *websocketTransport) detach(conn *websocket.Conn) bool {
+	if conn == nil {
+		return false
+	}
+
+	transport.connMutex.Lock()
+	active := transport.conn == conn
+	if active {
+		transport.conn = nil
+	}
+	transport.connMutex.Unlock()
+
+	conn.Close()
+	return active
+}
+
+// handleTextMessage parses an incoming message as a webrcon packet
+func (transport *websocketTransport) handleTextMessage(message string, socket gowebsocket.Socket) {
+	packet := Packet{}
+	if err := json.Unmarshal([]byte(message), &packet); err != nil {
+		transport.logger.Error("Failed to parse as generic message:", message, err)
+		return
+	}
+	transport.handlers.OnPacket(packet)
+}